@@ -77,9 +77,8 @@ func TestMoveMessages(t *testing.T) {
 			myMessages = append(myMessages, fmt.Sprintf("%v", msg.Properties.MessageID))
 		}
 
-		errs := a.Move(context.Background(), deadLetterQueue, queueName, myMessages)
-		if errs != nil {
-			t.Error(errs[0])
+		if err := resultError(a.Move(context.Background(), deadLetterQueue, queueName, myMessages)); err != nil {
+			t.Error(err)
 		}
 
 		messages, _ := a.GetAllMessages(context.Background(), queueName)
@@ -99,6 +98,37 @@ func TestMoveMessages(t *testing.T) {
 
 }
 
+func TestMoveMessages_NoPrefix(t *testing.T) {
+	queueName := "movetohere"
+	deadLetterQueue := queueName + "_fromhere"
+
+	a, err := NewActiveMQAdapter(context.Background(), "amqp://localhost:5671", "admin", "admin", "http://localhost:8162", "admin", "admin", false)
+	if err != nil {
+		t.Fatalf("No connection. %s", err)
+	}
+	mySender, err, closeSession := a.getNewSender(context.Background(), deadLetterQueue)
+	if err != nil {
+		t.Skipf("No broker. %s", err)
+	}
+	defer closeSession()
+
+	// ActiveMQ knows this message as ID:AMQP_NO_PREFIX:<its ID>
+	msg := amqp.Message{Data: [][]byte{[]byte("no prefix")}}
+	msg.Properties = &amqp.MessageProperties{MessageID: "no-prefix-" + uuid.New().String(), CreationTime: time.Now().UTC()}
+	msg.Header = &amqp.MessageHeader{Durable: true}
+	if err := mySender.Send(context.Background(), &msg); err != nil {
+		t.Fatalf("Doom. %s", err)
+	}
+	msgId := fmt.Sprintf("%v", msg.Properties.MessageID)
+
+	if err := a.MoveOne(context.Background(), deadLetterQueue, queueName, msgId); err != nil {
+		t.Fatalf("MoveOne() error = %v", err)
+	}
+	if _, err := a.GetMessage(context.Background(), queueName, msgId); err != nil {
+		t.Errorf("GetMessage() of the moved message error = %v", err)
+	}
+}
+
 func TestDeleteMessages(t *testing.T) {
 
 	queueName := "todeletefrom"
@@ -144,9 +174,8 @@ func TestDeleteMessages(t *testing.T) {
 			myMessages = append(myMessages, fmt.Sprintf("%v", msg.Properties.MessageID))
 		}
 
-		errs := a.DeleteMany(context.Background(), queueName, myMessages)
-		if errs != nil {
-			t.Error(errs[0])
+		if err := resultError(a.DeleteMany(context.Background(), queueName, myMessages)); err != nil {
+			t.Error(err)
 		}
 	})
 
@@ -0,0 +1,401 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+const (
+	// activeMQJolokiaPath is where the ActiveMQ Classic web console serves its Jolokia management API
+	activeMQJolokiaPath = "/api/jolokia"
+	// activeMQQueuePattern matches the MBean of every queue on every broker
+	activeMQQueuePattern = "org.apache.activemq:type=Broker,brokerName=*,destinationType=Queue,destinationName=*"
+)
+
+// ActiveMQJolokiaAdapter manages an ActiveMQ Classic broker through the Jolokia API of its web console, using the
+// broker's own browse, move, copy, remove and purge operations, so it needs no AMQP connection.  Browsing returns
+// at most the queue's maxBrowsePageSize messages, 400 by default.
+type ActiveMQJolokiaAdapter struct {
+	jolokia *jolokiaClient
+}
+
+type activeMQQueueAttributes struct {
+	Name               string `json:"Name"`
+	QueueSize          int64  `json:"QueueSize"`
+	ConsumerCount      int64  `json:"ConsumerCount"`
+	ProducerCount      int64  `json:"ProducerCount"`
+	EnqueueCount       int64  `json:"EnqueueCount"`
+	DequeueCount       int64  `json:"DequeueCount"`
+	ExpiredCount       int64  `json:"ExpiredCount"`
+	InFlightCount      int64  `json:"InFlightCount"`
+	MemoryPercentUsage int64  `json:"MemoryPercentUsage"`
+}
+
+// Returns an ActiveMQ Jolokia adapter:
+// consoleUrl: comma separated web console URLs, e.g. http://localhost:8161, tried in order until one answers
+// consoleUser, consolePasswd: console credentials
+// A console that can't be reached doesn't stop the adapter from starting; its calls fail until the console is back.
+func NewActiveMQJolokiaAdapter(ctx context.Context, consoleUrl, consoleUser, consolePasswd string) (*ActiveMQJolokiaAdapter, error) {
+	adapter := &ActiveMQJolokiaAdapter{
+		jolokia: newJolokiaClient(consoleUrl, activeMQJolokiaPath, consoleUser, consolePasswd),
+	}
+
+	err := adapter.jolokia.call(ctx, jolokiaRequest{Type: "version"}, nil)
+	if errors.Is(err, ErrBrokerUnavailable) {
+		log.Printf("ActiveMQ console at %s can't be reached, carrying on without it: %s", consoleUrl, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return adapter, nil
+}
+
+func (a *ActiveMQJolokiaAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	queueAttributes, err := a.readQueues(ctx, "Name", "QueueSize", "ConsumerCount", "ProducerCount", "EnqueueCount",
+		"DequeueCount", "ExpiredCount", "InFlightCount", "MemoryPercentUsage")
+	if err != nil {
+		return nil, err
+	}
+
+	queues := []Queue{}
+	for mbean, attributes := range queueAttributes {
+		queues = append(queues, Queue{
+			Name: attributes.Name,
+			Info: map[string]string{
+				"Size":                 strconv.FormatInt(attributes.QueueSize, 10),
+				"Consumers":            strconv.FormatInt(attributes.ConsumerCount, 10),
+				"Producers":            strconv.FormatInt(attributes.ProducerCount, 10),
+				"Enqueued":             strconv.FormatInt(attributes.EnqueueCount, 10),
+				"Dequeued":             strconv.FormatInt(attributes.DequeueCount, 10),
+				"Expired":              strconv.FormatInt(attributes.ExpiredCount, 10),
+				"In Flight":            strconv.FormatInt(attributes.InFlightCount, 10),
+				"Memory Percent Usage": strconv.FormatInt(attributes.MemoryPercentUsage, 10),
+				"Broker":               parseObjectNameProperties(mbean)["brokerName"],
+			},
+		})
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+
+	return queues, nil
+}
+
+// QueueSize lets the Jolokia API find queue sizes for an AMQPAdapter too
+func (a *ActiveMQJolokiaAdapter) QueueSize(ctx context.Context, queueName string) (int, error) {
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	if err := a.jolokia.call(ctx, jolokiaRequest{Type: "read", MBean: mbean, Attribute: []string{"QueueSize"}}, &struct {
+		QueueSize *int64 `json:"QueueSize"`
+	}{&size}); err != nil {
+		return 0, err
+	}
+	return int(size), nil
+}
+
+func (a *ActiveMQJolokiaAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []map[string]interface{}
+	if err := a.exec(ctx, mbean, "browse()", &messages); err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, message := range messages {
+		stdMessages = append(stdMessages, convertActiveMQCompositeMessage(message))
+	}
+	return stdMessages, nil
+}
+
+// GetMessages pages what browse returns by offset
+func (a *ActiveMQJolokiaAdapter) GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error) {
+	offset, err := pageOffset(page)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	messages, err := a.GetAllMessages(ctx, queueName)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	return offsetPage(messages, offset, pageLimit(page)), nil
+}
+
+// GetMessage browses the one message with the given ID, however far down the queue it is
+func (a *ActiveMQJolokiaAdapter) GetMessage(ctx context.Context, queueName string, messageID string) (structs.StandardMessage, error) {
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return structs.StandardMessage{}, err
+	}
+
+	var messages []map[string]interface{}
+	if err := a.exec(ctx, mbean, "browse(java.lang.String)", &messages, messageIDSelector([]string{messageID})); err != nil {
+		return structs.StandardMessage{}, err
+	}
+	if len(messages) == 0 {
+		return structs.StandardMessage{}, messageNotFound(messageID)
+	}
+	return convertActiveMQCompositeMessage(messages[0]), nil
+}
+
+// Capabilities adds the filters the broker selects by
+func (a *ActiveMQJolokiaAdapter) Capabilities() Capabilities {
+	capabilities := defaultCapabilities(a)
+	capabilities.Filters = []string{FilterMessageID, FilterCorrelationID, FilterDate}
+	return capabilities
+}
+
+// GetFilteredMessages has the broker select messages by ID, correlation ID and timestamp, so the filter reaches
+// past the messages browse() returns, and applies the rest of the filter to what it selects
+func (a *ActiveMQJolokiaAdapter) GetFilteredMessages(ctx context.Context, queueName string, filter MessageFilter) ([]structs.StandardMessage, error) {
+	selector := filterSelector(filter, true)
+	if selector == "" {
+		messages, err := a.GetAllMessages(ctx, queueName)
+		if err != nil {
+			return nil, err
+		}
+		return filter.Apply(messages), nil
+	}
+
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []map[string]interface{}
+	if err := a.exec(ctx, mbean, "browse(java.lang.String)", &messages, selector); err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, message := range messages {
+		stdMessages = append(stdMessages, convertActiveMQCompositeMessage(message))
+	}
+	return filter.Apply(stdMessages), nil
+}
+
+// GetMessagesBySelector browses the messages whose headers hold the given values, however far down the queue they are
+func (a *ActiveMQJolokiaAdapter) GetMessagesBySelector(ctx context.Context, queueName string, headers map[string]string) ([]structs.StandardMessage, error) {
+	selector, err := headerSelector(headers)
+	if err != nil {
+		return nil, err
+	}
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []map[string]interface{}
+	if err := a.exec(ctx, mbean, "browse(java.lang.String)", &messages, selector); err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, message := range messages {
+		stdMessages = append(stdMessages, convertActiveMQCompositeMessage(message))
+	}
+	return stdMessages, nil
+}
+
+func (a *ActiveMQJolokiaAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, a.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (a *ActiveMQJolokiaAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	toQueue, _ = url.QueryUnescape(toQueue)
+	return a.execForEach(ctx, fromQueue, "moveMessageTo(java.lang.String,java.lang.String)", messageIDs, toQueue)
+}
+
+func (a *ActiveMQJolokiaAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(a.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+// Copy puts a copy of each message on toQueue, leaving the original where it is
+func (a *ActiveMQJolokiaAdapter) Copy(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageCopied, a.copy(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (a *ActiveMQJolokiaAdapter) copy(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	toQueue, _ = url.QueryUnescape(toQueue)
+	return a.execForEach(ctx, fromQueue, "copyMessageTo(java.lang.String,java.lang.String)", messageIDs, toQueue)
+}
+
+func (a *ActiveMQJolokiaAdapter) Purge(ctx context.Context, queueName string) error {
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return err
+	}
+	return a.exec(ctx, mbean, "purge()", nil)
+}
+
+func (a *ActiveMQJolokiaAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return resultError(a.DeleteMany(ctx, queueName, []string{messageID}))
+}
+
+func (a *ActiveMQJolokiaAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, a.deleteMany(ctx, queueName, messageIDs))
+}
+
+func (a *ActiveMQJolokiaAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	return a.execForEach(ctx, queueName, "removeMessage(java.lang.String)", messageIDs)
+}
+
+// execForEach runs a queue operation that takes a message ID, and returns whether it found the message, for each
+// of the messages
+func (a *ActiveMQJolokiaAdapter) execForEach(ctx context.Context, queueName string, operation string, messageIDs []string, args ...interface{}) []error {
+	var execErrors []error
+
+	mbean, err := a.queueMBean(ctx, queueName)
+	if err != nil {
+		return append(execErrors, err)
+	}
+
+	for _, messageID := range messageIDs {
+		var found bool
+		if err := a.exec(ctx, mbean, operation, &found, append([]interface{}{messageID}, args...)...); err != nil {
+			execErrors = append(execErrors, forMessage(messageID, err))
+			continue
+		}
+		if !found {
+			execErrors = append(execErrors, messageNotFound(messageID))
+		}
+	}
+	return execErrors
+}
+
+func (a *ActiveMQJolokiaAdapter) exec(ctx context.Context, mbean string, operation string, value interface{}, args ...interface{}) error {
+	return a.jolokia.call(ctx, jolokiaRequest{
+		Type:      "exec",
+		MBean:     mbean,
+		Operation: operation,
+		Arguments: args,
+	}, value)
+}
+
+// readQueues reads the attributes of every queue, keyed by MBean name
+func (a *ActiveMQJolokiaAdapter) readQueues(ctx context.Context, attributes ...string) (map[string]activeMQQueueAttributes, error) {
+	queueAttributes := make(map[string]activeMQQueueAttributes)
+	err := a.jolokia.call(ctx, jolokiaRequest{
+		Type:      "read",
+		MBean:     activeMQQueuePattern,
+		Attribute: attributes,
+	}, &queueAttributes)
+	if isJolokiaNotFound(err) {
+		// a broker without queues has no MBeans matching the pattern
+		return queueAttributes, nil
+	}
+	return queueAttributes, err
+}
+
+// queueMBean finds the MBean of the queue.  ActiveMQ mangles some characters of queue names in MBean names, so
+// the queue is looked up by its Name attribute.
+func (a *ActiveMQJolokiaAdapter) queueMBean(ctx context.Context, queueName string) (string, error) {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	queueAttributes, err := a.readQueues(ctx, "Name")
+	if err != nil {
+		return "", err
+	}
+
+	var mbeans []string
+	for mbean, attributes := range queueAttributes {
+		if attributes.Name == queueName {
+			mbeans = append(mbeans, mbean)
+		}
+	}
+	if len(mbeans) == 0 {
+		return "", queueNotFound(queueName)
+	}
+	// a queue of the same name on more than one broker is managed on the first of them
+	sort.Strings(mbeans)
+	return mbeans[0], nil
+}
+
+// convertActiveMQCompositeMessage converts a message browsed over JMX: its JMS headers and properties become
+// headers and its text, bytes or map is the body
+func convertActiveMQCompositeMessage(message map[string]interface{}) structs.StandardMessage {
+	headers := make(map[string]string)
+	body := "<unknown body structure>"
+	var timestamp time.Time
+
+	for key, value := range message {
+		if value == nil {
+			continue
+		}
+		switch {
+		case key == "JMSMessageID":
+		case key == "JMSTimestamp":
+			timestamp = parseJolokiaDate(value)
+		case key == "Text":
+			body = fmt.Sprintf("%v", value)
+		case key == "BodyPreview":
+			if _, isText := message["Text"]; !isText {
+				body = jolokiaBytesToString(value)
+			}
+		case key == "ContentMap":
+			if contentMap, err := json.Marshal(value); err == nil {
+				body = string(contentMap)
+			}
+		case key == "PropertiesText":
+			// the same properties as the typed *Properties tables, as one string
+		case strings.HasSuffix(key, "Properties"):
+			// tabular data comes back keyed by property name, each row holding the key and value
+			rows, _ := value.(map[string]interface{})
+			for name, row := range rows {
+				if row, ok := row.(map[string]interface{}); ok {
+					headers[name] = fmt.Sprintf("%v", row["value"])
+				}
+			}
+		default:
+			headers[key] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	return structs.StandardMessage{
+		MessageID: fmt.Sprintf("%v", message["JMSMessageID"]),
+		Timestamp: timestamp,
+		Headers:   headers,
+		Body:      body,
+	}
+}
+
+// parseJolokiaDate reads a java.util.Date, which Jolokia serializes as an ISO 8601 string or, configured to, as
+// milliseconds since the epoch
+func parseJolokiaDate(value interface{}) time.Time {
+	switch date := value.(type) {
+	case string:
+		timestamp, _ := time.Parse(time.RFC3339, date)
+		return timestamp.UTC()
+	case float64:
+		return time.Unix(0, int64(date)*int64(time.Millisecond)).UTC()
+	}
+	return time.Time{}
+}
+
+// jolokiaBytesToString reads a Java byte array, which Jolokia serializes as an array of signed numbers
+func jolokiaBytesToString(value interface{}) string {
+	numbers, _ := value.([]interface{})
+	bytes := make([]byte, 0, len(numbers))
+	for _, number := range numbers {
+		if number, ok := number.(float64); ok {
+			bytes = append(bytes, byte(int8(number)))
+		}
+	}
+	return string(bytes)
+}
@@ -0,0 +1,354 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// fakeActiveMQJolokia answers the Jolokia requests the adapter makes as an ActiveMQ Classic broker named
+// localhost would.  Messages are kept as the composite data browse returns.
+type fakeActiveMQJolokia struct {
+	mutex    sync.Mutex
+	queues   map[string][]map[string]interface{}
+	sequence int
+}
+
+func newFakeActiveMQJolokia(t *testing.T) (*fakeActiveMQJolokia, *httptest.Server) {
+	fake := &fakeActiveMQJolokia{queues: make(map[string][]map[string]interface{})}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeActiveMQJolokia) mbean(queueName string) string {
+	// ActiveMQ replaces characters like commas in MBean names
+	return "org.apache.activemq:brokerName=localhost,destinationName=" + strings.ReplaceAll(queueName, ",", "_") +
+		",destinationType=Queue,type=Broker"
+}
+
+func (f *fakeActiveMQJolokia) addQueue(queueName string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.queues[queueName] = nil
+}
+
+func (f *fakeActiveMQJolokia) send(queueName string, bodies ...string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ids []string
+	for _, body := range bodies {
+		f.sequence++
+		id := fmt.Sprintf("ID:fake-broker-1:1:1:1:%d", f.sequence)
+		f.queues[queueName] = append(f.queues[queueName], map[string]interface{}{
+			"JMSMessageID":     id,
+			"JMSTimestamp":     time.Date(2020, 4, 1, 12, 0, f.sequence, 0, time.UTC).Format(time.RFC3339),
+			"JMSDeliveryMode":  "PERSISTENT",
+			"JMSPriority":      4,
+			"JMSCorrelationID": nil,
+			"Text":             body,
+			"StringProperties": map[string]interface{}{"origin": map[string]interface{}{"key": "origin", "value": "test"}},
+			"IntProperties":    map[string]interface{}{},
+			"PropertiesText":   "{origin=test}",
+		})
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (f *fakeActiveMQJolokia) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" || r.URL.Path != activeMQJolokiaPath {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var request jolokiaRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	status, value := f.handle(request)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "value": value, "error": fmt.Sprint(value)})
+}
+
+func (f *fakeActiveMQJolokia) handle(request jolokiaRequest) (int, interface{}) {
+	switch {
+	case request.Type == "version":
+		return http.StatusOK, map[string]string{"agent": "1.6.2"}
+	case request.Type == "read" && request.MBean == activeMQQueuePattern:
+		if len(f.queues) == 0 {
+			return http.StatusNotFound, "No MBean found"
+		}
+		queues := make(map[string]interface{})
+		for name, messages := range f.queues {
+			queues[f.mbean(name)] = map[string]interface{}{"Name": name, "QueueSize": len(messages), "ConsumerCount": 0, "EnqueueCount": 7, "MemoryPercentUsage": 0}
+		}
+		return http.StatusOK, queues
+	}
+
+	var queueName string
+	for name := range f.queues {
+		if f.mbean(name) == request.MBean {
+			queueName = name
+		}
+	}
+	if queueName == "" {
+		return http.StatusNotFound, "No MBean " + request.MBean
+	}
+
+	if request.Type == "read" {
+		return http.StatusOK, map[string]interface{}{"QueueSize": len(f.queues[queueName])}
+	}
+
+	find := func(id interface{}) int {
+		for i, message := range f.queues[queueName] {
+			if message["JMSMessageID"] == id {
+				return i
+			}
+		}
+		return -1
+	}
+	take := func(i int) map[string]interface{} {
+		message := f.queues[queueName][i]
+		f.queues[queueName] = append(f.queues[queueName][:i:i], f.queues[queueName][i+1:]...)
+		return message
+	}
+
+	switch request.Operation {
+	case "browse()":
+		return http.StatusOK, f.queues[queueName]
+	case "browse(java.lang.String)":
+		// only the name = 'value' AND ... selectors the adapter makes are understood
+		selected := []interface{}{}
+		for _, message := range f.queues[queueName] {
+			matches := true
+			for _, condition := range strings.Split(request.Arguments[0].(string), " AND ") {
+				nameAndValue := strings.SplitN(condition, " = ", 2)
+				value := strings.ReplaceAll(strings.Trim(nameAndValue[1], "'"), "''", "'")
+				if nameAndValue[0] == "JMSMessageID" {
+					matches = matches && message["JMSMessageID"] == value
+					continue
+				}
+				property, _ := message["StringProperties"].(map[string]interface{})[nameAndValue[0]].(map[string]interface{})
+				matches = matches && property != nil && property["value"] == value
+			}
+			if matches {
+				selected = append(selected, message)
+			}
+		}
+		return http.StatusOK, selected
+	case "removeMessage(java.lang.String)":
+		i := find(request.Arguments[0])
+		if i >= 0 {
+			take(i)
+		}
+		return http.StatusOK, i >= 0
+	case "moveMessageTo(java.lang.String,java.lang.String)", "copyMessageTo(java.lang.String,java.lang.String)":
+		i := find(request.Arguments[0])
+		if i < 0 {
+			return http.StatusOK, false
+		}
+		message := f.queues[queueName][i]
+		if strings.HasPrefix(request.Operation, "move") {
+			take(i)
+		} else {
+			f.sequence++
+			copied := make(map[string]interface{})
+			for key, value := range message {
+				copied[key] = value
+			}
+			copied["JMSMessageID"] = fmt.Sprintf("ID:fake-broker-1:1:1:1:%d", f.sequence)
+			message = copied
+		}
+		toQueue := request.Arguments[1].(string)
+		f.queues[toQueue] = append(f.queues[toQueue], message)
+		return http.StatusOK, true
+	case "purge()":
+		f.queues[queueName] = nil
+		return http.StatusOK, nil
+	}
+	return http.StatusBadRequest, "unknown operation " + request.Operation
+}
+
+func newTestActiveMQJolokiaAdapter(t *testing.T) (*ActiveMQJolokiaAdapter, *fakeActiveMQJolokia) {
+	fake, server := newFakeActiveMQJolokia(t)
+	adapter, err := NewActiveMQJolokiaAdapter(context.Background(), server.URL, "admin", "secret")
+	if err != nil {
+		t.Fatalf("NewActiveMQJolokiaAdapter() error = %v", err)
+	}
+	return adapter, fake
+}
+
+var testActiveMQQueueCount int
+
+func TestActiveMQJolokiaAdapter(t *testing.T) {
+	adapter, fake := newTestActiveMQJolokiaAdapter(t)
+
+	testAdapter(t, adapterTestHarness{
+		adapter: adapter,
+		newQueue: func(t *testing.T) string {
+			testActiveMQQueueCount++
+			queueName := fmt.Sprintf("test,%d", testActiveMQQueueCount)
+			fake.addQueue(queueName)
+			return queueName
+		},
+		send: func(t *testing.T, queueName string, bodies ...string) {
+			fake.send(queueName, bodies...)
+		},
+	})
+}
+
+func TestActiveMQJolokiaAdapter_Queues(t *testing.T) {
+	adapter, fake := newTestActiveMQJolokiaAdapter(t)
+	ctx := context.Background()
+
+	queues, err := adapter.GetAllQueues(ctx)
+	if err != nil || len(queues) != 0 {
+		t.Fatalf("GetAllQueues() of a broker without queues = %v, %v", queues, err)
+	}
+
+	fake.send("orders.dlq", "one", "two")
+	queues, err = adapter.GetAllQueues(ctx)
+	if err != nil || len(queues) != 1 {
+		t.Fatalf("GetAllQueues() = %v, %v", queues, err)
+	}
+	if info := queues[0].Info; info["Size"] != "2" || info["Enqueued"] != "7" || info["Consumers"] != "0" || info["Broker"] != "localhost" {
+		t.Errorf("GetAllQueues() info = %v", info)
+	}
+
+	if size, err := adapter.QueueSize(ctx, "orders.dlq"); err != nil || size != 2 {
+		t.Errorf("QueueSize() = %d, %v, want 2", size, err)
+	}
+	if _, err := adapter.GetAllMessages(ctx, "missing"); err == nil {
+		t.Errorf("GetAllMessages() of an unknown queue should fail")
+	}
+}
+
+func TestActiveMQJolokiaAdapter_Messages(t *testing.T) {
+	adapter, fake := newTestActiveMQJolokiaAdapter(t)
+	ctx := context.Background()
+	ids := fake.send("orders.dlq", "one", "two")
+
+	messages, err := adapter.GetAllMessages(ctx, "orders.dlq")
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("GetAllMessages() = %v, %v", messages, err)
+	}
+	first := messages[0]
+	if first.MessageID != ids[0] || first.Body != "one" || !first.Timestamp.Equal(time.Date(2020, 4, 1, 12, 0, 1, 0, time.UTC)) {
+		t.Errorf("GetAllMessages() first message = %v", first)
+	}
+	if first.Headers["origin"] != "test" || first.Headers["JMSPriority"] != "4" || first.Headers["JMSDeliveryMode"] != "PERSISTENT" {
+		t.Errorf("GetAllMessages() first message headers = %v", first.Headers)
+	}
+	if _, ok := first.Headers["JMSCorrelationID"]; ok {
+		t.Errorf("GetAllMessages() shows empty headers: %v", first.Headers)
+	}
+
+	message, err := adapter.GetMessage(ctx, "orders.dlq", ids[1])
+	if err != nil || message.Body != "two" {
+		t.Errorf("GetMessage() = %v, %v", message, err)
+	}
+	if _, err := adapter.GetMessage(ctx, "orders.dlq", "ID:unknown'"); err == nil {
+		t.Errorf("GetMessage() of an unknown message should fail")
+	}
+
+	copyIDs := []string{ids[0], "unknown"}
+	wantResults(t, "Copy()", adapter.Copy(ctx, "orders.dlq", "orders", copyIDs), copyIDs, structs.MessageCopied, structs.MessageNotFound)
+	copies, _ := adapter.GetAllMessages(ctx, "orders")
+	originals, _ := adapter.GetAllMessages(ctx, "orders.dlq")
+	if len(copies) != 1 || copies[0].Body != "one" || copies[0].MessageID == ids[0] || len(originals) != 2 {
+		t.Errorf("after Copy() orders = %v, orders.dlq = %v", copies, originals)
+	}
+}
+
+func TestActiveMQJolokiaAdapter_GetMessagesBySelector(t *testing.T) {
+	adapter, fake := newTestActiveMQJolokiaAdapter(t)
+	ctx := context.Background()
+	fake.send("orders.dlq", "one", "two")
+
+	messages, err := adapter.GetMessagesBySelector(ctx, "orders.dlq", map[string]string{"origin": "test"})
+	if err != nil || len(messages) != 2 {
+		t.Errorf("GetMessagesBySelector() = %v, %v, want both messages", messages, err)
+	}
+	messages, err = adapter.GetMessagesBySelector(ctx, "orders.dlq", map[string]string{"origin": "it's elsewhere"})
+	if err != nil || len(messages) != 0 {
+		t.Errorf("GetMessagesBySelector() of another origin = %v, %v, want none", messages, err)
+	}
+	if _, err := adapter.GetMessagesBySelector(ctx, "orders.dlq", map[string]string{"origin = 'test' OR 1": "1"}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("GetMessagesBySelector() error = %v, want a header name that isn't an identifier refused as a bad request", err)
+	}
+}
+
+func TestJolokiaError(t *testing.T) {
+	tests := []struct {
+		err  *jolokiaError
+		want error
+	}{
+		{&jolokiaError{Status: http.StatusForbidden}, ErrUnauthorized},
+		{&jolokiaError{Status: http.StatusNotFound}, ErrQueueNotFound},
+		{&jolokiaError{Status: http.StatusBadRequest}, ErrBadRequest},
+		{&jolokiaError{Status: http.StatusInternalServerError, Type: "javax.jms.InvalidSelectorException"}, ErrBadRequest},
+		{&jolokiaError{Status: http.StatusInternalServerError, Type: "java.lang.NullPointerException"}, nil},
+	}
+	for _, tt := range tests {
+		for _, kind := range []error{ErrUnauthorized, ErrQueueNotFound, ErrBadRequest} {
+			if got := errors.Is(fmt.Errorf("wrapped: %w", tt.err), kind); got != (kind == tt.want) {
+				t.Errorf("errors.Is(%+v, %v) = %v", tt.err, kind, got)
+			}
+		}
+	}
+}
+
+func TestConvertActiveMQCompositeMessage(t *testing.T) {
+	bytesMessage := convertActiveMQCompositeMessage(map[string]interface{}{
+		"JMSMessageID": "ID:1",
+		"JMSTimestamp": float64(1585742400000),
+		"BodyPreview":  []interface{}{float64('h'), float64('i'), float64(-61), float64(-87)},
+	})
+	if bytesMessage.Body != "hié" || !bytesMessage.Timestamp.Equal(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("convertActiveMQCompositeMessage() of a bytes message = %v", bytesMessage)
+	}
+
+	mapMessage := convertActiveMQCompositeMessage(map[string]interface{}{
+		"JMSMessageID": "ID:2",
+		"ContentMap":   map[string]interface{}{"orderId": "1001"},
+	})
+	if mapMessage.Body != `{"orderId":"1001"}` {
+		t.Errorf("convertActiveMQCompositeMessage() of a map message = %v", mapMessage)
+	}
+}
+
+func TestNewActiveMQJolokiaAdapter_ConsoleDown(t *testing.T) {
+	_, server := newFakeActiveMQJolokia(t)
+	server.Close()
+
+	adapter, err := NewActiveMQJolokiaAdapter(context.Background(), server.URL, "admin", "admin")
+	if err != nil {
+		t.Fatalf("NewActiveMQJolokiaAdapter() with the console down error = %v", err)
+	}
+	if _, err := adapter.GetAllQueues(context.Background()); !errors.Is(err, ErrBrokerUnavailable) {
+		t.Errorf("GetAllQueues() with the console down error = %v, want ErrBrokerUnavailable", err)
+	}
+}
+
+func TestNewActiveMQJolokiaAdapter_BadCredentials(t *testing.T) {
+	_, server := newFakeActiveMQJolokia(t)
+	if _, err := NewActiveMQJolokiaAdapter(context.Background(), server.URL, "admin", "wrong"); err == nil {
+		t.Errorf("NewActiveMQJolokiaAdapter() with bad credentials should fail")
+	}
+}
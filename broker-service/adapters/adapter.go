@@ -8,20 +8,141 @@ import (
 
 type Adapter interface {
 	GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error)
+	// GetMessages returns a page of the queue's messages, in the order GetAllMessages lists them
+	GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error)
 	GetAllQueues(ctx context.Context) ([]Queue, error)
-	Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error
+	// Move moves the messages to toQueue, with a result for each of messageIDs in the same order
+	Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult
 	MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error
 	Purge(ctx context.Context, queueName string) error
 	DeleteOne(ctx context.Context, queueName string, messageID string) error
-	DeleteMany(ctx context.Context, queueName string, messageIDs []string) []error
+	// DeleteMany deletes the messages, with a result for each of messageIDs in the same order
+	DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult
 }
 
+// Redriver is implemented by adapters whose broker knows which queue dead-lettered messages came from, so they
+// can be sent back without the caller naming the source queue.
+type Redriver interface {
+	// Redrive moves the given messages (or every message when messageIDs is empty) from a dead-letter queue back
+	// to the queue they were dead-lettered from
+	Redrive(ctx context.Context, deadLetterQueue string, messageIDs []string) []structs.MessageResult
+}
+
+// RangeBrowser is implemented by adapters whose broker keeps messages after they are read, so a queue can be
+// browsed from somewhere other than its head.
+type RangeBrowser interface {
+	// GetMessagesInRange returns the messages between the from and to positions, whose format the adapter defines
+	GetMessagesInRange(ctx context.Context, queueName string, from string, to string) ([]structs.StandardMessage, error)
+}
+
+// Copier is implemented by adapters whose broker can copy a message to another queue, leaving the original.
+type Copier interface {
+	// Copy puts a copy of each message on toQueue
+	Copy(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult
+}
+
+// MessageGetter is implemented by adapters whose broker can look up a single message by its ID.
+type MessageGetter interface {
+	// GetMessage returns the message with the given ID
+	GetMessage(ctx context.Context, queueName string, messageID string) (structs.StandardMessage, error)
+}
+
+// SelectorBrowser is implemented by adapters whose broker can pick out messages by their headers, so messages
+// that don't match are never received.
+type SelectorBrowser interface {
+	// GetMessagesBySelector returns the queue's messages whose headers hold exactly the given values
+	GetMessagesBySelector(ctx context.Context, queueName string, headers map[string]string) ([]structs.StandardMessage, error)
+}
+
+// Filterer is implemented by adapters whose broker can apply some of a filter itself, so messages that don't match
+// are never read.
+type Filterer interface {
+	// GetFilteredMessages returns the queue's messages that match the filter
+	GetFilteredMessages(ctx context.Context, queueName string, filter MessageFilter) ([]structs.StandardMessage, error)
+}
+
+// ConnectionReporter is implemented by adapters that hold a connection to their broker, reconnecting it when it
+// drops.
+type ConnectionReporter interface {
+	// ConnectionStatus returns the state of the adapter's connection
+	ConnectionStatus() ConnectionStatus
+}
+
+// Journaler is implemented by adapters that keep a journal of their moves, so the moves a crash interrupts can be
+// finished when the service starts again.
+type Journaler interface {
+	// ReplayJournal finishes the moves the journal holds
+	ReplayJournal(ctx context.Context) []error
+	// JournalEntries returns the moves the journal holds that aren't finished
+	JournalEntries() []JournalEntry
+}
+
+// PageRequest asks for a page of a queue's messages
+type PageRequest struct {
+	// Limit is the most messages the page holds, DefaultPageLimit when it is zero and never more than MaxPageLimit
+	Limit int
+	// Cursor is the NextCursor of the previous page, or empty for the first page
+	Cursor string
+}
+
+// MessagePage is a page of a queue's messages
+type MessagePage struct {
+	Messages []structs.StandardMessage
+	// NextCursor asks for the following page, and is empty on the last page
+	NextCursor string
+}
+
+// CapabilityReporter is implemented by adapters whose broker can't do everything the Adapter interface offers, or
+// can do more than their other interfaces say.
+type CapabilityReporter interface {
+	// Capabilities returns what the broker supports
+	Capabilities() Capabilities
+}
+
+// Capabilities tells the UI what a broker supports, so it only offers what will work
+type Capabilities struct {
+	GetMessage bool
+	Move       bool
+	// MoveScope limits where messages can be moved, e.g. vhost when they can only move within the broker's virtual host
+	MoveScope string `json:",omitempty"`
+	Copy      bool
+	Delete    bool
+	Purge     bool
+	Redrive   bool
+	// Selectors is set when messages can be browsed by header selector
+	Selectors bool
+	// Filters are the filter criteria the broker applies itself, the service applying the rest
+	Filters []string
+	// Paging are the ways the broker's queues can be paged, PagingOffset, PagingPosition or PagingRange
+	Paging []string
+	// Journal is set when the broker's moves are journaled, so the journal can be listed
+	Journal bool
+}
+
+const (
+	// PagingOffset pages by position in the queue, with limit and cursor
+	PagingOffset = "offset"
+	// PagingPosition pages from a position the broker keeps, with limit and cursor, so pages stay put as messages
+	// are removed
+	PagingPosition = "position"
+	// PagingRange browses between from and to positions
+	PagingRange = "range"
+)
+
+// Filter criteria a broker can apply itself
+const (
+	FilterMessageID     = "messageId"
+	FilterCorrelationID = "correlationId"
+	FilterDate          = "date"
+)
+
 type Queue struct {
 	Name string
 	Info map[string]string
 }
 
 type Broker struct {
-	Name string
-	Info map[string]string
+	Name         string
+	Info         map[string]string
+	Capabilities Capabilities
 }
@@ -0,0 +1,334 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// adapterTestHarness gives the shared adapter tests a broker to work on
+type adapterTestHarness struct {
+	adapter Adapter
+	// newQueue creates an empty queue and returns its name
+	newQueue func(t *testing.T) string
+	// send puts a message with each body on the queue, in order
+	send func(t *testing.T, queueName string, bodies ...string)
+	// noTimestamps is set for brokers that don't record when a message was sent
+	noTimestamps bool
+}
+
+// testAdapter checks an adapter against the behaviour every adapter should share: messages are listed in the
+// order they were sent and browsing doesn't remove them, deletes and moves only touch the given messages,
+// unknown message IDs are ErrMessageNotFound errors and purge empties the queue.
+func testAdapter(t *testing.T, harness adapterTestHarness) {
+	ctx := context.Background()
+	adapter := harness.adapter
+
+	getBodies := func(t *testing.T, queueName string) ([]string, []structs.StandardMessage) {
+		t.Helper()
+		messages, err := adapter.GetAllMessages(ctx, queueName)
+		if err != nil {
+			t.Fatalf("GetAllMessages(%s) error = %v", queueName, err)
+		}
+		var bodies []string
+		for _, message := range messages {
+			bodies = append(bodies, message.Body)
+		}
+		return bodies, messages
+	}
+
+	wantBodies := func(t *testing.T, queueName string, want ...string) []structs.StandardMessage {
+		t.Helper()
+		bodies, messages := getBodies(t, queueName)
+		if strings.Join(bodies, ",") != strings.Join(want, ",") {
+			t.Fatalf("messages on %s = %v, want %v", queueName, bodies, want)
+		}
+		return messages
+	}
+
+	t.Run("GetAllQueues", func(t *testing.T) {
+		queueName := harness.newQueue(t)
+		harness.send(t, queueName, "one", "two")
+
+		queues, err := adapter.GetAllQueues(ctx)
+		if err != nil {
+			t.Fatalf("GetAllQueues() error = %v", err)
+		}
+		for _, queue := range queues {
+			if queue.Name == queueName {
+				if queue.Info["Size"] != "2" {
+					t.Errorf("GetAllQueues() %s size = %s, want 2", queueName, queue.Info["Size"])
+				}
+				return
+			}
+		}
+		t.Errorf("GetAllQueues() = %v, missing %s", queues, queueName)
+	})
+
+	t.Run("GetAllMessages", func(t *testing.T) {
+		queueName := harness.newQueue(t)
+		wantBodies(t, queueName)
+
+		harness.send(t, queueName, "one", "two", "three")
+		messages := wantBodies(t, queueName, "one", "two", "three")
+
+		seen := make(map[string]bool)
+		for _, message := range messages {
+			if message.MessageID == "" || seen[message.MessageID] {
+				t.Errorf("GetAllMessages() message ID %q is empty or repeated", message.MessageID)
+			}
+			seen[message.MessageID] = true
+			if message.Timestamp.IsZero() && !harness.noTimestamps {
+				t.Errorf("GetAllMessages() message %s has no timestamp", message.MessageID)
+			}
+		}
+
+		// browsing again finds the same messages
+		wantBodies(t, queueName, "one", "two", "three")
+	})
+
+	t.Run("GetMessages", func(t *testing.T) {
+		queueName := harness.newQueue(t)
+		page, err := adapter.GetMessages(ctx, queueName, PageRequest{Limit: 2})
+		if err != nil || len(page.Messages) != 0 || page.NextCursor != "" {
+			t.Fatalf("GetMessages() of an empty queue = %v, %v", page, err)
+		}
+
+		harness.send(t, queueName, "one", "two", "three", "four", "five")
+		all := wantBodies(t, queueName, "one", "two", "three", "four", "five")
+
+		var pages [][]string
+		request := PageRequest{Limit: 2}
+		for {
+			page, err := adapter.GetMessages(ctx, queueName, request)
+			if err != nil {
+				t.Fatalf("GetMessages(%v) error = %v", request, err)
+			}
+			var bodies []string
+			for _, message := range page.Messages {
+				if message.MessageID != all[len(pages)*2+len(bodies)].MessageID {
+					t.Errorf("GetMessages() message %s has a different ID than GetAllMessages() gave it", message.MessageID)
+				}
+				bodies = append(bodies, message.Body)
+			}
+			pages = append(pages, bodies)
+			if page.NextCursor == "" || len(pages) > 3 {
+				break
+			}
+			request.Cursor = page.NextCursor
+		}
+		if got := fmt.Sprint(pages); got != "[[one two] [three four] [five]]" {
+			t.Errorf("GetMessages() pages = %s, want [[one two] [three four] [five]]", got)
+		}
+
+		// paging doesn't remove anything
+		wantBodies(t, queueName, "one", "two", "three", "four", "five")
+	})
+
+	t.Run("DeleteOne", func(t *testing.T) {
+		queueName := harness.newQueue(t)
+		harness.send(t, queueName, "one", "two", "three")
+		messages := wantBodies(t, queueName, "one", "two", "three")
+
+		if err := adapter.DeleteOne(ctx, queueName, messages[1].MessageID); err != nil {
+			t.Fatalf("DeleteOne() error = %v", err)
+		}
+		wantBodies(t, queueName, "one", "three")
+
+		if err := adapter.DeleteOne(ctx, queueName, messages[1].MessageID); err == nil {
+			t.Errorf("DeleteOne() of a deleted message should fail")
+		}
+	})
+
+	t.Run("DeleteMany", func(t *testing.T) {
+		queueName := harness.newQueue(t)
+		harness.send(t, queueName, "one", "two", "three", "four")
+		messages := wantBodies(t, queueName, "one", "two", "three", "four")
+
+		messageIDs := []string{messages[0].MessageID, messages[2].MessageID, "unknown"}
+		results := adapter.DeleteMany(ctx, queueName, messageIDs)
+		wantResults(t, "DeleteMany()", results, messageIDs, structs.MessageDeleted, structs.MessageDeleted, structs.MessageNotFound)
+		wantBodies(t, queueName, "two", "four")
+	})
+
+	t.Run("MoveOne", func(t *testing.T) {
+		fromQueue := harness.newQueue(t)
+		toQueue := harness.newQueue(t)
+		harness.send(t, fromQueue, "one", "two")
+		messages := wantBodies(t, fromQueue, "one", "two")
+
+		if err := adapter.MoveOne(ctx, fromQueue, toQueue, messages[0].MessageID); err != nil {
+			t.Fatalf("MoveOne() error = %v", err)
+		}
+		wantBodies(t, fromQueue, "two")
+		wantBodies(t, toQueue, "one")
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		fromQueue := harness.newQueue(t)
+		toQueue := harness.newQueue(t)
+		harness.send(t, fromQueue, "one", "two", "three")
+		harness.send(t, toQueue, "zero")
+		messages := wantBodies(t, fromQueue, "one", "two", "three")
+
+		messageIDs := []string{messages[0].MessageID, messages[2].MessageID, "unknown"}
+		results := adapter.Move(ctx, fromQueue, toQueue, messageIDs)
+		wantResults(t, "Move()", results, messageIDs, structs.MessageMoved, structs.MessageMoved, structs.MessageNotFound)
+		wantBodies(t, fromQueue, "two")
+		wantBodies(t, toQueue, "zero", "one", "three")
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		queueName := harness.newQueue(t)
+		otherQueue := harness.newQueue(t)
+		harness.send(t, queueName, "one", "two")
+		harness.send(t, otherQueue, "other")
+
+		if err := adapter.Purge(ctx, queueName); err != nil {
+			t.Fatalf("Purge() error = %v", err)
+		}
+		wantBodies(t, queueName)
+		wantBodies(t, otherQueue, "other")
+	})
+}
+
+// wantResults checks that an operation on messageIDs gave one result for each, in order, with the statuses
+func wantResults(t *testing.T, operation string, results []structs.MessageResult, messageIDs []string, statuses ...structs.MessageStatus) {
+	t.Helper()
+	if len(results) != len(messageIDs) {
+		t.Fatalf("%s results = %+v, want one for each of %v", operation, results, messageIDs)
+	}
+	for i, result := range results {
+		if result.MessageID != messageIDs[i] || result.Status != statuses[i] {
+			t.Errorf("%s result %d = %+v, want %s for %s", operation, i, result, statuses[i], messageIDs[i])
+		}
+		if (result.Status == structs.MessageNotFound) != errors.Is(result.Err, ErrMessageNotFound) {
+			t.Errorf("%s result %d error = %v, doesn't match its status %s", operation, i, result.Err, result.Status)
+		}
+	}
+}
+
+func TestMessageFilter(t *testing.T) {
+	message := structs.StandardMessage{
+		MessageID: "ID:broker-1:1:1:1:7",
+		Timestamp: time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC),
+		Headers:   map[string]string{"Correlation ID": "order-1001", "error": "timeout"},
+		Body:      `{"orderId":1001}`,
+	}
+
+	tests := []struct {
+		name   string
+		filter MessageFilter
+		want   bool
+	}{
+		{"empty", MessageFilter{}, true},
+		{"message ID", MessageFilter{MessageID: ":1:7"}, true},
+		{"other message ID", MessageFilter{MessageID: ":1:8"}, false},
+		{"correlation ID", MessageFilter{CorrelationID: "1001"}, true},
+		{"other correlation ID", MessageFilter{CorrelationID: "1002"}, false},
+		{"body", MessageFilter{Body: "orderId"}, true},
+		{"dates", MessageFilter{FromDate: message.Timestamp, ToDate: message.Timestamp}, true},
+		{"too late", MessageFilter{FromDate: message.Timestamp.Add(time.Second)}, false},
+		{"too early", MessageFilter{ToDate: message.Timestamp.Add(-time.Second)}, false},
+		{"header", MessageFilter{Headers: map[string]string{"error": "time"}}, true},
+		{"missing header", MessageFilter{Headers: map[string]string{"reason": ""}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(message); got != tt.want {
+			t.Errorf("%s: Matches() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	memory, _ := NewMemoryAdapter(context.Background(), "")
+	capabilities := GetCapabilities(memory)
+	if !capabilities.Move || !capabilities.Delete || !capabilities.Purge || capabilities.Copy || capabilities.Selectors ||
+		fmt.Sprint(capabilities.Paging) != "[offset]" {
+		t.Errorf("GetCapabilities() of the memory adapter = %+v, want the Adapter interface paged by offset", capabilities)
+	}
+
+	capabilities = GetCapabilities(&KafkaAdapter{})
+	if capabilities.Delete || !capabilities.Move || fmt.Sprint(capabilities.Paging) != "[position range]" ||
+		fmt.Sprint(capabilities.Filters) != "[date]" {
+		t.Errorf("GetCapabilities() of the Kafka adapter = %+v, want no deleting", capabilities)
+	}
+
+	amqpAdapter := &AMQPAdapter{}
+	if capabilities := GetCapabilities(amqpAdapter); capabilities.Selectors || len(capabilities.Filters) != 0 || !capabilities.GetMessage {
+		t.Errorf("GetCapabilities() of an AMQP adapter without selectors = %+v", capabilities)
+	}
+	amqpAdapter.UseSelectors(true)
+	if capabilities := GetCapabilities(amqpAdapter); !capabilities.Selectors || len(capabilities.Filters) != 2 {
+		t.Errorf("GetCapabilities() of an AMQP adapter with selectors = %+v", capabilities)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	refused := errors.New("connection refused")
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"queue not found", queueNotFound("orders"), ErrQueueNotFound},
+		{"message not found", messageNotFound("ID:1"), ErrMessageNotFound},
+		{"wrapped", fmt.Errorf("moving: %w", messageNotFound("ID:1")), ErrMessageNotFound},
+		{"unavailable", unavailable(refused), ErrBrokerUnavailable},
+		{"marked once", unauthorized(unavailable(refused)), ErrBrokerUnavailable},
+		{"401", httpStatusError("console", &http.Response{StatusCode: 401, Status: "401 Unauthorized"}, "orders"), ErrUnauthorized},
+		{"404 for a queue", httpStatusError("console", &http.Response{StatusCode: 404, Status: "404 Not Found"}, "orders"), ErrQueueNotFound},
+		{"503", httpStatusError("console", &http.Response{StatusCode: 503, Status: "503 Service Unavailable"}, ""), ErrBrokerUnavailable},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: %v is not %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	if err := httpStatusError("console", &http.Response{StatusCode: 404, Status: "404 Not Found"}, ""); isMarked(err) {
+		t.Errorf("a 404 for no queue is %v, want it unmarked", err)
+	}
+	if got := messageNotFound("ID:1").Error(); got != "Did not find message ID:1" {
+		t.Errorf("messageNotFound().Error() = %q", got)
+	}
+	if !errors.Is(unavailable(refused), refused) {
+		t.Errorf("unavailable() doesn't wrap the error it marks")
+	}
+}
+
+func TestMessageResults(t *testing.T) {
+	refused := unavailable(errors.New("connection refused"))
+	messageIDs := []string{"ID:1", "ID:2", "ID:3"}
+
+	results := messageResults(messageIDs, structs.MessageMoved, []error{
+		messageNotFound("ID:2"),
+		forMessage("ID:3", errors.New("rejected")),
+	})
+	wantResults(t, "messageResults()", results, messageIDs, structs.MessageMoved, structs.MessageNotFound, structs.MessageFailed)
+	if results[2].Error != "rejected" {
+		t.Errorf("messageResults() error of ID:3 = %q, want its own", results[2].Error)
+	}
+
+	results = messageResults(messageIDs, structs.MessageDeleted, []error{messageNotFound("ID:1"), refused})
+	wantResults(t, "messageResults()", results, messageIDs, structs.MessageNotFound, structs.MessageFailed, structs.MessageFailed)
+	if !errors.Is(results[1].Err, ErrBrokerUnavailable) {
+		t.Errorf("messageResults() error of ID:2 = %v, want the general error", results[1].Err)
+	}
+
+	if err := resultError(messageResults(messageIDs[:1], structs.MessageDeleted, nil)); err != nil {
+		t.Errorf("resultError() of a deleted message = %v", err)
+	}
+
+	// a whole queue operation that failed before finding any message still reports why
+	results = messageResults(nil, structs.MessageRedriven, []error{refused})
+	if len(results) != 1 || results[0].MessageID != "" || results[0].Status != structs.MessageFailed || results[0].Err != refused {
+		t.Errorf("messageResults() of no messages = %+v, want the general error", results)
+	}
+}
@@ -0,0 +1,563 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Azure/go-amqp"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+const (
+	// amqpReceiveTimeout is how long a receive waits for the next message before deciding the queue is empty
+	amqpReceiveTimeout = 1 * time.Second
+	// amqpMaxReceiveErrors is how many failed receives a browse, move or delete puts up with before giving up
+	amqpMaxReceiveErrors = 10
+)
+
+// QueueDiscoverer finds the queues of an AMQP 1.0 broker.  AMQP 1.0 has no way of listing queues, so each
+// broker's management API does it.
+type QueueDiscoverer interface {
+	// GetAllQueues returns the broker's queues, with their sizes in Info["Size"] when the broker knows them
+	GetAllQueues(ctx context.Context) ([]Queue, error)
+	// QueueSize returns how many messages are on the queue, or -1 when the broker can't tell
+	QueueSize(ctx context.Context, queueName string) (int, error)
+}
+
+// AMQPAdapter manages the queues of an AMQP 1.0 broker, such as ActiveMQ, Artemis or Qpid.  Messages are
+// browsed by receiving and releasing them, and moved or deleted by accepting them.  Queues are found by the
+// adapter's QueueDiscoverer.  The connection is supervised, so it fails over to the next broker URL and
+// reconnects after an outage.  Unless selectors are turned off, the broker picks out the messages to get, move or
+// delete with a JMS selector, so the rest of the queue is never received.
+type AMQPAdapter struct {
+	supervisor *connectionSupervisor
+	discoverer QueueDiscoverer
+	selectors  bool
+}
+
+// Returns an AMQP 1.0 adapter:
+// brokerUrl: comma separated broker URLs, tried in order until one connects
+// userName, passwd: SASL PLAIN credentials
+// useTLS: connect using TLS
+// discoverer: finds the broker's queues
+// The adapter is returned even when no broker URL connects, and connects once the broker comes up.
+func NewAMQPAdapter(ctx context.Context, brokerUrl, userName, passwd string, useTLS bool, discoverer QueueDiscoverer) (*AMQPAdapter, error) {
+
+	dial := func(brokerUrl string) (io.Closer, <-chan error, error) {
+		// the client doesn't report a dropped connection, operations report it when they fail
+		if useTLS {
+			client, err := amqp.Dial(brokerUrl, amqp.ConnSASLPlain(userName, passwd), amqp.ConnTLS(true), amqp.ConnIdleTimeout(0))
+			return client, nil, err
+		}
+		client, err := amqp.Dial(brokerUrl, amqp.ConnSASLPlain(userName, passwd), amqp.ConnIdleTimeout(0))
+		return client, nil, err
+	}
+
+	return &AMQPAdapter{
+		supervisor: newConnectionSupervisor("AMQP 1.0 broker", strings.Split(brokerUrl, ","), dial),
+		discoverer: discoverer,
+		selectors:  true,
+	}, nil
+}
+
+// UseSelectors says whether the broker understands JMS selectors on AMQP links, as ActiveMQ, Artemis and Qpid
+// Broker-J do.  Without them messages are found by receiving the whole queue.
+func (a *AMQPAdapter) UseSelectors(useSelectors bool) {
+	a.selectors = useSelectors
+}
+
+// Capabilities leaves out browsing by selector and the filters selectors apply when they are turned off
+func (a *AMQPAdapter) Capabilities() Capabilities {
+	capabilities := defaultCapabilities(a)
+	capabilities.Selectors = a.selectors
+	if a.selectors {
+		capabilities.Filters = []string{FilterCorrelationID, FilterDate}
+	}
+	return capabilities
+}
+
+func (a *AMQPAdapter) ConnectionStatus() ConnectionStatus {
+	return a.supervisor.ConnectionStatus()
+}
+
+func (a *AMQPAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	return a.discoverer.GetAllQueues(ctx)
+}
+
+func (a *AMQPAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	return a.browse(ctx, queueName, "", a.queueSize(ctx, queueName))
+}
+
+// GetMessages pages the queue by offset.  Receiving can only start at the head of the queue, so everything up to the
+// end of the page is received and released.
+func (a *AMQPAdapter) GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error) {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	offset, err := pageOffset(page)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	limit := pageLimit(page)
+
+	receive := offset + limit + 1
+	if size := a.queueSize(ctx, queueName); size >= 0 && size < receive {
+		receive = size
+	}
+	messages, err := a.browse(ctx, queueName, "", receive)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	return offsetPage(messages, offset, limit), nil
+}
+
+// GetMessage has the broker select the message by its ID, falling back to browsing the whole queue without selectors
+func (a *AMQPAdapter) GetMessage(ctx context.Context, queueName string, messageID string) (structs.StandardMessage, error) {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	var messages []structs.StandardMessage
+	var err error
+	if a.selectors {
+		messages, err = a.browse(ctx, queueName, messageIDSelector([]string{messageID}), 1)
+	} else {
+		messages, err = a.browse(ctx, queueName, "", a.queueSize(ctx, queueName))
+	}
+	if err != nil {
+		return structs.StandardMessage{}, err
+	}
+
+	for _, message := range messages {
+		if message.MessageID == messageID {
+			return message, nil
+		}
+	}
+	return structs.StandardMessage{}, messageNotFound(messageID)
+}
+
+// GetFilteredMessages has the broker select messages by correlation ID and timestamp, and applies the rest of the
+// filter to what it receives.  Message IDs aren't selected on, as the broker may know a message by another
+// JMSMessageID than its AMQP message ID.
+func (a *AMQPAdapter) GetFilteredMessages(ctx context.Context, queueName string, filter MessageFilter) ([]structs.StandardMessage, error) {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	selector := ""
+	if a.selectors {
+		selector = filterSelector(filter, false)
+	}
+	messages, err := a.browse(ctx, queueName, selector, a.queueSize(ctx, queueName))
+	if err != nil {
+		return nil, err
+	}
+	return filter.Apply(messages), nil
+}
+
+func (a *AMQPAdapter) GetMessagesBySelector(ctx context.Context, queueName string, headers map[string]string) ([]structs.StandardMessage, error) {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	if !a.selectors {
+		return nil, notSupported(errors.New("selectors are turned off for this broker"))
+	}
+	selector, err := headerSelector(headers)
+	if err != nil {
+		return nil, err
+	}
+	return a.browse(ctx, queueName, selector, a.queueSize(ctx, queueName))
+}
+
+// browse receives up to limit of the messages matching the selector, or of every message when the selector is
+// empty, and releases them so they stay on the queue
+func (a *AMQPAdapter) browse(ctx context.Context, queueName string, selector string, limit int) ([]structs.StandardMessage, error) {
+	receiver, closeReceiver, err := a.getNewReceiver(ctx, queueName, selector)
+	if err != nil {
+		return nil, err
+	}
+	defer closeReceiver()
+
+	messages, err := a.receiveMessages(ctx, receiver, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// release the message (don't remove it from the queue)
+	for _, msg := range messages {
+		if err := msg.Release(); err != nil {
+			log.Printf("error trying to release message %s", err)
+		}
+	}
+
+	stdMsgs, _ := a.convertMessagesToStandardMessage(ctx, messages)
+
+	return stdMsgs, nil
+}
+
+func (a *AMQPAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, a.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (a *AMQPAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	fromQueue, _ = url.QueryUnescape(fromQueue)
+	toQueue, _ = url.QueryUnescape(toQueue)
+
+	sender, err, closeSession := a.getNewSender(ctx, toQueue)
+	if err != nil {
+		return []error{fmt.Errorf("Error initiating sender: %w", err)}
+	}
+	defer closeSession()
+	defer sender.Close(ctx)
+
+	return a.settleMessages(ctx, fromQueue, messageIDs, func(msgId string, msg *amqp.Message) error {
+		if err := sender.Send(ctx, msg); err != nil {
+			log.Printf("error trying to send message %s, error is %s", msgId, err)
+			return err
+		}
+		return nil
+	})
+}
+
+func (a *AMQPAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(a.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+func (a *AMQPAdapter) Purge(ctx context.Context, queueName string) error {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	receiver, closeReceiver, err := a.getNewReceiver(ctx, queueName, "")
+	if err != nil {
+		return err
+	}
+	defer closeReceiver()
+
+	messages, err := a.receiveMessages(ctx, receiver, a.queueSize(ctx, queueName))
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		if err := msg.Accept(); err != nil {
+			log.Printf("error trying to accept message %v, error is %s", msg.Properties.MessageID, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *AMQPAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return resultError(a.DeleteMany(ctx, queueName, []string{messageID}))
+}
+
+func (a *AMQPAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, a.deleteMany(ctx, queueName, messageIDs))
+}
+
+func (a *AMQPAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	queueName, _ = url.QueryUnescape(queueName)
+
+	return a.settleMessages(ctx, queueName, messageIDs, func(string, *amqp.Message) error {
+		return nil
+	})
+}
+
+// settleMessages accepts each of messageIDs once handle succeeds for it.  With selectors the broker sends only
+// those messages, and an ID the selector misses isn't on the queue.  Without them the queue is received and every
+// other message is released, so it stays on the queue.
+func (a *AMQPAdapter) settleMessages(ctx context.Context, queueName string, messageIDs []string,
+	handle func(msgId string, msg *amqp.Message) error) []error {
+
+	if !a.selectors {
+		return a.settleReceived(ctx, queueName, "", a.queueSize(ctx, queueName), messageIDs, handle)
+	}
+
+	var settleErrors []error
+	for start := 0; start < len(messageIDs); start += jmsSelectorBatchSize {
+		end := start + jmsSelectorBatchSize
+		if end > len(messageIDs) {
+			end = len(messageIDs)
+		}
+		batch := messageIDs[start:end]
+		settleErrors = append(settleErrors, a.settleReceived(ctx, queueName, messageIDSelector(batch), len(batch), batch, handle)...)
+	}
+	return settleErrors
+}
+
+// settleReceived receives up to limit messages matching the selector and, for each of messageIDs, accepts the
+// message once handle succeeds for it.  Every other message is released.
+func (a *AMQPAdapter) settleReceived(ctx context.Context, queueName string, selector string, limit int, messageIDs []string,
+	handle func(msgId string, msg *amqp.Message) error) []error {
+
+	var settleErrors []error
+
+	receiver, closeReceiver, err := a.getNewReceiver(ctx, queueName, selector)
+	if err != nil {
+		return forMessages(messageIDs, err)
+	}
+	defer closeReceiver()
+
+	received, err := a.receiveMessages(ctx, receiver, limit)
+	if err != nil {
+		return forMessages(messageIDs, err)
+	}
+
+	messages := make(map[string]*amqp.Message)
+	for _, msg := range received {
+		messages[fmt.Sprintf("%v", msg.Properties.MessageID)] = msg
+	}
+
+	for _, msgId := range messageIDs {
+		msg, ok := messages[msgId]
+		if !ok {
+			settleErrors = append(settleErrors, messageNotFound(msgId))
+			continue
+		}
+		if err := handle(msgId, msg); err != nil {
+			settleErrors = append(settleErrors, forMessage(msgId, err))
+			continue
+		}
+		if err := msg.Accept(); err != nil {
+			log.Printf("error trying to accept message %s, error is %s", msgId, err)
+			settleErrors = append(settleErrors, forMessage(msgId, err))
+		}
+		delete(messages, msgId)
+	}
+
+	for _, msg := range messages {
+		// release the message if it wasn't the one we were looking for
+		if err := msg.Release(); err != nil {
+			log.Printf("error trying to release message %s", err)
+		}
+	}
+
+	return settleErrors
+}
+
+// receiveMessages receives up to limit messages, or every message when limit is negative, stopping early once no
+// message arrives within amqpReceiveTimeout.  The caller settles the messages.
+func (a *AMQPAdapter) receiveMessages(ctx context.Context, receiver *amqp.Receiver, limit int) ([]*amqp.Message, error) {
+	var messages []*amqp.Message
+	numOfErrors := 0
+	for limit < 0 || len(messages) < limit {
+		ctxForReceive, cancelFunction := context.WithTimeout(ctx, amqpReceiveTimeout)
+		msg, err := receiver.Receive(ctxForReceive)
+		timedOut := ctxForReceive.Err() != nil
+		cancelFunction()
+		if timedOut {
+			break
+		}
+		if err != nil {
+			log.Printf("Unable to receive messages: %s", err)
+			numOfErrors++
+			if numOfErrors > amqpMaxReceiveErrors {
+				for _, msg := range messages {
+					msg.Release()
+				}
+				return nil, errors.New("unable to receive messages")
+			}
+			continue
+		}
+
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// queueSize asks the discoverer how many messages to receive, falling back to receiving until the queue runs dry
+func (a *AMQPAdapter) queueSize(ctx context.Context, queueName string) int {
+	size, err := a.discoverer.QueueSize(ctx, queueName)
+	if err != nil {
+		log.Printf("unable to get the size of queue %s, receiving until it is empty: %s", queueName, err)
+		return -1
+	}
+	return size
+}
+
+func (a *AMQPAdapter) getSession(ctx context.Context) (*amqp.Session, error, func()) {
+	conn, err := a.supervisor.get()
+	if err != nil {
+		return nil, err, nil
+	}
+	session, err := conn.(*amqp.Client).NewSession()
+
+	if err != nil {
+		log.Printf("error attempting to start new session %v", err)
+		// a session can only fail to start on a broken connection
+		a.supervisor.failed(conn, err)
+		return nil, unavailable(fmt.Errorf("Get session failed, connection to client failed: %w", err)), nil
+	}
+
+	closeSession := func() {
+		ctx2, cancel := context.WithTimeout(ctx, 10*time.Second)
+		log.Println("Closing session")
+		_ = session.Close(ctx2)
+		cancel()
+	}
+
+	return session, nil, closeSession
+}
+
+// getNewReceiver creates a new session on the active client and then a new receiver for the queue on that
+// session, receiving only the messages matching the selector unless it is empty.  The returned function closes both.
+func (a *AMQPAdapter) getNewReceiver(ctx context.Context, queueName string, selector string) (*amqp.Receiver, func(), error) {
+	session, err, closeSession := a.getSession(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Get new session failed: %w", err)
+	}
+
+	linkOptions := []amqp.LinkOption{
+		amqp.LinkSourceAddress(queueName),
+		amqp.LinkCredit(10),
+	}
+	if selector != "" {
+		linkOptions = append(linkOptions, amqp.LinkSelectorFilter(selector))
+	}
+	receiver, err := session.NewReceiver(linkOptions...)
+	if err != nil {
+		closeSession()
+		log.Printf("unable to get new receiver, error is %s", err.Error())
+		return nil, nil, amqpLinkError(queueName, fmt.Errorf("getNewReceiver failed: %w", err))
+	}
+
+	closeReceiver := func() {
+		closeContext, closeCancel := context.WithTimeout(ctx, 10*time.Second)
+		err := receiver.Close(closeContext)
+		closeCancel()
+		if err != nil {
+			log.Printf("Unable to close the receiver: %s", err)
+		}
+		closeSession()
+	}
+
+	return receiver, closeReceiver, nil
+}
+
+// getNewSender creates a new session on the active client and then a new sender on that session
+func (a *AMQPAdapter) getNewSender(ctx context.Context, destination string) (*amqp.Sender, error, func()) {
+	session, err, closeSession := a.getSession(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("amqpAdapter getNewSender - new session failed: %w", err), nil
+	}
+	var sender *amqp.Sender
+	sender, err = session.NewSender(
+		amqp.LinkTargetAddress(destination),
+	)
+	if err != nil {
+		closeSession()
+		return nil, amqpLinkError(destination, fmt.Errorf("amqpAdapter getNewSender - get sender failed: %w", err)), nil
+	}
+	log.Println("amqpAdapter getNewSender - sender succeeded")
+	return sender, nil, closeSession
+}
+
+// amqpLinkError marks the error a link failed to attach with by the condition the broker detached it with
+func amqpLinkError(address string, err error) error {
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) {
+		switch amqpErr.Condition {
+		case amqp.ErrorNotFound:
+			return queueNotFound(address)
+		case amqp.ErrorUnauthorizedAccess:
+			return unauthorized(err)
+		}
+	}
+	return err
+}
+
+func (a *AMQPAdapter) convertMessagesToStandardMessage(
+	ctx context.Context, messages []*amqp.Message) ([]structs.StandardMessage, []error) {
+
+	stdMessages := []structs.StandardMessage{}
+
+	for _, msg := range messages {
+
+		headers := make(map[string]string)
+
+		var body string
+		getDataBody := msg.GetData()
+
+		if getDataBody == nil {
+			var ok bool
+			body, ok = msg.Value.(string)
+			if !ok {
+				body = "<unknown body structure>"
+			}
+		} else {
+			body = string(getDataBody)
+		}
+
+		messageId := fmt.Sprintf("%v", msg.Properties.MessageID)
+
+		headers["Correlation ID"] = fmt.Sprintf("%v", msg.Properties.CorrelationID)
+		headers["Durable"] = fmt.Sprintf("%v", msg.Header.Durable)
+		headers["Priority"] = fmt.Sprintf("%v", msg.Header.Priority)
+		headers["TTL"] = fmt.Sprintf("%v", msg.Header.TTL)
+		headers["First Acquirer"] = fmt.Sprintf("%v", msg.Header.FirstAcquirer)
+		headers["Delivery Count"] = fmt.Sprintf("%v", msg.Header.DeliveryCount)
+		headers["User ID"] = fmt.Sprintf("%v", msg.Properties.UserID)
+		headers["Destination"] = fmt.Sprintf("%v", msg.Properties.To)
+		headers["Subject"] = fmt.Sprintf("%v", msg.Properties.Subject)
+		headers["Reply To"] = fmt.Sprintf("%v", msg.Properties.ReplyTo)
+		headers["Type"] = fmt.Sprintf("%v", msg.Properties.ContentType)
+		headers["Group ID"] = fmt.Sprintf("%v", msg.Properties.GroupID)
+		headers["Group Sequence"] = fmt.Sprintf("%v", msg.Properties.GroupSequence)
+
+		for key, val := range msg.ApplicationProperties {
+			headers[key] = fmt.Sprintf("%v", val)
+		}
+
+		for key, val := range msg.Annotations {
+			keyStr := fmt.Sprintf("%v", key)
+			headers[keyStr] = fmt.Sprintf("%v", val)
+		}
+
+		for key, val := range msg.DeliveryAnnotations {
+			keyStr := fmt.Sprintf("%v", key)
+			headers[keyStr] = fmt.Sprintf("%v", val)
+		}
+
+		stdMsg := structs.StandardMessage{
+			MessageID: messageId,
+			Timestamp: msg.Properties.CreationTime,
+			Headers:   headers,
+			Body:      body,
+		}
+
+		stdMessages = append(stdMessages, stdMsg)
+	}
+
+	return stdMessages, nil
+}
+
+// StaticQueueDiscoverer lists a fixed set of queues, for brokers without a management API.  Their sizes are
+// unknown.
+type StaticQueueDiscoverer struct {
+	queues []string
+}
+
+// Returns a discoverer listing the given comma separated queues
+func NewStaticQueueDiscoverer(queues string) *StaticQueueDiscoverer {
+	discoverer := &StaticQueueDiscoverer{}
+	for _, queue := range strings.Split(queues, ",") {
+		if queue = strings.TrimSpace(queue); queue != "" {
+			discoverer.queues = append(discoverer.queues, queue)
+		}
+	}
+	return discoverer
+}
+
+func (s *StaticQueueDiscoverer) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	queues := []Queue{}
+	for _, queue := range s.queues {
+		queues = append(queues, Queue{Name: queue, Info: map[string]string{}})
+	}
+	return queues, nil
+}
+
+func (s *StaticQueueDiscoverer) QueueSize(ctx context.Context, queueName string) (int, error) {
+	return -1, nil
+}
@@ -0,0 +1,185 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActiveMQConsoleDiscoverer(t *testing.T) {
+	console := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/xml/queues.jsp":
+			fmt.Fprint(w, `<queues><queue name="orders.dlq"><stats size="2" consumerCount="0" enqueueCount="2" dequeueCount="0"/></queue><queue name="emails"><stats size="0"/></queue></queues>`)
+		case "/admin/queueBrowse/orders.dlq":
+			fmt.Fprint(w, `<rss version="2.0"><channel><item><title>1</title></item><item><title>2</title></item></channel></rss>`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer console.Close()
+	ctx := context.Background()
+
+	// the first console is down, so the second one answers
+	discoverer := NewActiveMQConsoleDiscoverer("http://127.0.0.1:1,"+console.URL, "admin", "secret")
+
+	queues, err := discoverer.GetAllQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if len(queues) != 2 || queues[0].Name != "orders.dlq" || queues[0].Info["Size"] != "2" || queues[1].Name != "emails" {
+		t.Errorf("GetAllQueues() = %v", queues)
+	}
+
+	if size, err := discoverer.QueueSize(ctx, "orders.dlq"); err != nil || size != 2 {
+		t.Errorf("QueueSize() = %d, %v, want 2", size, err)
+	}
+	if _, err := discoverer.QueueSize(ctx, "missing"); err == nil {
+		t.Errorf("QueueSize() of a queue the console doesn't know should fail")
+	}
+
+	if _, err := NewActiveMQConsoleDiscoverer(console.URL, "admin", "wrong").GetAllQueues(ctx); err == nil {
+		t.Errorf("GetAllQueues() with bad credentials should fail")
+	}
+}
+
+func TestArtemisQueueDiscoverer(t *testing.T) {
+	var requests []jolokiaRequest
+	console := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" || r.URL.Path != artemisJolokiaPath {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("Origin") == "" {
+			fmt.Fprint(w, `{"status": 403, "error": "Origin null is not allowed to call this agent"}`)
+			return
+		}
+		var request jolokiaRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		switch {
+		case strings.HasSuffix(request.MBean, `queue=*`):
+			fmt.Fprint(w, `{"status": 200, "value": {
+				"org.apache.activemq.artemis:address=\"orders\",broker=\"0.0.0.0\",component=addresses,queue=\"orders.dlq\",routing-type=\"anycast\",subcomponent=queues": {"MessageCount": 3, "ConsumerCount": 1},
+				"org.apache.activemq.artemis:address=\"DLQ\",broker=\"0.0.0.0\",component=addresses,queue=\"DLQ\",routing-type=\"anycast\",subcomponent=queues": {"MessageCount": 0, "ConsumerCount": 0}
+			}}`)
+		case strings.HasSuffix(request.MBean, `queue="orders.dlq"`):
+			fmt.Fprint(w, `{"status": 200, "value": {
+				"org.apache.activemq.artemis:address=\"orders\",broker=\"0.0.0.0\",component=addresses,queue=\"orders.dlq\",routing-type=\"anycast\",subcomponent=queues": {"MessageCount": 3, "ConsumerCount": 1}
+			}}`)
+		default:
+			fmt.Fprint(w, `{"status": 404, "error": "No matching MBean found"}`)
+		}
+	}))
+	defer console.Close()
+	ctx := context.Background()
+
+	discoverer := NewArtemisQueueDiscoverer(console.URL+"/", "admin", "secret")
+
+	queues, err := discoverer.GetAllQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if len(queues) != 2 || queues[0].Name != "DLQ" || queues[1].Name != "orders.dlq" {
+		t.Fatalf("GetAllQueues() = %v", queues)
+	}
+	if info := queues[1].Info; info["Size"] != "3" || info["Consumers"] != "1" || info["Address"] != "orders" {
+		t.Errorf("GetAllQueues() orders.dlq info = %v", info)
+	}
+	if requests[0].Type != "read" || !strings.Contains(requests[0].MBean, `routing-type="anycast"`) {
+		t.Errorf("GetAllQueues() request = %v", requests[0])
+	}
+
+	if size, err := discoverer.QueueSize(ctx, "orders.dlq"); err != nil || size != 3 {
+		t.Errorf("QueueSize() = %d, %v, want 3", size, err)
+	}
+	if _, err := discoverer.QueueSize(ctx, "missing"); err == nil {
+		t.Errorf("QueueSize() of an unknown queue should fail")
+	}
+
+	if _, err := NewArtemisQueueDiscoverer(console.URL, "admin", "wrong").GetAllQueues(ctx); err == nil {
+		t.Errorf("GetAllQueues() with bad credentials should fail")
+	}
+}
+
+func TestStaticQueueDiscoverer(t *testing.T) {
+	discoverer := NewStaticQueueDiscoverer("orders.dlq, emails.dlq,")
+
+	queues, err := discoverer.GetAllQueues(context.Background())
+	if err != nil || len(queues) != 2 || queues[0].Name != "orders.dlq" || queues[1].Name != "emails.dlq" {
+		t.Errorf("GetAllQueues() = %v, %v", queues, err)
+	}
+	if size, err := discoverer.QueueSize(context.Background(), "orders.dlq"); err != nil || size != -1 {
+		t.Errorf("QueueSize() = %d, %v, want -1", size, err)
+	}
+}
+
+func TestParseObjectNameProperties(t *testing.T) {
+	properties := parseObjectNameProperties(`org.apache.activemq.artemis:address="a,b",broker="0.0.0.0",component=addresses,queue="say \"hi\"\\n"`)
+	if properties["address"] != "a,b" || properties["broker"] != "0.0.0.0" || properties["component"] != "addresses" || properties["queue"] != `say "hi"\n` {
+		t.Errorf("parseObjectNameProperties() = %v", properties)
+	}
+
+	name := "orders*\"dlq\"?\n"
+	quoted := quoteObjectNameValue(name)
+	if quoted != `"orders\*\"dlq\"\?\n"` {
+		t.Errorf("quoteObjectNameValue() = %s", quoted)
+	}
+	if properties := parseObjectNameProperties("domain:queue=" + quoteObjectNameValue(`a\b"c`)); properties["queue"] != `a\b"c` {
+		t.Errorf("parseObjectNameProperties(quoteObjectNameValue()) = %v", properties)
+	}
+}
+
+func TestMessageIDSelector(t *testing.T) {
+	if selector := messageIDSelector([]string{"ID:it's-1"}); selector != "JMSMessageID = 'ID:it''s-1'" {
+		t.Errorf("messageIDSelector() of one ID = %s", selector)
+	}
+	if selector := messageIDSelector([]string{"ID:1", "ID:2"}); selector != "JMSMessageID IN ('ID:1', 'ID:2')" {
+		t.Errorf("messageIDSelector() of two IDs = %s", selector)
+	}
+	if selector := messageIDSelector([]string{"abc"}); selector != "JMSMessageID IN ('abc', 'ID:AMQP_NO_PREFIX:abc')" {
+		t.Errorf("messageIDSelector() of an ID without the ID: prefix = %s", selector)
+	}
+}
+
+func TestHeaderSelector(t *testing.T) {
+	selector, err := headerSelector(map[string]string{"origin": "it's", "JMSType": "order"})
+	if err != nil || selector != "JMSType = 'order' AND origin = 'it''s'" {
+		t.Errorf("headerSelector() = %s, %v", selector, err)
+	}
+
+	for _, name := range []string{"", "1st", "Correlation ID", "a='b' OR c", "NOT"} {
+		if _, err := headerSelector(map[string]string{name: "value"}); err == nil {
+			t.Errorf("headerSelector() should refuse the header name %q", name)
+		}
+	}
+	if _, err := headerSelector(nil); err == nil {
+		t.Errorf("headerSelector() without headers should fail")
+	}
+}
+
+func TestFilterSelector(t *testing.T) {
+	filter := MessageFilter{
+		MessageID:     "ID:1",
+		CorrelationID: "50%_off",
+		FromDate:      time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+		Body:          "left to Matches",
+	}
+	want := `JMSMessageID LIKE '%ID:1%' ESCAPE '\' AND JMSCorrelationID LIKE '%50\%\_off%' ESCAPE '\' AND JMSTimestamp >= 1585699200000`
+	if selector := filterSelector(filter, true); selector != want {
+		t.Errorf("filterSelector() = %s, want %s", selector, want)
+	}
+	if selector := filterSelector(MessageFilter{MessageID: "ID:1", Body: "text"}, false); selector != "" {
+		t.Errorf("filterSelector() without message IDs = %s, want none", selector)
+	}
+}
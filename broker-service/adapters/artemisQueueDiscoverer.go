@@ -0,0 +1,85 @@
+package adapters
+
+import (
+	"context"
+	"sort"
+	"strconv"
+)
+
+// artemisJolokiaPath is where the Artemis web console serves its Jolokia management API
+const artemisJolokiaPath = "/console/jolokia"
+
+// ArtemisQueueDiscoverer finds the anycast queues of an ActiveMQ Artemis broker, and their sizes, through the
+// Jolokia management API of its web console
+type ArtemisQueueDiscoverer struct {
+	jolokia *jolokiaClient
+}
+
+type artemisQueueAttributes struct {
+	MessageCount  int64 `json:"MessageCount"`
+	ConsumerCount int64 `json:"ConsumerCount"`
+}
+
+// Returns an Artemis queue discoverer:
+// consoleUrl: comma separated web console URLs, e.g. http://localhost:8161, tried in order until one answers
+// consoleUser, consolePasswd: console credentials
+func NewArtemisQueueDiscoverer(consoleUrl, consoleUser, consolePasswd string) *ArtemisQueueDiscoverer {
+	return &ArtemisQueueDiscoverer{
+		jolokia: newJolokiaClient(consoleUrl, artemisJolokiaPath, consoleUser, consolePasswd),
+	}
+}
+
+func (d *ArtemisQueueDiscoverer) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	queueAttributes, err := d.readQueues(ctx, "*")
+	if err != nil {
+		return nil, err
+	}
+
+	queues := []Queue{}
+	for mbean, attributes := range queueAttributes {
+		properties := parseObjectNameProperties(mbean)
+		queues = append(queues, Queue{
+			Name: properties["queue"],
+			Info: map[string]string{
+				"Size":      strconv.FormatInt(attributes.MessageCount, 10),
+				"Consumers": strconv.FormatInt(attributes.ConsumerCount, 10),
+				"Address":   properties["address"],
+			},
+		})
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+
+	return queues, nil
+}
+
+func (d *ArtemisQueueDiscoverer) QueueSize(ctx context.Context, queueName string) (int, error) {
+	queueAttributes, err := d.readQueues(ctx, quoteObjectNameValue(queueName))
+	if err != nil {
+		return 0, err
+	}
+	if len(queueAttributes) == 0 {
+		return 0, queueNotFound(queueName)
+	}
+
+	var size int64
+	for _, attributes := range queueAttributes {
+		size += attributes.MessageCount
+	}
+	return int(size), nil
+}
+
+// readQueues reads the message and consumer counts of the anycast queues matching the queue pattern, keyed by
+// their MBean names
+func (d *ArtemisQueueDiscoverer) readQueues(ctx context.Context, queuePattern string) (map[string]artemisQueueAttributes, error) {
+	queueAttributes := make(map[string]artemisQueueAttributes)
+	err := d.jolokia.call(ctx, jolokiaRequest{
+		Type:      "read",
+		MBean:     `org.apache.activemq.artemis:broker=*,component=addresses,address=*,subcomponent=queues,routing-type="anycast",queue=` + queuePattern,
+		Attribute: []string{"MessageCount", "ConsumerCount"},
+	}, &queueAttributes)
+	if isJolokiaNotFound(err) {
+		// Jolokia answers a pattern nothing matches with not found
+		return queueAttributes, nil
+	}
+	return queueAttributes, err
+}
@@ -0,0 +1,37 @@
+package adapters
+
+// GetCapabilities returns what the adapter's broker supports, as the adapter reports it or, for adapters that
+// don't, as the interfaces it implements say
+func GetCapabilities(adapter Adapter) Capabilities {
+	if reporter, ok := adapter.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return defaultCapabilities(adapter)
+}
+
+// defaultCapabilities is everything the Adapter interface offers, paged by offset, along with the optional
+// interfaces the adapter implements
+func defaultCapabilities(adapter Adapter) Capabilities {
+	_, messageGetter := adapter.(MessageGetter)
+	_, copier := adapter.(Copier)
+	_, redriver := adapter.(Redriver)
+	_, selectorBrowser := adapter.(SelectorBrowser)
+	_, journaler := adapter.(Journaler)
+
+	capabilities := Capabilities{
+		GetMessage: messageGetter,
+		Move:       true,
+		Copy:       copier,
+		Delete:     true,
+		Purge:      true,
+		Redrive:    redriver,
+		Selectors:  selectorBrowser,
+		Filters:    []string{},
+		Paging:     []string{PagingOffset},
+		Journal:    journaler,
+	}
+	if _, ok := adapter.(RangeBrowser); ok {
+		capabilities.Paging = append(capabilities.Paging, PagingRange)
+	}
+	return capabilities
+}
@@ -0,0 +1,238 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// connectionMinBackoff is how long a supervisor waits after its first failed round of dials
+	connectionMinBackoff = 1 * time.Second
+	// connectionMaxBackoff caps how long a supervisor waits between rounds of dials
+	connectionMaxBackoff = 30 * time.Second
+)
+
+// ConnectionState says whether an adapter is connected to its broker
+type ConnectionState string
+
+const (
+	ConnectionConnecting   ConnectionState = "connecting"
+	ConnectionConnected    ConnectionState = "connected"
+	ConnectionReconnecting ConnectionState = "reconnecting"
+	ConnectionClosed       ConnectionState = "closed"
+)
+
+// ConnectionStatus is a snapshot of an adapter's connection to its broker
+type ConnectionStatus struct {
+	State ConnectionState
+	// URL is the broker URL the adapter is, or was last, connected to
+	URL string
+	// LastError is why the last connection or dial failed
+	LastError string
+	// Since is when the connection entered its state
+	Since time.Time
+	// Attempts counts the rounds of dials since the connection was last up
+	Attempts int
+}
+
+// connectionDialer connects to one broker URL.  It returns the connection and, when the client library can tell,
+// a channel that receives (or is closed) once the connection drops.
+type connectionDialer func(url string) (io.Closer, <-chan error, error)
+
+// connectionSupervisor keeps a connection to one of a broker's URLs.  When the connection drops, or an operation
+// reports that it failed, the supervisor dials the URLs in order, backing off between rounds, until one connects.
+type connectionSupervisor struct {
+	name       string
+	urls       []string
+	dial       connectionDialer
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mutex        sync.Mutex
+	conn         io.Closer
+	status       ConnectionStatus
+	reconnecting bool
+	stop         chan struct{}
+}
+
+// newConnectionSupervisor dials the URLs once before returning.  If none of them connect it keeps trying in the
+// background, so the adapter starts working once its broker comes up.
+func newConnectionSupervisor(name string, urls []string, dial connectionDialer) *connectionSupervisor {
+	s := &connectionSupervisor{
+		name:       name,
+		urls:       urls,
+		dial:       dial,
+		minBackoff: connectionMinBackoff,
+		maxBackoff: connectionMaxBackoff,
+		status:     ConnectionStatus{State: ConnectionConnecting, Since: time.Now().UTC()},
+		stop:       make(chan struct{}),
+	}
+
+	if !s.dialAll() {
+		s.mutex.Lock()
+		s.startReconnecting()
+		s.mutex.Unlock()
+	}
+	return s
+}
+
+// get returns the current connection, or an ErrBrokerUnavailable saying why there isn't one
+func (s *connectionSupervisor) get() (io.Closer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn == nil {
+		return nil, unavailable(fmt.Errorf("%s is %s: %s", s.name, s.status.State, s.status.LastError))
+	}
+	return s.conn, nil
+}
+
+// failed tells the supervisor an operation found the connection broken, so it reconnects.  Reports about a
+// connection that has already been replaced are ignored.
+func (s *connectionSupervisor) failed(conn io.Closer, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if conn == nil || conn != s.conn {
+		return
+	}
+	log.Printf("connection to %s at %s failed: %s", s.name, s.status.URL, err)
+	s.drop(err)
+}
+
+func (s *connectionSupervisor) ConnectionStatus() ConnectionStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.status
+}
+
+// Close stops reconnecting and closes the connection
+func (s *connectionSupervisor) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status.State == ConnectionClosed {
+		return nil
+	}
+	close(s.stop)
+	s.setState(ConnectionClosed)
+
+	var err error
+	if s.conn != nil {
+		err = s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+// dialAll tries each URL in turn, and returns whether one connected
+func (s *connectionSupervisor) dialAll() bool {
+	var dialErr error
+	for _, url := range s.urls {
+		log.Printf("Attempting to connect to %s at %s", s.name, url)
+		conn, closed, err := s.dial(url)
+		if err != nil {
+			log.Printf("Error connecting to %s at %s: %s", s.name, url, err)
+			dialErr = err
+			continue
+		}
+
+		s.mutex.Lock()
+		if s.status.State == ConnectionClosed {
+			s.mutex.Unlock()
+			conn.Close()
+			return true
+		}
+		s.conn = conn
+		s.reconnecting = false
+		s.status.URL = url
+		s.status.LastError = ""
+		s.status.Attempts = 0
+		s.setState(ConnectionConnected)
+		s.mutex.Unlock()
+
+		log.Printf("Connected to %s at %s", s.name, url)
+		if closed != nil {
+			go s.watch(conn, closed)
+		}
+		return true
+	}
+
+	if dialErr == nil {
+		dialErr = errors.New("no broker URLs configured")
+	}
+	s.mutex.Lock()
+	s.status.LastError = dialErr.Error()
+	s.status.Attempts++
+	s.mutex.Unlock()
+	return false
+}
+
+// watch waits for the connection to drop
+func (s *connectionSupervisor) watch(conn io.Closer, closed <-chan error) {
+	select {
+	case err, ok := <-closed:
+		if !ok || err == nil {
+			err = errors.New("connection closed")
+		}
+		s.failed(conn, err)
+	case <-s.stop:
+	}
+}
+
+// drop forgets the broken connection and starts reconnecting, the caller holding the lock
+func (s *connectionSupervisor) drop(err error) {
+	if s.status.State == ConnectionClosed {
+		return
+	}
+	go s.conn.Close()
+	s.conn = nil
+	s.status.LastError = err.Error()
+	s.setState(ConnectionReconnecting)
+	s.startReconnecting()
+}
+
+// startReconnecting starts the reconnect loop unless it is already running, the caller holding the lock
+func (s *connectionSupervisor) startReconnecting() {
+	if s.reconnecting {
+		return
+	}
+	s.reconnecting = true
+	if s.status.State != ConnectionConnecting {
+		s.setState(ConnectionReconnecting)
+	}
+	go s.reconnect()
+}
+
+func (s *connectionSupervisor) reconnect() {
+	backoff := s.minBackoff
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		if s.dialAll() {
+			return
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// setState moves the connection to the state, the caller holding the lock
+func (s *connectionSupervisor) setState(state ConnectionState) {
+	if s.status.State != state {
+		s.status.State = state
+		s.status.Since = time.Now().UTC()
+	}
+}
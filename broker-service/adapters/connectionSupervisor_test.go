@@ -0,0 +1,173 @@
+package adapters
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConnection counts how often it is closed
+type fakeConnection struct {
+	url    string
+	mutex  sync.Mutex
+	closes int
+}
+
+func (c *fakeConnection) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closes++
+	return nil
+}
+
+// fakeBroker dials the URLs that are up, handing out a closed channel per connection so tests can drop it
+type fakeBroker struct {
+	mutex  sync.Mutex
+	up     map[string]bool
+	dials  int
+	closed map[*fakeConnection]chan error
+}
+
+func newFakeBroker(up ...string) *fakeBroker {
+	b := &fakeBroker{up: make(map[string]bool), closed: make(map[*fakeConnection]chan error)}
+	for _, url := range up {
+		b.up[url] = true
+	}
+	return b
+}
+
+func (b *fakeBroker) setUp(url string, up bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.up[url] = up
+}
+
+func (b *fakeBroker) dial(url string) (io.Closer, <-chan error, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.dials++
+	if !b.up[url] {
+		return nil, nil, errors.New("connection refused")
+	}
+	conn := &fakeConnection{url: url}
+	b.closed[conn] = make(chan error, 1)
+	return conn, b.closed[conn], nil
+}
+
+func (b *fakeBroker) drop(conn io.Closer) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed[conn.(*fakeConnection)] <- errors.New("connection reset")
+}
+
+func useShortConnectionBackoff(t *testing.T) {
+	minBackoff, maxBackoff := connectionMinBackoff, connectionMaxBackoff
+	connectionMinBackoff, connectionMaxBackoff = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { connectionMinBackoff, connectionMaxBackoff = minBackoff, maxBackoff })
+}
+
+// waitForState polls the supervisor until it reaches the state
+func waitForState(t *testing.T, s *connectionSupervisor, state ConnectionState) ConnectionStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status := s.ConnectionStatus()
+		if status.State == state {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("connection status = %+v, want state %s", status, state)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnectionSupervisor_FailsOver(t *testing.T) {
+	useShortConnectionBackoff(t)
+	broker := newFakeBroker("b")
+	s := newConnectionSupervisor("test", []string{"a", "b"}, broker.dial)
+	defer s.Close()
+
+	status := s.ConnectionStatus()
+	if status.State != ConnectionConnected || status.URL != "b" {
+		t.Fatalf("connection status = %+v, want connected to b", status)
+	}
+	conn, err := s.get()
+	if err != nil || conn.(*fakeConnection).url != "b" {
+		t.Errorf("get() = %v, %v, want the connection to b", conn, err)
+	}
+}
+
+func TestConnectionSupervisor_ConnectsOnceBrokerIsUp(t *testing.T) {
+	useShortConnectionBackoff(t)
+	broker := newFakeBroker()
+	s := newConnectionSupervisor("test", []string{"a"}, broker.dial)
+	defer s.Close()
+
+	if _, err := s.get(); err == nil {
+		t.Fatalf("get() without a broker should fail")
+	}
+	if status := s.ConnectionStatus(); status.LastError != "connection refused" || status.Attempts == 0 {
+		t.Errorf("connection status = %+v, want the dial error", status)
+	}
+
+	broker.setUp("a", true)
+	status := waitForState(t, s, ConnectionConnected)
+	if status.Attempts != 0 || status.LastError != "" {
+		t.Errorf("connection status once connected = %+v", status)
+	}
+}
+
+func TestConnectionSupervisor_Reconnects(t *testing.T) {
+	useShortConnectionBackoff(t)
+	broker := newFakeBroker("a")
+	s := newConnectionSupervisor("test", []string{"a"}, broker.dial)
+	defer s.Close()
+
+	first, _ := s.get()
+	broker.setUp("a", false)
+	broker.drop(first)
+	status := waitForState(t, s, ConnectionReconnecting)
+	// a reconnect may already have failed by the time the status is read
+	if status.LastError != "connection reset" && status.LastError != "connection refused" {
+		t.Errorf("connection status after drop = %+v", status)
+	}
+
+	broker.setUp("a", true)
+	waitForState(t, s, ConnectionConnected)
+	second, err := s.get()
+	if err != nil || second == first {
+		t.Errorf("get() after reconnecting = %v, %v, want a new connection", second, err)
+	}
+
+	// reports about the old connection don't drop the new one
+	s.failed(first, errors.New("stale"))
+	if status := s.ConnectionStatus(); status.State != ConnectionConnected {
+		t.Errorf("connection status after a stale failure = %+v", status)
+	}
+}
+
+func TestConnectionSupervisor_Close(t *testing.T) {
+	useShortConnectionBackoff(t)
+	broker := newFakeBroker()
+	s := newConnectionSupervisor("test", []string{"a"}, broker.dial)
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	broker.mutex.Lock()
+	dials := broker.dials
+	broker.mutex.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+	broker.mutex.Lock()
+	defer broker.mutex.Unlock()
+	if broker.dials > dials+1 {
+		t.Errorf("dialed %d times after Close(), want no more reconnecting", broker.dials-dials)
+	}
+	if status := s.ConnectionStatus(); status.State != ConnectionClosed {
+		t.Errorf("connection status after Close() = %+v", status)
+	}
+}
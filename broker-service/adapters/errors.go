@@ -0,0 +1,135 @@
+package adapters
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// The kinds of failure callers tell apart with errors.Is, which every adapter wraps its errors in where it can tell
+// what went wrong
+var (
+	// ErrQueueNotFound is a queue, topic or stream the broker doesn't have
+	ErrQueueNotFound = errors.New("queue not found")
+	// ErrMessageNotFound is a message ID the queue doesn't hold
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrNotSupported is an operation the broker can't do
+	ErrNotSupported = errors.New("not supported")
+	// ErrBrokerUnavailable is a broker, or management API, that can't be reached
+	ErrBrokerUnavailable = errors.New("broker unavailable")
+	// ErrUnauthorized is a broker turning down the adapter's credentials
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrBadRequest is a request the broker can't make sense of, such as a cursor it never handed out
+	ErrBadRequest = errors.New("bad request")
+)
+
+// QueueNotFoundError is an ErrQueueNotFound for the named queue
+type QueueNotFoundError struct {
+	Queue string
+}
+
+func (e *QueueNotFoundError) Error() string {
+	return fmt.Sprintf("Did not find queue %s", e.Queue)
+}
+
+func (e *QueueNotFoundError) Is(target error) bool {
+	return target == ErrQueueNotFound
+}
+
+// MessageNotFoundError is an ErrMessageNotFound for the message with the ID
+type MessageNotFoundError struct {
+	MessageID string
+}
+
+func (e *MessageNotFoundError) Error() string {
+	return fmt.Sprintf("Did not find message %s", e.MessageID)
+}
+
+func (e *MessageNotFoundError) Is(target error) bool {
+	return target == ErrMessageNotFound
+}
+
+// BrokerError is the error a broker or its client library gave, marked with the kind of failure it is
+type BrokerError struct {
+	Kind error
+	Err  error
+}
+
+func (e *BrokerError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BrokerError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BrokerError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// queueNotFound returns the error for a queue the broker doesn't have
+func queueNotFound(queue string) error {
+	return &QueueNotFoundError{Queue: queue}
+}
+
+// messageNotFound returns the error for a message the queue doesn't hold
+func messageNotFound(messageID string) error {
+	return &MessageNotFoundError{MessageID: messageID}
+}
+
+// unavailable marks err as the broker being out of reach, unless it is nil or already marked
+func unavailable(err error) error {
+	return markError(ErrBrokerUnavailable, err)
+}
+
+// unauthorized marks err as the broker turning down the adapter's credentials, unless it is nil or already marked
+func unauthorized(err error) error {
+	return markError(ErrUnauthorized, err)
+}
+
+// notSupported marks err as an operation the broker can't do
+func notSupported(err error) error {
+	return markError(ErrNotSupported, err)
+}
+
+// badRequest marks err as a request the broker can't make sense of
+func badRequest(err error) error {
+	return markError(ErrBadRequest, err)
+}
+
+func markError(kind error, err error) error {
+	if kind == nil || err == nil || isMarked(err) {
+		return err
+	}
+	return &BrokerError{Kind: kind, Err: err}
+}
+
+// isMarked tells whether err is already one of the kinds of failure
+func isMarked(err error) bool {
+	for _, kind := range []error{ErrQueueNotFound, ErrMessageNotFound, ErrNotSupported, ErrBrokerUnavailable, ErrUnauthorized, ErrBadRequest} {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// httpStatusError returns the error for an unsuccessful response from a broker's HTTP API.  A 404 means the queue
+// isn't there when the request was for one, and is left unmarked otherwise.
+func httpStatusError(api string, resp *http.Response, queue string) error {
+	if resp.StatusCode == http.StatusNotFound && queue != "" {
+		return queueNotFound(queue)
+	}
+	return markError(httpStatusKind(resp.StatusCode), fmt.Errorf("invalid status from %s, status %s", api, resp.Status))
+}
+
+// httpStatusKind returns the kind of failure an HTTP status means, or nil when it could be any
+func httpStatusKind(status int) error {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrBrokerUnavailable
+	}
+	return nil
+}
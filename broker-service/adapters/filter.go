@@ -0,0 +1,76 @@
+package adapters
+
+import (
+	"strings"
+	"time"
+
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// MessageFilter picks out messages by the criteria of the UI's filter.  Text criteria match anywhere in the value,
+// and empty criteria match everything.
+type MessageFilter struct {
+	MessageID     string
+	CorrelationID string
+	// FromDate and ToDate bound the message timestamps, both included.  A zero time leaves that end open.
+	FromDate time.Time
+	ToDate   time.Time
+	Body     string
+	// Headers holds text each named header must contain
+	Headers map[string]string
+}
+
+// IsEmpty tells whether the filter matches every message
+func (f MessageFilter) IsEmpty() bool {
+	return f.MessageID == "" && f.CorrelationID == "" && f.FromDate.IsZero() && f.ToDate.IsZero() && f.Body == "" &&
+		len(f.Headers) == 0
+}
+
+// Matches tells whether the message meets every criterion of the filter
+func (f MessageFilter) Matches(message structs.StandardMessage) bool {
+	if !strings.Contains(message.MessageID, f.MessageID) || !strings.Contains(message.Body, f.Body) {
+		return false
+	}
+	if !f.FromDate.IsZero() && message.Timestamp.Before(f.FromDate) {
+		return false
+	}
+	if !f.ToDate.IsZero() && message.Timestamp.After(f.ToDate) {
+		return false
+	}
+	if f.CorrelationID != "" && !strings.Contains(correlationID(message), f.CorrelationID) {
+		return false
+	}
+	for name, value := range f.Headers {
+		header, ok := message.Headers[name]
+		if !ok || !strings.Contains(header, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the messages that match the filter
+func (f MessageFilter) Apply(messages []structs.StandardMessage) []structs.StandardMessage {
+	if f.IsEmpty() {
+		return messages
+	}
+	matching := []structs.StandardMessage{}
+	for _, message := range messages {
+		if f.Matches(message) {
+			matching = append(matching, message)
+		}
+	}
+	return matching
+}
+
+// correlationID finds the message's correlation ID, which each broker names its own way: CorrelationID,
+// Correlation ID, JMSCorrelationID, correlation-id and so on
+func correlationID(message structs.StandardMessage) string {
+	for name, value := range message.Headers {
+		normalized := strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+		if normalized == "correlationid" || normalized == "jmscorrelationid" {
+			return value
+		}
+	}
+	return ""
+}
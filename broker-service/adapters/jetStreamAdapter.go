@@ -0,0 +1,470 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// jetStreamDefaultMaxMessages caps how many messages a single browse returns
+const jetStreamDefaultMaxMessages = 1000
+
+// jetStreamMaxScannedSequences caps how many sequences a single browse gets, one request each, when the stream is
+// sparse or a consumer's filter matches few of its messages
+const jetStreamMaxScannedSequences = 10000
+
+// JetStreamAdapter browses and manages NATS JetStream streams.
+// Every stream is a queue, and so is every consumer, named stream/consumer.  A message's ID is its stream sequence.
+// Browsing reads messages by sequence so nothing is acknowledged; a consumer shows the messages of its stream that
+// it hasn't acknowledged yet.  Moving a message republishes it on another subject and deletes the original.
+type JetStreamAdapter struct {
+	conn        *nats.Conn
+	js          nats.JetStreamContext
+	maxMessages int
+	// maxScanned caps how many sequences a browse gets
+	maxScanned int
+}
+
+// Returns a JetStream adapter:
+// urls: comma separated NATS server URLs, e.g. nats://localhost:4222
+// user, pass: optional user and password
+// token: optional authentication token
+// credentialsFile: optional user credentials (JWT and seed) file
+func NewJetStreamAdapter(ctx context.Context, urls, user, pass, token, credentialsFile string) (*JetStreamAdapter, error) {
+
+	options := []nats.Option{nats.Name("broker-service"), nats.MaxReconnects(-1)}
+	if user != "" {
+		options = append(options, nats.UserInfo(user, pass))
+	}
+	if token != "" {
+		options = append(options, nats.Token(token))
+	}
+	if credentialsFile != "" {
+		options = append(options, nats.UserCredentials(credentialsFile))
+	}
+
+	conn, err := nats.Connect(urls, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := conn.JetStream(nats.Context(ctx))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &JetStreamAdapter{
+		conn:        conn,
+		js:          js,
+		maxMessages: jetStreamDefaultMaxMessages,
+		maxScanned:  jetStreamMaxScannedSequences,
+	}, nil
+}
+
+func (j *JetStreamAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	var streams []*nats.StreamInfo
+	for info := range j.js.Streams(nats.Context(ctx)) {
+		streams = append(streams, info)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(streams, func(a, b int) bool { return streams[a].Config.Name < streams[b].Config.Name })
+
+	queues := []Queue{}
+	for _, stream := range streams {
+		queues = append(queues, Queue{
+			Name: stream.Config.Name,
+			Info: map[string]string{
+				"Type":      "Stream",
+				"Size":      strconv.FormatUint(stream.State.Msgs, 10),
+				"Bytes":     strconv.FormatUint(stream.State.Bytes, 10),
+				"FirstSeq":  strconv.FormatUint(stream.State.FirstSeq, 10),
+				"LastSeq":   strconv.FormatUint(stream.State.LastSeq, 10),
+				"Subjects":  strings.Join(stream.Config.Subjects, ","),
+				"Retention": stream.Config.Retention.String(),
+				"Consumers": strconv.Itoa(stream.State.Consumers),
+			},
+		})
+
+		var consumers []*nats.ConsumerInfo
+		for info := range j.js.Consumers(stream.Config.Name, nats.Context(ctx)) {
+			consumers = append(consumers, info)
+		}
+		sort.Slice(consumers, func(a, b int) bool { return consumers[a].Name < consumers[b].Name })
+
+		for _, consumer := range consumers {
+			queues = append(queues, Queue{
+				Name: stream.Config.Name + "/" + consumer.Name,
+				Info: map[string]string{
+					"Type":          "Consumer",
+					"Stream":        stream.Config.Name,
+					"Size":          strconv.FormatUint(consumer.NumPending+uint64(consumer.NumAckPending), 10),
+					"NumPending":    strconv.FormatUint(consumer.NumPending, 10),
+					"NumAckPending": strconv.Itoa(consumer.NumAckPending),
+					"Redelivered":   strconv.Itoa(consumer.NumRedelivered),
+					"AckFloor":      strconv.FormatUint(consumer.AckFloor.Stream, 10),
+					"FilterSubject": consumer.Config.FilterSubject,
+				},
+			})
+		}
+	}
+
+	return queues, nil
+}
+
+// jetStreamQueue is a queue name resolved to its stream, and the consumer and its subject filter for a consumer
+type jetStreamQueue struct {
+	stream   *nats.StreamInfo
+	consumer *nats.ConsumerInfo
+	filter   string
+}
+
+// resolveQueue looks up a stream or stream/consumer queue name
+func (j *JetStreamAdapter) resolveQueue(ctx context.Context, encodedQueueName string) (*jetStreamQueue, error) {
+	queueName, _ := url.QueryUnescape(encodedQueueName)
+	streamName, consumerName := queueName, ""
+	if i := strings.Index(queueName, "/"); i >= 0 {
+		streamName, consumerName = queueName[:i], queueName[i+1:]
+	}
+
+	stream, err := j.js.StreamInfo(streamName, nats.Context(ctx))
+	if err != nil {
+		return nil, jetStreamError(queueName, fmt.Errorf("unable to find stream %s: %w", streamName, err))
+	}
+
+	queue := &jetStreamQueue{stream: stream}
+	if consumerName != "" {
+		queue.consumer, err = j.js.ConsumerInfo(streamName, consumerName, nats.Context(ctx))
+		if err != nil {
+			return nil, jetStreamError(queueName, fmt.Errorf("unable to find consumer %s on stream %s: %w", consumerName, streamName, err))
+		}
+		queue.filter = queue.consumer.Config.FilterSubject
+	}
+	return queue, nil
+}
+
+func (j *JetStreamAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	queue, err := j.resolveQueue(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	msgs, _, err := j.readMessages(ctx, queue, 0, j.maxMessages)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range msgs {
+		stdMessages = append(stdMessages, convertJetStreamMessage(msg))
+	}
+
+	return stdMessages, nil
+}
+
+// Capabilities pages by stream sequence
+func (j *JetStreamAdapter) Capabilities() Capabilities {
+	capabilities := defaultCapabilities(j)
+	capabilities.Paging = []string{PagingPosition}
+	return capabilities
+}
+
+// GetMessages pages the queue by stream sequence, the cursor being the sequence to start at
+func (j *JetStreamAdapter) GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error) {
+	var start uint64
+	if page.Cursor != "" {
+		var err error
+		if start, err = strconv.ParseUint(page.Cursor, 10, 64); err != nil {
+			return MessagePage{}, invalidCursor(page.Cursor)
+		}
+	}
+
+	queue, err := j.resolveQueue(ctx, queueName)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	limit := pageLimit(page)
+	msgs, next, err := j.readMessages(ctx, queue, start, limit+1)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	messagePage := MessagePage{Messages: []structs.StandardMessage{}}
+	if len(msgs) > limit {
+		messagePage.NextCursor = strconv.FormatUint(msgs[limit].Sequence, 10)
+		msgs = msgs[:limit]
+	} else if next != 0 {
+		// the page is short as the read stopped, not as the queue ended
+		messagePage.NextCursor = strconv.FormatUint(next, 10)
+	}
+	for _, msg := range msgs {
+		messagePage.Messages = append(messagePage.Messages, convertJetStreamMessage(msg))
+	}
+	return messagePage, nil
+}
+
+// readMessages gets up to limit of the queue's messages, starting at the start sequence or the head of the queue.
+// It gets no more than maxScanned sequences, or limit when that is more; when it stops there next
+// is the sequence to carry on from, else zero.
+func (j *JetStreamAdapter) readMessages(ctx context.Context, queue *jetStreamQueue, start uint64, limit int) ([]*nats.RawStreamMsg, uint64, error) {
+	var msgs []*nats.RawStreamMsg
+	if queue.stream.State.Msgs == 0 {
+		return msgs, 0, nil
+	}
+
+	// a consumer shows what it hasn't acknowledged yet
+	first := queue.stream.State.FirstSeq
+	if queue.consumer != nil && queue.consumer.AckFloor.Stream+1 > first {
+		first = queue.consumer.AckFloor.Stream + 1
+	}
+	if start > first {
+		first = start
+	}
+
+	maxScanned := uint64(j.maxScanned)
+	if limit > j.maxScanned {
+		maxScanned = uint64(limit)
+	}
+
+	for sequence := first; sequence <= queue.stream.State.LastSeq && len(msgs) < limit; sequence++ {
+		if sequence-first == maxScanned {
+			return msgs, sequence, nil
+		}
+		msg, err := j.js.GetMsg(queue.stream.Config.Name, sequence, nats.Context(ctx))
+		if errors.Is(err, nats.ErrMsgNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if queue.filter != "" && !subjectMatches(queue.filter, msg.Subject) {
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, 0, nil
+}
+
+// Move republishes the messages on the destination and deletes them from their stream once the destination
+// stream has stored them.  The destination is a subject, or a stream (or consumer) whose subject can be worked out.
+func (j *JetStreamAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, j.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (j *JetStreamAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	var moveErrors []error
+
+	from, err := j.resolveQueue(ctx, fromQueue)
+	if err != nil {
+		return append(moveErrors, err)
+	}
+
+	toName, _ := url.QueryUnescape(toQueue)
+	to, err := j.resolveQueue(ctx, toQueue)
+	if errors.Is(err, ErrQueueNotFound) {
+		// not a stream, so a subject
+		to = nil
+	} else if err != nil {
+		return forMessages(messageIDs, err)
+	}
+
+	for _, messageID := range messageIDs {
+		msg, err := j.getMessage(ctx, from, messageID)
+		if err != nil {
+			moveErrors = append(moveErrors, forMessage(messageID, err))
+			continue
+		}
+
+		subject := toName
+		var publishOptions []nats.PubOpt
+		if to != nil {
+			subject, err = publishSubject(to, msg.Subject)
+			if err != nil {
+				moveErrors = append(moveErrors, forMessage(messageID, err))
+				continue
+			}
+			publishOptions = append(publishOptions, nats.ExpectStream(to.stream.Config.Name))
+		}
+
+		republished := nats.NewMsg(subject)
+		republished.Data = msg.Data
+		for key, values := range msg.Header {
+			// expectations were for the original publish
+			if strings.HasPrefix(key, "Nats-Expected-") {
+				continue
+			}
+			republished.Header[key] = values
+		}
+
+		ack, err := j.js.PublishMsg(republished, append(publishOptions, nats.Context(ctx))...)
+		if err != nil {
+			log.Printf("error trying to send message %s, error is %s", messageID, err)
+			moveErrors = append(moveErrors, forMessage(messageID, err))
+			continue
+		}
+		if ack.Duplicate {
+			moveErrors = append(moveErrors, forMessage(messageID, fmt.Errorf("message %s was not moved, stream %s already has a message with its Nats-Msg-Id", messageID, ack.Stream)))
+			continue
+		}
+
+		if err := j.js.DeleteMsg(from.stream.Config.Name, msg.Sequence, nats.Context(ctx)); err != nil {
+			moveErrors = append(moveErrors, forMessage(messageID, fmt.Errorf("message %s was copied to %s but not deleted: %s", messageID, subject, err)))
+		}
+	}
+
+	return moveErrors
+}
+
+func (j *JetStreamAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(j.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+// publishSubject works out which subject to republish a message on so that it ends up in the given stream: the
+// consumer's or stream's only literal subject, or else the message's own subject when the stream takes it
+func publishSubject(to *jetStreamQueue, originalSubject string) (string, error) {
+	if to.filter != "" && !strings.ContainsAny(to.filter, "*>") {
+		return to.filter, nil
+	}
+
+	subjects := to.stream.Config.Subjects
+	if to.filter == "" && len(subjects) == 1 && !strings.ContainsAny(subjects[0], "*>") {
+		return subjects[0], nil
+	}
+
+	for _, subject := range subjects {
+		if subjectMatches(subject, originalSubject) && (to.filter == "" || subjectMatches(to.filter, originalSubject)) {
+			return originalSubject, nil
+		}
+	}
+
+	return "", fmt.Errorf("no subject of stream %s to publish %s on, move to a subject instead", to.stream.Config.Name, originalSubject)
+}
+
+// Purge removes every message from a stream, or from a consumer's stream every message matching its filter
+func (j *JetStreamAdapter) Purge(ctx context.Context, queueName string) error {
+	queue, err := j.resolveQueue(ctx, queueName)
+	if err != nil {
+		return err
+	}
+
+	var purgeOptions []nats.JSOpt
+	if queue.filter != "" {
+		purgeOptions = append(purgeOptions, &nats.StreamPurgeRequest{Subject: queue.filter})
+	}
+	return j.js.PurgeStream(queue.stream.Config.Name, append(purgeOptions, nats.Context(ctx))...)
+}
+
+func (j *JetStreamAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return resultError(j.DeleteMany(ctx, queueName, []string{messageID}))
+}
+
+// DeleteMany deletes the messages from the stream, for a consumer from the consumer's stream
+func (j *JetStreamAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, j.deleteMany(ctx, queueName, messageIDs))
+}
+
+func (j *JetStreamAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	var deleteErrors []error
+
+	queue, err := j.resolveQueue(ctx, queueName)
+	if err != nil {
+		return append(deleteErrors, err)
+	}
+
+	for _, messageID := range messageIDs {
+		msg, err := j.getMessage(ctx, queue, messageID)
+		if err != nil {
+			deleteErrors = append(deleteErrors, forMessage(messageID, err))
+			continue
+		}
+		if err := j.js.DeleteMsg(queue.stream.Config.Name, msg.Sequence, nats.Context(ctx)); err != nil {
+			deleteErrors = append(deleteErrors, forMessage(messageID, err))
+		}
+	}
+
+	return deleteErrors
+}
+
+// getMessage returns the message with the given stream sequence, if the queue has it
+func (j *JetStreamAdapter) getMessage(ctx context.Context, queue *jetStreamQueue, messageID string) (*nats.RawStreamMsg, error) {
+	sequence, err := strconv.ParseUint(messageID, 10, 64)
+	if err != nil {
+		return nil, messageNotFound(messageID)
+	}
+
+	msg, err := j.js.GetMsg(queue.stream.Config.Name, sequence, nats.Context(ctx))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return nil, messageNotFound(messageID)
+	}
+	if err != nil {
+		return nil, jetStreamError("", err)
+	}
+	if queue.filter != "" && !subjectMatches(queue.filter, msg.Subject) {
+		return nil, messageNotFound(messageID)
+	}
+	return msg, nil
+}
+
+// jetStreamError marks the error the server or client gave for a request about the queue
+func jetStreamError(queueName string, err error) error {
+	switch {
+	case queueName != "" && (errors.Is(err, nats.ErrStreamNotFound) || errors.Is(err, nats.ErrConsumerNotFound)):
+		return queueNotFound(queueName)
+	case queueName != "" && (errors.Is(err, nats.ErrInvalidStreamName) || errors.Is(err, nats.ErrInvalidConsumerName)):
+		// a name with a dot, such as a subject, can't be a stream or consumer
+		return queueNotFound(queueName)
+	case errors.Is(err, nats.ErrAuthorization):
+		return unauthorized(err)
+	case errors.Is(err, nats.ErrTimeout), errors.Is(err, nats.ErrNoResponders), errors.Is(err, nats.ErrConnectionClosed),
+		errors.Is(err, nats.ErrNoServers), errors.Is(err, nats.ErrJetStreamNotEnabled):
+		return unavailable(err)
+	}
+	return err
+}
+
+func convertJetStreamMessage(msg *nats.RawStreamMsg) structs.StandardMessage {
+	headers := make(map[string]string)
+	for key, values := range msg.Header {
+		headers[key] = strings.Join(values, ", ")
+	}
+	headers["Subject"] = msg.Subject
+	headers["Sequence"] = strconv.FormatUint(msg.Sequence, 10)
+
+	return structs.StandardMessage{
+		MessageID: strconv.FormatUint(msg.Sequence, 10),
+		Timestamp: msg.Time.UTC(),
+		Headers:   headers,
+		Body:      string(msg.Data),
+	}
+}
+
+// subjectMatches reports whether a subject matches a subject filter, in which * matches a single token and a
+// trailing > matches one or more tokens
+func subjectMatches(filter string, subject string) bool {
+	filterTokens := strings.Split(filter, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range filterTokens {
+		if token == ">" && i == len(filterTokens)-1 {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) {
+			return false
+		}
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+	return len(filterTokens) == len(subjectTokens)
+}
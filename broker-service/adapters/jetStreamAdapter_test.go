@@ -0,0 +1,272 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// newTestJetStreamAdapter starts an embedded NATS server with JetStream enabled and connects an adapter to it
+func newTestJetStreamAdapter(t *testing.T) *JetStreamAdapter {
+	natsServer, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("unable to create nats server: %v", err)
+	}
+	go natsServer.Start()
+	if !natsServer.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("nats server not ready")
+	}
+	t.Cleanup(natsServer.Shutdown)
+
+	adapter, err := NewJetStreamAdapter(context.Background(), natsServer.ClientURL(), "", "", "", "")
+	if err != nil {
+		t.Fatalf("NewJetStreamAdapter() error = %v", err)
+	}
+	t.Cleanup(adapter.conn.Close)
+	return adapter
+}
+
+var testStreamCount int32
+
+// createTestStream adds a stream taking the given subjects, a stream of its own when none are given
+func createTestStream(t *testing.T, adapter *JetStreamAdapter, subjects ...string) string {
+	name := fmt.Sprintf("TEST%d", atomic.AddInt32(&testStreamCount, 1))
+	if len(subjects) == 0 {
+		subjects = []string{"test." + name}
+	}
+	if _, err := adapter.js.AddStream(&nats.StreamConfig{Name: name, Subjects: subjects}); err != nil {
+		t.Fatalf("unable to add stream %s: %v", name, err)
+	}
+	return name
+}
+
+func publishTestMessages(t *testing.T, adapter *JetStreamAdapter, subject string, bodies ...string) {
+	for _, body := range bodies {
+		msg := nats.NewMsg(subject)
+		msg.Data = []byte(body)
+		msg.Header.Set("Body", body)
+		if _, err := adapter.js.PublishMsg(msg); err != nil {
+			t.Fatalf("unable to publish to %s: %v", subject, err)
+		}
+	}
+}
+
+func TestJetStreamAdapter(t *testing.T) {
+	adapter := newTestJetStreamAdapter(t)
+
+	testAdapter(t, adapterTestHarness{
+		adapter: adapter,
+		newQueue: func(t *testing.T) string {
+			return createTestStream(t, adapter)
+		},
+		send: func(t *testing.T, queueName string, bodies ...string) {
+			publishTestMessages(t, adapter, "test."+queueName, bodies...)
+		},
+	})
+}
+
+func TestJetStreamAdapter_Consumers(t *testing.T) {
+	adapter := newTestJetStreamAdapter(t)
+	ctx := context.Background()
+
+	stream := createTestStream(t, adapter, "orders.>")
+	publishTestMessages(t, adapter, "orders.new", "new 1", "new 2", "new 3")
+	publishTestMessages(t, adapter, "orders.paid", "paid 1")
+
+	_, err := adapter.js.AddConsumer(stream, &nats.ConsumerConfig{
+		Durable:       "shipping",
+		FilterSubject: "orders.new",
+		AckPolicy:     nats.AckExplicitPolicy,
+	})
+	if err != nil {
+		t.Fatalf("unable to add consumer: %v", err)
+	}
+
+	// the consumer acknowledges its first message
+	subscription, err := adapter.js.PullSubscribe("orders.new", "shipping", nats.Bind(stream, "shipping"))
+	if err != nil {
+		t.Fatalf("unable to bind to consumer: %v", err)
+	}
+	msgs, err := subscription.Fetch(1)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("unable to fetch from consumer: %v", err)
+	}
+	if err := msgs[0].AckSync(); err != nil {
+		t.Fatalf("unable to ack: %v", err)
+	}
+
+	queues, err := adapter.GetAllQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if len(queues) != 2 || queues[0].Name != stream || queues[1].Name != stream+"/shipping" {
+		t.Fatalf("GetAllQueues() = %v", queues)
+	}
+	if info := queues[0].Info; info["Type"] != "Stream" || info["Size"] != "4" || info["Subjects"] != "orders.>" || info["Consumers"] != "1" {
+		t.Errorf("GetAllQueues() stream info = %v", info)
+	}
+	if info := queues[1].Info; info["Type"] != "Consumer" || info["Size"] != "2" || info["FilterSubject"] != "orders.new" || info["AckFloor"] != "1" {
+		t.Errorf("GetAllQueues() consumer info = %v", info)
+	}
+
+	// browsing the consumer shows the messages it hasn't acknowledged, and doesn't acknowledge them
+	messages, err := adapter.GetAllMessages(ctx, stream+"%2Fshipping")
+	if err != nil {
+		t.Fatalf("GetAllMessages() error = %v", err)
+	}
+	if len(messages) != 2 || messages[0].Body != "new 2" || messages[1].Body != "new 3" {
+		t.Fatalf("GetAllMessages(consumer) = %v", messages)
+	}
+	if messages[0].MessageID != "2" || messages[0].Headers["Subject"] != "orders.new" || messages[0].Headers["Sequence"] != "2" || messages[0].Headers["Body"] != "new 2" {
+		t.Errorf("GetAllMessages(consumer) first message = %v", messages[0])
+	}
+	if info, _ := adapter.js.ConsumerInfo(stream, "shipping"); info.AckFloor.Stream != 1 {
+		t.Errorf("browsing acknowledged messages, ack floor = %d", info.AckFloor.Stream)
+	}
+
+	// the paid message isn't the consumer's to delete
+	if err := adapter.DeleteOne(ctx, stream+"/shipping", "4"); err == nil {
+		t.Errorf("DeleteOne() of a message outside the consumer's filter should fail")
+	}
+
+	// purging the consumer leaves messages it doesn't see
+	if err := adapter.Purge(ctx, stream+"/shipping"); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+	messages, _ = adapter.GetAllMessages(ctx, stream)
+	if len(messages) != 1 || messages[0].Body != "paid 1" {
+		t.Errorf("GetAllMessages(stream) after consumer purge = %v", messages)
+	}
+}
+
+func TestJetStreamAdapter_SparseConsumer(t *testing.T) {
+	adapter := newTestJetStreamAdapter(t)
+	adapter.maxScanned = 2
+	ctx := context.Background()
+
+	stream := createTestStream(t, adapter, "orders.>")
+	publishTestMessages(t, adapter, "orders.paid", "paid 1", "paid 2", "paid 3", "paid 4")
+	publishTestMessages(t, adapter, "orders.new", "new 1")
+	if _, err := adapter.js.AddConsumer(stream, &nats.ConsumerConfig{Durable: "shipping", FilterSubject: "orders.new", AckPolicy: nats.AckExplicitPolicy}); err != nil {
+		t.Fatalf("unable to add consumer: %v", err)
+	}
+
+	// each page gets two sequences at most, and the cursor carries on from where it stopped
+	var cursors []string
+	page, err := adapter.GetMessages(ctx, stream+"/shipping", PageRequest{Limit: 1})
+	for err == nil && page.NextCursor != "" && len(page.Messages) == 0 {
+		cursors = append(cursors, page.NextCursor)
+		page, err = adapter.GetMessages(ctx, stream+"/shipping", PageRequest{Limit: 1, Cursor: page.NextCursor})
+	}
+	if err != nil || len(page.Messages) != 1 || page.Messages[0].Body != "new 1" || page.NextCursor != "" {
+		t.Fatalf("GetMessages() = %v, %v", page, err)
+	}
+	if fmt.Sprint(cursors) != "[3 5]" {
+		t.Errorf("GetMessages() cursors = %v, want [3 5]", cursors)
+	}
+
+	messages, err := adapter.GetAllMessages(ctx, stream+"/shipping")
+	if err != nil || len(messages) != 1 {
+		t.Errorf("GetAllMessages() with maxMessages above maxScanned = %v, %v", messages, err)
+	}
+}
+
+func TestJetStreamAdapter_MoveToSubject(t *testing.T) {
+	adapter := newTestJetStreamAdapter(t)
+	ctx := context.Background()
+
+	deadLetters := createTestStream(t, adapter, "dlq.>")
+	orders := createTestStream(t, adapter, "orders.*")
+	publishTestMessages(t, adapter, "dlq.orders", "first", "second")
+
+	// a stream with a wildcard subject needs a subject to move to
+	if err := adapter.MoveOne(ctx, deadLetters, orders, "1"); err == nil {
+		t.Errorf("MoveOne() to a stream without a literal subject should fail")
+	}
+
+	if err := adapter.MoveOne(ctx, deadLetters, "orders.retry", "1"); err != nil {
+		t.Fatalf("MoveOne() error = %v", err)
+	}
+	messages, _ := adapter.GetAllMessages(ctx, orders)
+	if len(messages) != 1 || messages[0].Headers["Subject"] != "orders.retry" || messages[0].Body != "first" || messages[0].Headers["Body"] != "first" {
+		t.Errorf("GetAllMessages(orders) after move = %v", messages)
+	}
+
+	// the subject is unescaped once
+	if err := adapter.MoveOne(ctx, deadLetters, "orders.a%2Bb", "2"); err != nil {
+		t.Fatalf("MoveOne() to orders.a%%2Bb error = %v", err)
+	}
+	messages, _ = adapter.GetAllMessages(ctx, orders)
+	if len(messages) != 2 || messages[1].Headers["Subject"] != "orders.a+b" {
+		t.Errorf("GetAllMessages(orders) after move to orders.a+b = %v", messages)
+	}
+	publishTestMessages(t, adapter, "dlq.orders", "second")
+
+	// nothing stores the subject, so the message stays where it is
+	if err := adapter.MoveOne(ctx, deadLetters, "nowhere", "3"); err == nil {
+		t.Errorf("MoveOne() to a subject no stream takes should fail")
+	}
+	messages, _ = adapter.GetAllMessages(ctx, deadLetters)
+	if len(messages) != 1 || messages[0].Body != "second" {
+		t.Errorf("GetAllMessages(dlq) after failed move = %v", messages)
+	}
+}
+
+func TestJetStreamAdapter_MoveDuplicate(t *testing.T) {
+	adapter := newTestJetStreamAdapter(t)
+	ctx := context.Background()
+
+	from := createTestStream(t, adapter)
+	to := createTestStream(t, adapter)
+
+	for _, queueName := range []string{from, to} {
+		msg := nats.NewMsg("test." + queueName)
+		msg.Data = []byte(queueName)
+		msg.Header.Set(nats.MsgIdHdr, "same-id")
+		if _, err := adapter.js.PublishMsg(msg); err != nil {
+			t.Fatalf("unable to publish: %v", err)
+		}
+	}
+
+	// the destination drops the copy as a duplicate, so the original must stay
+	if err := adapter.MoveOne(ctx, from, to, "1"); err == nil {
+		t.Errorf("MoveOne() of a duplicate should fail")
+	}
+	if messages, _ := adapter.GetAllMessages(ctx, from); len(messages) != 1 {
+		t.Errorf("GetAllMessages() after duplicate move = %v", messages)
+	}
+}
+
+func TestSubjectMatches(t *testing.T) {
+	tests := []struct {
+		filter  string
+		subject string
+		want    bool
+	}{
+		{"orders.new", "orders.new", true},
+		{"orders.new", "orders.paid", false},
+		{"orders.*", "orders.new", true},
+		{"orders.*", "orders.new.eu", false},
+		{"orders.>", "orders.new.eu", true},
+		{"orders.>", "orders", false},
+		{"*.new", "orders.new", true},
+		{"orders", "orders.new", false},
+	}
+	for _, tt := range tests {
+		if got := subjectMatches(tt.filter, tt.subject); got != tt.want {
+			t.Errorf("subjectMatches(%s, %s) = %v, want %v", tt.filter, tt.subject, got, tt.want)
+		}
+	}
+}
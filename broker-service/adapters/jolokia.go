@@ -0,0 +1,193 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// jolokiaClient calls the Jolokia JMX-over-HTTP API that ActiveMQ Classic and Artemis serve from their web consoles
+type jolokiaClient struct {
+	consoleUrls []string
+	path        string
+	consoleUsr  string
+	consolePwd  string
+	httpClient  *http.Client
+}
+
+type jolokiaRequest struct {
+	Type      string        `json:"type"`
+	MBean     string        `json:"mbean,omitempty"`
+	Attribute []string      `json:"attribute,omitempty"`
+	Operation string        `json:"operation,omitempty"`
+	Arguments []interface{} `json:"arguments,omitempty"`
+}
+
+type jolokiaResponse struct {
+	Status    int             `json:"status"`
+	Error     string          `json:"error"`
+	ErrorType string          `json:"error_type"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// jolokiaError is an error Jolokia reports in its response, e.g. status 404 when no MBean matches
+type jolokiaError struct {
+	Status  int
+	Message string
+	// Type is the Java exception behind the error, e.g. javax.jms.InvalidSelectorException
+	Type string
+}
+
+func (e *jolokiaError) Error() string {
+	return fmt.Sprintf("jolokia error %d: %s", e.Status, e.Message)
+}
+
+// Is tells the kind of failure the error is: credentials turned down, no MBean for the queue, or a request the
+// broker couldn't make sense of, such as a selector it can't parse
+func (e *jolokiaError) Is(target error) bool {
+	switch {
+	case e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden:
+		return target == ErrUnauthorized
+	case e.Status == http.StatusNotFound:
+		return target == ErrQueueNotFound
+	case e.Status == http.StatusBadRequest || strings.HasSuffix(e.Type, "InvalidSelectorException"):
+		return target == ErrBadRequest
+	}
+	return false
+}
+
+// isJolokiaNotFound tells whether Jolokia found no MBean for the request
+func isJolokiaNotFound(err error) bool {
+	var jolokiaErr *jolokiaError
+	return errors.As(err, &jolokiaErr) && jolokiaErr.Status == http.StatusNotFound
+}
+
+// newJolokiaClient returns a client for the comma separated console URLs, serving Jolokia at path
+func newJolokiaClient(consoleUrl, path, consoleUser, consolePasswd string) *jolokiaClient {
+	var consoleUrls []string
+	for _, url := range strings.Split(consoleUrl, ",") {
+		consoleUrls = append(consoleUrls, strings.TrimSuffix(url, "/"))
+	}
+	return &jolokiaClient{
+		consoleUrls: consoleUrls,
+		path:        path,
+		consoleUsr:  consoleUser,
+		consolePwd:  consolePasswd,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// call sends the request to each console in turn until one answers, and decodes the value it returns into value
+func (j *jolokiaClient) call(ctx context.Context, request jolokiaRequest, value interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	var response jolokiaResponse
+	for _, consoleUrl := range j.consoleUrls {
+		response, err = j.post(ctx, consoleUrl, body)
+		if err == nil {
+			break
+		}
+		log.Printf("Error returned from this attempt was %s, url: %s", err, consoleUrl)
+	}
+	if err != nil {
+		log.Printf("Unable to connect with any consoleURLs: %s", err)
+		return err
+	}
+
+	if response.Status != http.StatusOK {
+		return &jolokiaError{Status: response.Status, Message: response.Error, Type: response.ErrorType}
+	}
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(response.Value, value)
+}
+
+func (j *jolokiaClient) post(ctx context.Context, consoleUrl string, body []byte) (jolokiaResponse, error) {
+	var response jolokiaResponse
+
+	req, err := http.NewRequest(http.MethodPost, consoleUrl+j.path, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(j.consoleUsr, j.consolePwd)
+	req.Header.Set("Content-Type", "application/json")
+	// the consoles only let through Jolokia requests from the origins in their jolokia-access.xml
+	req.Header.Set("Origin", consoleUrl)
+
+	resp, err := j.httpClient.Do(req)
+	if err != nil {
+		return response, unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return response, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return response, httpStatusError("jolokia", resp, "")
+	}
+
+	err = json.Unmarshal(respBody, &response)
+	return response, err
+}
+
+// parseObjectNameProperties returns the key properties of a JMX object name, e.g. queue and address from
+// org.apache.activemq.artemis:address="orders",queue="orders.dlq", with quoted values unquoted
+func parseObjectNameProperties(objectName string) map[string]string {
+	properties := make(map[string]string)
+	if colon := strings.Index(objectName, ":"); colon >= 0 {
+		objectName = objectName[colon+1:]
+	}
+
+	var key, value strings.Builder
+	inKey, quoted, escaped := true, false, false
+	for _, r := range objectName {
+		switch {
+		case inKey && r == '=':
+			inKey = false
+		case inKey:
+			key.WriteRune(r)
+		case escaped:
+			if r == 'n' {
+				r = '\n'
+			}
+			value.WriteRune(r)
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && r == ',':
+			properties[key.String()] = value.String()
+			key.Reset()
+			value.Reset()
+			inKey = true
+		default:
+			value.WriteRune(r)
+		}
+	}
+	if key.Len() > 0 {
+		properties[key.String()] = value.String()
+	}
+	return properties
+}
+
+// quoteObjectNameValue quotes a JMX object name value, escaping the characters a quoted value can't hold and the
+// wildcards it would otherwise match with
+func quoteObjectNameValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `*`, `\*`, `?`, `\?`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
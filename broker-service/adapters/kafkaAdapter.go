@@ -0,0 +1,852 @@
+package adapters
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+const (
+	// kafkaDefaultMaxMessages caps how many records a single browse returns
+	kafkaDefaultMaxMessages = 1000
+	// kafkaDefaultGroupID is the consumer group whose offsets moves commit
+	kafkaDefaultGroupID = "brokerui"
+	// kafkaReadTimeout is how long a browse waits for more records before deciding the range has been read
+	kafkaReadTimeout = 5 * time.Second
+)
+
+// Browse positions understood by GetMessagesInRange, besides offsets and RFC 3339 timestamps
+const (
+	kafkaPositionEarliest  = "earliest"
+	kafkaPositionLatest    = "latest"
+	kafkaPositionCommitted = "committed"
+)
+
+// errKafkaDeleteNotSupported is returned for single record deletes, which Kafka has no way of doing
+var errKafkaDeleteNotSupported = notSupported(errors.New("deleting single records is not supported by Kafka, records can only be moved or the whole topic purged"))
+
+// KafkaAdapter browses and republishes records on Kafka topics.
+// Each topic is a queue and a record's ID is its partition and offset, e.g. 0:42.
+// Kafka keeps records after they are read, so a move copies records to the destination topic and commits the
+// adapter's consumer group past them on the source; browsing starts from that committed offset by default.  Only
+// records at the head of a partition can be moved, as committing past a record hides the ones before it.
+type KafkaAdapter struct {
+	client      *kgo.Client
+	admin       *kadm.Client
+	options     []kgo.Opt
+	groupID     string
+	browseFrom  string
+	browseTo    string
+	maxMessages int
+}
+
+// Returns a Kafka adapter:
+// brokers: comma separated seed brokers, e.g. localhost:9092
+// user, pass: optional SASL credentials, mechanism is PLAIN (the default), SCRAM-SHA-256 or SCRAM-SHA-512
+// useTls: connect with TLS
+// groupID: the consumer group whose offsets moves commit, brokerui when empty
+// browseFrom, browseTo: where GetAllMessages starts and stops, see GetMessagesInRange, committed and latest when empty
+func NewKafkaAdapter(ctx context.Context, brokers, user, pass, mechanism string, useTls bool, groupID, browseFrom, browseTo string) (*KafkaAdapter, error) {
+
+	var seeds []string
+	for _, broker := range strings.Split(brokers, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			seeds = append(seeds, broker)
+		}
+	}
+
+	options := []kgo.Opt{kgo.SeedBrokers(seeds...)}
+	if useTls {
+		options = append(options, kgo.DialTLSConfig(&tls.Config{}))
+	}
+	if user != "" {
+		switch strings.ToUpper(mechanism) {
+		case "", "PLAIN":
+			options = append(options, kgo.SASL(plain.Auth{User: user, Pass: pass}.AsMechanism()))
+		case "SCRAM-SHA-256":
+			options = append(options, kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha256Mechanism()))
+		case "SCRAM-SHA-512":
+			options = append(options, kgo.SASL(scram.Auth{User: user, Pass: pass}.AsSha512Mechanism()))
+		default:
+			return nil, fmt.Errorf("unsupported SASL mechanism %s", mechanism)
+		}
+	}
+
+	client, err := kgo.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if groupID == "" {
+		groupID = kafkaDefaultGroupID
+	}
+	if browseFrom == "" {
+		browseFrom = kafkaPositionCommitted
+	}
+	if browseTo == "" {
+		browseTo = kafkaPositionLatest
+	}
+
+	return &KafkaAdapter{
+		client:      client,
+		admin:       kadm.NewClient(client),
+		options:     options,
+		groupID:     groupID,
+		browseFrom:  browseFrom,
+		browseTo:    browseTo,
+		maxMessages: kafkaDefaultMaxMessages,
+	}, nil
+}
+
+func (k *KafkaAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	topics, err := k.admin.ListTopics(ctx)
+	if err != nil {
+		return nil, kafkaError("", err)
+	}
+
+	names := topics.Names()
+	if len(names) == 0 {
+		return []Queue{}, nil
+	}
+
+	startOffsets, err := k.admin.ListStartOffsets(ctx, names...)
+	if err != nil {
+		return nil, kafkaError("", err)
+	}
+	endOffsets, err := k.admin.ListEndOffsets(ctx, names...)
+	if err != nil {
+		return nil, kafkaError("", err)
+	}
+	committed, err := k.fetchCommitted(ctx, names...)
+	if err != nil {
+		log.Printf("unable to fetch offsets of group %s: %s", k.groupID, err)
+	}
+
+	queues := []Queue{}
+	for _, topic := range topics.Sorted() {
+		var size, lag int64
+		for partition := range topic.Partitions {
+			start, _ := startOffsets.Lookup(topic.Topic, partition)
+			end, _ := endOffsets.Lookup(topic.Topic, partition)
+			size += end.Offset - start.Offset
+
+			from := start.Offset
+			if offset, ok := committed.Lookup(topic.Topic, partition); ok && offset.Err == nil && offset.At > from {
+				from = offset.At
+			}
+			if end.Offset > from {
+				lag += end.Offset - from
+			}
+		}
+
+		queues = append(queues, Queue{
+			Name: topic.Topic,
+			Info: map[string]string{
+				"Partitions": strconv.Itoa(len(topic.Partitions)),
+				"Size":       strconv.FormatInt(size, 10),
+				"Lag":        strconv.FormatInt(lag, 10),
+				"GroupID":    k.groupID,
+			},
+		})
+	}
+
+	return queues, nil
+}
+
+// GetAllMessages returns the records between the adapter's configured browse positions
+func (k *KafkaAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	return k.GetMessagesInRange(ctx, queueName, k.browseFrom, k.browseTo)
+}
+
+// GetMessagesInRange returns the records of every partition from the from position up to (not including) the to
+// position, oldest first and capped at the adapter's maximum.  A position is earliest, latest, committed (the
+// consumer group's offset, or earliest when it has none), an offset applied to every partition, or an RFC 3339
+// timestamp.  An empty position uses the adapter's configured one.
+func (k *KafkaAdapter) GetMessagesInRange(ctx context.Context, encodedQueueName string, from string, to string) ([]structs.StandardMessage, error) {
+	topic, _ := url.QueryUnescape(encodedQueueName)
+
+	if from == "" {
+		from = k.browseFrom
+	}
+	if to == "" {
+		to = k.browseTo
+	}
+
+	starts, err := k.resolvePosition(ctx, topic, from)
+	if err != nil {
+		return nil, err
+	}
+	ends, err := k.resolvePosition(ctx, topic, to)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := make(map[int32][2]int64)
+	for partition, start := range starts {
+		ranges[partition] = [2]int64{start, ends[partition]}
+	}
+
+	records, err := k.readRecords(ctx, topic, ranges, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sortKafkaRecords(records)
+	if len(records) > k.maxMessages {
+		records = records[:k.maxMessages]
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, record := range records {
+		stdMessages = append(stdMessages, convertKafkaRecord(record))
+	}
+
+	return stdMessages, nil
+}
+
+// GetFilteredMessages reads only the records between the filter's dates, and applies the rest of the filter to them
+func (k *KafkaAdapter) GetFilteredMessages(ctx context.Context, queueName string, filter MessageFilter) ([]structs.StandardMessage, error) {
+	from, to := "", ""
+	if !filter.FromDate.IsZero() {
+		from = filter.FromDate.Format(time.RFC3339Nano)
+	}
+	if !filter.ToDate.IsZero() {
+		// the to position isn't included, and record timestamps are in milliseconds
+		to = filter.ToDate.Truncate(time.Millisecond).Add(time.Millisecond).Format(time.RFC3339Nano)
+	}
+
+	messages, err := k.GetMessagesInRange(ctx, queueName, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Apply(messages), nil
+}
+
+// GetMessages pages the records between the adapter's configured browse positions.  The cursor holds the offset
+// each partition continues from, e.g. 0:15,1:20.
+func (k *KafkaAdapter) GetMessages(ctx context.Context, encodedQueueName string, page PageRequest) (MessagePage, error) {
+	topic, _ := url.QueryUnescape(encodedQueueName)
+	limit := pageLimit(page)
+
+	ends, err := k.resolvePosition(ctx, topic, k.browseTo)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	var starts map[int32]int64
+	if page.Cursor == "" {
+		starts, err = k.resolvePosition(ctx, topic, k.browseFrom)
+	} else {
+		starts, err = parseKafkaCursor(page.Cursor)
+	}
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	// the whole page could come from any one partition, so up to a page is read from each
+	ranges := make(map[int32][2]int64)
+	next := make(map[int32]int64)
+	for partition, end := range ends {
+		start, ok := starts[partition]
+		if !ok {
+			// partitions added since the first page are left out
+			start = end
+		}
+		next[partition] = start
+		if start+int64(limit) < end {
+			end = start + int64(limit)
+		}
+		ranges[partition] = [2]int64{start, end}
+	}
+
+	records, err := k.readRecords(ctx, topic, ranges, nil)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	sortKafkaRecords(records)
+	if len(records) > limit {
+		records = records[:limit]
+	}
+
+	messagePage := MessagePage{Messages: []structs.StandardMessage{}}
+	for _, record := range records {
+		messagePage.Messages = append(messagePage.Messages, convertKafkaRecord(record))
+		if record.Offset+1 > next[record.Partition] {
+			next[record.Partition] = record.Offset + 1
+		}
+	}
+	for partition, offset := range next {
+		if offset < ends[partition] {
+			messagePage.NextCursor = formatKafkaCursor(next)
+			break
+		}
+	}
+	return messagePage, nil
+}
+
+// sortKafkaRecords puts records from across partitions in the order they were produced
+func sortKafkaRecords(records []*kgo.Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Timestamp.Equal(records[j].Timestamp) {
+			return records[i].Timestamp.Before(records[j].Timestamp)
+		}
+		if records[i].Partition != records[j].Partition {
+			return records[i].Partition < records[j].Partition
+		}
+		return records[i].Offset < records[j].Offset
+	})
+}
+
+// formatKafkaCursor writes the offset each partition continues from, e.g. 0:15,1:20
+func formatKafkaCursor(offsets map[int32]int64) string {
+	partitions := make([]int, 0, len(offsets))
+	for partition := range offsets {
+		partitions = append(partitions, int(partition))
+	}
+	sort.Ints(partitions)
+
+	positions := make([]string, len(partitions))
+	for i, partition := range partitions {
+		positions[i] = fmt.Sprintf("%d:%d", partition, offsets[int32(partition)])
+	}
+	return strings.Join(positions, ",")
+}
+
+// parseKafkaCursor reads the offsets formatKafkaCursor writes
+func parseKafkaCursor(cursor string) (map[int32]int64, error) {
+	offsets := make(map[int32]int64)
+	for _, position := range strings.Split(cursor, ",") {
+		partitionAndOffset := strings.SplitN(position, ":", 2)
+		if len(partitionAndOffset) != 2 {
+			return nil, invalidCursor(cursor)
+		}
+		partition, err := strconv.ParseInt(partitionAndOffset[0], 10, 32)
+		if err != nil {
+			return nil, invalidCursor(cursor)
+		}
+		offset, err := strconv.ParseInt(partitionAndOffset[1], 10, 64)
+		if err != nil || offset < 0 {
+			return nil, invalidCursor(cursor)
+		}
+		offsets[int32(partition)] = offset
+	}
+	return offsets, nil
+}
+
+// resolvePosition turns a browse position into an offset for every partition of the topic, kept between the
+// partition's start and end offsets
+func (k *KafkaAdapter) resolvePosition(ctx context.Context, topic string, position string) (map[int32]int64, error) {
+	startOffsets, err := k.admin.ListStartOffsets(ctx, topic)
+	if err == nil {
+		err = startOffsets.Error()
+	}
+	if err != nil {
+		return nil, kafkaError(topic, err)
+	}
+	endOffsets, err := k.admin.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = endOffsets.Error()
+	}
+	if err != nil {
+		return nil, kafkaError(topic, err)
+	}
+	if len(startOffsets[topic]) == 0 {
+		return nil, queueNotFound(topic)
+	}
+
+	var lookup func(partition int32) int64
+
+	switch strings.ToLower(position) {
+	case kafkaPositionEarliest:
+		lookup = func(partition int32) int64 { return startOffsets[topic][partition].Offset }
+	case kafkaPositionLatest:
+		lookup = func(partition int32) int64 { return endOffsets[topic][partition].Offset }
+	case kafkaPositionCommitted:
+		committed, err := k.fetchCommitted(ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+		lookup = func(partition int32) int64 {
+			if offset, ok := committed.Lookup(topic, partition); ok && offset.Err == nil && offset.At >= 0 {
+				return offset.At
+			}
+			return startOffsets[topic][partition].Offset
+		}
+	default:
+		if offset, err := strconv.ParseInt(position, 10, 64); err == nil {
+			lookup = func(int32) int64 { return offset }
+			break
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, position)
+		if err != nil {
+			return nil, badRequest(fmt.Errorf("position %s is not earliest, latest, committed, an offset or an RFC 3339 timestamp", position))
+		}
+		afterOffsets, err := k.admin.ListOffsetsAfterMilli(ctx, timestamp.UnixNano()/int64(time.Millisecond), topic)
+		if err != nil {
+			return nil, err
+		}
+		lookup = func(partition int32) int64 {
+			if offset, ok := afterOffsets.Lookup(topic, partition); ok && offset.Err == nil && offset.Offset >= 0 {
+				return offset.Offset
+			}
+			return endOffsets[topic][partition].Offset
+		}
+	}
+
+	offsets := make(map[int32]int64)
+	for partition, start := range startOffsets[topic] {
+		offset := lookup(partition)
+		if offset < start.Offset {
+			offset = start.Offset
+		}
+		if end := endOffsets[topic][partition].Offset; offset > end {
+			offset = end
+		}
+		offsets[partition] = offset
+	}
+	return offsets, nil
+}
+
+// readRecords reads each partition's [start, end) offset range with a client of its own, so nothing is committed.
+// With wanted set only those partition offsets are kept.  Reading stops once every range has been read, the
+// maximum number of records has been kept, or no record has arrived for a while (compacted topics have gaps).
+func (k *KafkaAdapter) readRecords(ctx context.Context, topic string, ranges map[int32][2]int64, wanted map[int32]map[int64]bool) ([]*kgo.Record, error) {
+	partitions := make(map[int32]kgo.Offset)
+	for partition, offsets := range ranges {
+		if offsets[0] < offsets[1] {
+			partitions[partition] = kgo.NewOffset().At(offsets[0])
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, nil
+	}
+
+	options := append([]kgo.Opt{}, k.options...)
+	options = append(options, kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topic: partitions}))
+	reader, err := kgo.NewClient(options...)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var records []*kgo.Record
+	remaining := len(partitions)
+	done := make(map[int32]bool)
+
+	for remaining > 0 && len(records) < k.maxMessages {
+		ctxForPoll, cancelFunction := context.WithTimeout(ctx, kafkaReadTimeout)
+		fetches := reader.PollFetches(ctxForPoll)
+		cancelFunction()
+
+		timedOut := false
+		for _, fetchError := range fetches.Errors() {
+			if errors.Is(fetchError.Err, context.DeadlineExceeded) || errors.Is(fetchError.Err, context.Canceled) {
+				timedOut = true
+				continue
+			}
+			return nil, fmt.Errorf("unable to read partition %d of %s: %s", fetchError.Partition, topic, fetchError.Err)
+		}
+		if timedOut {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			break
+		}
+
+		fetches.EachRecord(func(record *kgo.Record) {
+			end := ranges[record.Partition][1]
+			if done[record.Partition] || record.Offset >= end {
+				return
+			}
+			if record.Offset >= end-1 {
+				done[record.Partition] = true
+				remaining--
+			}
+			if wanted != nil && !wanted[record.Partition][record.Offset] {
+				return
+			}
+			records = append(records, record)
+		})
+	}
+
+	return records, nil
+}
+
+func (k *KafkaAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, k.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (k *KafkaAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	var moveErrors []error
+
+	fromTopic, _ := url.QueryUnescape(fromQueue)
+	toTopic, _ := url.QueryUnescape(toQueue)
+
+	records, findErrors := k.findRecords(ctx, fromTopic, messageIDs)
+	moveErrors = append(moveErrors, findErrors...)
+	if len(records) == 0 {
+		return moveErrors
+	}
+
+	heads, err := k.resolvePosition(ctx, fromTopic, kafkaPositionCommitted)
+	if err != nil {
+		return append(moveErrors, err)
+	}
+	records, headErrors := k.headRecords(ctx, fromTopic, heads, records)
+	moveErrors = append(moveErrors, headErrors...)
+	if len(records) == 0 {
+		return moveErrors
+	}
+
+	var copies []*kgo.Record
+	for _, record := range records {
+		copies = append(copies, &kgo.Record{
+			Topic:     toTopic,
+			Key:       record.Key,
+			Value:     record.Value,
+			Headers:   record.Headers,
+			Timestamp: record.Timestamp,
+		})
+	}
+
+	copied := make(map[string]bool)
+	results := k.client.ProduceSync(ctx, copies...)
+	for i, result := range results {
+		if result.Err != nil {
+			log.Printf("error trying to send message %s, error is %s", kafkaMessageID(records[i]), result.Err)
+			moveErrors = append(moveErrors, forMessage(kafkaMessageID(records[i]), result.Err))
+			continue
+		}
+		copied[kafkaMessageID(records[i])] = true
+	}
+
+	// only commit past the records that made it to the destination without a gap from the head of their partition
+	sorted := append([]*kgo.Record{}, records...)
+	sort.Slice(sorted, func(a, b int) bool {
+		if sorted[a].Partition != sorted[b].Partition {
+			return sorted[a].Partition < sorted[b].Partition
+		}
+		return sorted[a].Offset < sorted[b].Offset
+	})
+	commits := make(kadm.Offsets)
+	var committing []*kgo.Record
+	stopped := make(map[int32]bool)
+	for _, record := range sorted {
+		messageID := kafkaMessageID(record)
+		if record.Offset < heads[record.Partition] {
+			continue
+		}
+		if !copied[messageID] {
+			stopped[record.Partition] = true
+			continue
+		}
+		if stopped[record.Partition] {
+			moveErrors = append(moveErrors, forMessage(messageID,
+				fmt.Errorf("message %s was copied to %s but not committed past, as a message before it wasn't copied", messageID, toTopic)))
+			continue
+		}
+		commits.Add(kadm.Offset{Topic: fromTopic, Partition: record.Partition, At: record.Offset + 1, LeaderEpoch: -1})
+		committing = append(committing, record)
+	}
+
+	commitErrors := k.commitOffsets(ctx, fromTopic, commits)
+	for _, record := range committing {
+		if err, ok := commitErrors[record.Partition]; ok {
+			moveErrors = append(moveErrors, forMessage(kafkaMessageID(record),
+				fmt.Errorf("message %s was copied to %s but not committed past: %w", kafkaMessageID(record), toTopic, err)))
+		}
+	}
+	return moveErrors
+}
+
+// headRecords keeps the records that, together with the others being moved, run without a gap from the head of
+// their partition, the offset the consumer group is committed to.  Committing past a record hides every record
+// before it, so moving one with records before it that aren't being moved is refused.  Records the group is already
+// past are kept, as moving them commits nothing.
+func (k *KafkaAdapter) headRecords(ctx context.Context, topic string, heads map[int32]int64, records []*kgo.Record) ([]*kgo.Record, []error) {
+	var headErrors []error
+
+	moving := make(map[string]bool)
+	ranges := make(map[int32][2]int64)
+	for _, record := range records {
+		moving[kafkaMessageID(record)] = true
+		head := heads[record.Partition]
+		if record.Offset >= head && record.Offset >= ranges[record.Partition][1] {
+			ranges[record.Partition] = [2]int64{head, record.Offset + 1}
+		}
+	}
+	if len(ranges) == 0 {
+		return records, nil
+	}
+
+	read, err := k.readRecords(ctx, topic, ranges, nil)
+	if err != nil {
+		return nil, append(headErrors, err)
+	}
+
+	// each partition's records are read in offset order
+	atHead := make(map[string]bool)
+	blocked := make(map[int32]string)
+	for _, record := range read {
+		if _, ok := blocked[record.Partition]; ok {
+			continue
+		}
+		if !moving[kafkaMessageID(record)] {
+			blocked[record.Partition] = kafkaMessageID(record)
+			continue
+		}
+		atHead[kafkaMessageID(record)] = true
+	}
+
+	var kept []*kgo.Record
+	for _, record := range records {
+		messageID := kafkaMessageID(record)
+		if record.Offset < heads[record.Partition] || atHead[messageID] {
+			kept = append(kept, record)
+			continue
+		}
+		if blockedID, ok := blocked[record.Partition]; ok {
+			err = fmt.Errorf("message %s is not at the head of partition %d: message %s before it isn't being moved and would be skipped",
+				messageID, record.Partition, blockedID)
+		} else {
+			err = fmt.Errorf("message %s is not at the head of partition %d: the messages before it couldn't be read", messageID, record.Partition)
+		}
+		headErrors = append(headErrors, forMessage(messageID, err))
+	}
+	return kept, headErrors
+}
+
+func (k *KafkaAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(k.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+// commitOffsets moves the consumer group forward to the given offsets, never back.  It returns the error for each
+// partition whose offset wasn't committed.
+func (k *KafkaAdapter) commitOffsets(ctx context.Context, topic string, offsets kadm.Offsets) map[int32]error {
+	commitErrors := make(map[int32]error)
+	failAll := func(err error) map[int32]error {
+		offsets.Each(func(offset kadm.Offset) {
+			commitErrors[offset.Partition] = err
+		})
+		return commitErrors
+	}
+
+	if len(offsets) == 0 {
+		return commitErrors
+	}
+
+	committed, err := k.fetchCommitted(ctx, topic)
+	if err != nil {
+		return failAll(err)
+	}
+	offsets.KeepFunc(func(offset kadm.Offset) bool {
+		current, ok := committed.Lookup(offset.Topic, offset.Partition)
+		return !ok || current.Err != nil || current.At < offset.At
+	})
+	if len(offsets) == 0 {
+		return commitErrors
+	}
+
+	responses, err := k.admin.CommitOffsets(ctx, k.groupID, offsets)
+	if err != nil {
+		return failAll(err)
+	}
+	responses.EachError(func(response kadm.OffsetResponse) {
+		commitErrors[response.Partition] = fmt.Errorf("unable to commit offset %d of partition %d for group %s: %s",
+			response.At, response.Partition, k.groupID, response.Err)
+	})
+	return commitErrors
+}
+
+// fetchCommitted returns the consumer group's committed offsets on the topics, none when the group has never
+// committed anything
+func (k *KafkaAdapter) fetchCommitted(ctx context.Context, topics ...string) (kadm.OffsetResponses, error) {
+	committed, err := k.admin.FetchOffsetsForTopics(ctx, k.groupID, topics...)
+	if errors.Is(err, kerr.GroupIDNotFound) {
+		return kadm.OffsetResponses{}, nil
+	}
+	return committed, err
+}
+
+// findRecords reads the records with the given IDs, in the order the IDs were given
+func (k *KafkaAdapter) findRecords(ctx context.Context, topic string, messageIDs []string) ([]*kgo.Record, []error) {
+	var findErrors []error
+
+	wanted := make(map[int32]map[int64]bool)
+	ranges := make(map[int32][2]int64)
+	for _, messageID := range messageIDs {
+		partition, offset, err := parseKafkaMessageID(messageID)
+		if err != nil {
+			findErrors = append(findErrors, forMessage(messageID, err))
+			continue
+		}
+		if wanted[partition] == nil {
+			wanted[partition] = make(map[int64]bool)
+			ranges[partition] = [2]int64{offset, offset + 1}
+		}
+		wanted[partition][offset] = true
+		if offset < ranges[partition][0] {
+			ranges[partition] = [2]int64{offset, ranges[partition][1]}
+		}
+		if offset >= ranges[partition][1] {
+			ranges[partition] = [2]int64{ranges[partition][0], offset + 1}
+		}
+	}
+
+	// don't wait for records past the end of a partition
+	endOffsets, err := k.admin.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = endOffsets.Error()
+	}
+	if err != nil {
+		return nil, append(findErrors, err)
+	}
+	for partition, offsets := range ranges {
+		end, _ := endOffsets.Lookup(topic, partition)
+		if offsets[1] > end.Offset {
+			ranges[partition] = [2]int64{offsets[0], end.Offset}
+		}
+	}
+
+	read, err := k.readRecords(ctx, topic, ranges, wanted)
+	if err != nil {
+		return nil, append(findErrors, err)
+	}
+
+	found := make(map[string]*kgo.Record)
+	for _, record := range read {
+		found[kafkaMessageID(record)] = record
+	}
+
+	var records []*kgo.Record
+	for _, messageID := range messageIDs {
+		if _, _, err := parseKafkaMessageID(messageID); err != nil {
+			continue
+		}
+		record, ok := found[messageID]
+		if !ok {
+			findErrors = append(findErrors, messageNotFound(messageID))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, findErrors
+}
+
+// Purge deletes every record currently on the topic
+func (k *KafkaAdapter) Purge(ctx context.Context, encodedQueueName string) error {
+	topic, _ := url.QueryUnescape(encodedQueueName)
+
+	endOffsets, err := k.admin.ListEndOffsets(ctx, topic)
+	if err == nil {
+		err = endOffsets.Error()
+	}
+	if err != nil {
+		return err
+	}
+
+	responses, err := k.admin.DeleteRecords(ctx, endOffsets.Offsets())
+	if err != nil {
+		return err
+	}
+	return responses.Error()
+}
+
+// Capabilities leaves out deleting, as Kafka can't delete single records
+func (k *KafkaAdapter) Capabilities() Capabilities {
+	capabilities := defaultCapabilities(k)
+	capabilities.Delete = false
+	capabilities.Filters = []string{FilterDate}
+	capabilities.Paging = []string{PagingPosition, PagingRange}
+	return capabilities
+}
+
+func (k *KafkaAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return errKafkaDeleteNotSupported
+}
+
+func (k *KafkaAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, k.deleteMany(ctx, queueName, messageIDs))
+}
+
+func (k *KafkaAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	return []error{errKafkaDeleteNotSupported}
+}
+
+func convertKafkaRecord(record *kgo.Record) structs.StandardMessage {
+	headers := make(map[string]string)
+	for _, header := range record.Headers {
+		if value, ok := headers[header.Key]; ok {
+			headers[header.Key] = value + ", " + string(header.Value)
+			continue
+		}
+		headers[header.Key] = string(header.Value)
+	}
+
+	headers["Topic"] = record.Topic
+	headers["Key"] = string(record.Key)
+	headers["Partition"] = strconv.Itoa(int(record.Partition))
+	headers["Offset"] = strconv.FormatInt(record.Offset, 10)
+
+	return structs.StandardMessage{
+		MessageID: kafkaMessageID(record),
+		Timestamp: record.Timestamp.UTC(),
+		Headers:   headers,
+		Body:      string(record.Value),
+	}
+}
+
+// kafkaMessageID identifies a record by its partition and offset, e.g. 0:42
+func kafkaMessageID(record *kgo.Record) string {
+	return fmt.Sprintf("%d:%d", record.Partition, record.Offset)
+}
+
+func parseKafkaMessageID(messageID string) (int32, int64, error) {
+	parts := strings.Split(messageID, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("message ID %s is not partition:offset", messageID)
+	}
+	partition, err := strconv.ParseInt(parts[0], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("message ID %s is not partition:offset", messageID)
+	}
+	offset, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("message ID %s is not partition:offset", messageID)
+	}
+	return int32(partition), offset, nil
+}
+
+// kafkaError marks the error Kafka or the client gave for a request about the topic
+func kafkaError(topic string, err error) error {
+	var netErr net.Error
+	switch {
+	case topic != "" && errors.Is(err, kerr.UnknownTopicOrPartition):
+		return queueNotFound(topic)
+	case errors.Is(err, kerr.TopicAuthorizationFailed), errors.Is(err, kerr.GroupAuthorizationFailed),
+		errors.Is(err, kerr.ClusterAuthorizationFailed), errors.Is(err, kerr.SaslAuthenticationFailed):
+		return unauthorized(err)
+	case errors.As(err, &netErr):
+		return unavailable(err)
+	}
+	return err
+}
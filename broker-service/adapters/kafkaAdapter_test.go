@@ -0,0 +1,340 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// newTestKafkaAdapter starts an in-process fake Kafka cluster with two partition dlq and retry topics
+func newTestKafkaAdapter(t *testing.T, browseFrom string) *KafkaAdapter {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(2, "dlq", "retry"))
+	if err != nil {
+		t.Fatalf("unable to start fake kafka: %v", err)
+	}
+	t.Cleanup(cluster.Close)
+	acceptSimpleCommits(cluster)
+
+	adapter, err := NewKafkaAdapter(context.Background(), strings.Join(cluster.ListenAddrs(), ","), "", "", "", false, "", browseFrom, "")
+	if err != nil {
+		t.Fatalf("NewKafkaAdapter() error = %v", err)
+	}
+	t.Cleanup(adapter.client.Close)
+	return adapter
+}
+
+// acceptSimpleCommits makes the fake cluster store offsets committed outside of a group generation, which Kafka
+// allows for groups without members but the fake only supports for groups that have joined
+func acceptSimpleCommits(cluster *kfake.Cluster) {
+	var mutex sync.Mutex
+	commits := make(map[string]map[int32]int64)
+
+	cluster.ControlKey(8, func(request kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		commitRequest := request.(*kmsg.OffsetCommitRequest)
+		response := commitRequest.ResponseKind().(*kmsg.OffsetCommitResponse)
+		for _, topic := range commitRequest.Topics {
+			responseTopic := kmsg.NewOffsetCommitResponseTopic()
+			responseTopic.Topic = topic.Topic
+			for _, partition := range topic.Partitions {
+				if commits[topic.Topic] == nil {
+					commits[topic.Topic] = make(map[int32]int64)
+				}
+				commits[topic.Topic][partition.Partition] = partition.Offset
+				responsePartition := kmsg.NewOffsetCommitResponseTopicPartition()
+				responsePartition.Partition = partition.Partition
+				responseTopic.Partitions = append(responseTopic.Partitions, responsePartition)
+			}
+			response.Topics = append(response.Topics, responseTopic)
+		}
+		return response, nil, true
+	})
+
+	cluster.ControlKey(9, func(request kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		fetchRequest := request.(*kmsg.OffsetFetchRequest)
+		response := fetchRequest.ResponseKind().(*kmsg.OffsetFetchResponse)
+		// the admin client asks for every topic and picks out the ones it wants
+		responseGroup := kmsg.NewOffsetFetchResponseGroup()
+		responseGroup.Group = kafkaDefaultGroupID
+		for topic, partitions := range commits {
+			responseTopic := kmsg.NewOffsetFetchResponseGroupTopic()
+			responseTopic.Topic = topic
+			for partition, offset := range partitions {
+				responsePartition := kmsg.NewOffsetFetchResponseGroupTopicPartition()
+				responsePartition.Partition = partition
+				responsePartition.Offset = offset
+				responseTopic.Partitions = append(responseTopic.Partitions, responsePartition)
+			}
+			responseGroup.Topics = append(responseGroup.Topics, responseTopic)
+		}
+		response.Groups = append(response.Groups, responseGroup)
+		return response, nil, true
+	})
+}
+
+// produceTestKafkaRecords sends count records to the topic, alternating partitions, each a millisecond apart
+// starting at start
+func produceTestKafkaRecords(t *testing.T, adapter *KafkaAdapter, topic string, count int, start time.Time) {
+	var records []*kgo.Record
+	for i := 0; i < count; i++ {
+		records = append(records, &kgo.Record{
+			Topic:     topic,
+			Partition: int32(i % 2),
+			Key:       []byte("key-" + strconv.Itoa(i)),
+			Value:     []byte("record " + strconv.Itoa(i)),
+			Headers:   []kgo.RecordHeader{{Key: "index", Value: []byte(strconv.Itoa(i))}},
+			Timestamp: start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+
+	producer, err := kgo.NewClient(append(adapter.options, kgo.RecordPartitioner(kgo.ManualPartitioner()))...)
+	if err != nil {
+		t.Fatalf("unable to create producer: %v", err)
+	}
+	defer producer.Close()
+
+	// one batch per record, the fake only finds offsets for a timestamp by batch
+	for _, record := range records {
+		if err := producer.ProduceSync(context.Background(), record).FirstErr(); err != nil {
+			t.Fatalf("unable to produce to %s: %v", topic, err)
+		}
+	}
+}
+
+func TestKafkaAdapter_GetAllQueues(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "")
+	produceTestKafkaRecords(t, adapter, "dlq", 5, time.Now())
+
+	queues, err := adapter.GetAllQueues(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if len(queues) != 2 || queues[0].Name != "dlq" || queues[1].Name != "retry" {
+		t.Fatalf("GetAllQueues() = %v", queues)
+	}
+	if info := queues[0].Info; info["Partitions"] != "2" || info["Size"] != "5" || info["Lag"] != "5" || info["GroupID"] != "brokerui" {
+		t.Errorf("GetAllQueues() dlq info = %v", info)
+	}
+}
+
+func TestKafkaAdapter_GetMessagesInRange(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "")
+	start := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	produceTestKafkaRecords(t, adapter, "dlq", 6, start)
+
+	messages, err := adapter.GetAllMessages(context.Background(), "dlq")
+	if err != nil {
+		t.Fatalf("GetAllMessages() error = %v", err)
+	}
+	if len(messages) != 6 {
+		t.Fatalf("GetAllMessages() returned %d messages, want 6", len(messages))
+	}
+	first := messages[0]
+	if first.MessageID != "0:0" || first.Body != "record 0" || !first.Timestamp.Equal(start) {
+		t.Errorf("GetAllMessages() first message = %v", first)
+	}
+	if first.Headers["Key"] != "key-0" || first.Headers["index"] != "0" || first.Headers["Partition"] != "0" || first.Headers["Offset"] != "0" {
+		t.Errorf("GetAllMessages() first message headers = %v", first.Headers)
+	}
+	if messages[1].MessageID != "1:0" || messages[5].MessageID != "1:2" {
+		t.Errorf("GetAllMessages() not in timestamp order: %s, %s", messages[1].MessageID, messages[5].MessageID)
+	}
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []string
+	}{
+		{"offsets", "1", "2", []string{"0:1", "1:1"}},
+		{"timestamps", start.Add(2 * time.Millisecond).Format(time.RFC3339Nano), start.Add(4 * time.Millisecond).Format(time.RFC3339Nano), []string{"0:1", "1:1"}},
+		{"timestamp to latest", start.Add(4 * time.Millisecond).Format(time.RFC3339Nano), "", []string{"0:2", "1:2"}},
+		{"earliest to offset", "earliest", "1", []string{"0:0", "1:0"}},
+		{"empty range", "latest", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := adapter.GetMessagesInRange(context.Background(), "dlq", tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("GetMessagesInRange() error = %v", err)
+			}
+			var got []string
+			for _, message := range messages {
+				got = append(got, message.MessageID)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("GetMessagesInRange(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := adapter.GetMessagesInRange(context.Background(), "dlq", "yesterday", ""); err == nil {
+		t.Errorf("GetMessagesInRange() with a bad position should fail")
+	}
+}
+
+func TestKafkaAdapter_GetMessages(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "")
+	produceTestKafkaRecords(t, adapter, "dlq", 5, time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC))
+
+	var got []string
+	var cursors []string
+	page := PageRequest{Limit: 2}
+	for i := 0; i < 4; i++ {
+		messagePage, err := adapter.GetMessages(context.Background(), "dlq", page)
+		if err != nil {
+			t.Fatalf("GetMessages(%v) error = %v", page, err)
+		}
+		for _, message := range messagePage.Messages {
+			got = append(got, message.MessageID)
+		}
+		if messagePage.NextCursor == "" {
+			break
+		}
+		cursors = append(cursors, messagePage.NextCursor)
+		page.Cursor = messagePage.NextCursor
+	}
+
+	if strings.Join(got, ",") != "0:0,1:0,0:1,1:1,0:2" {
+		t.Errorf("GetMessages() pages = %v, want the records in timestamp order", got)
+	}
+	if strings.Join(cursors, " ") != "0:1,1:1 0:2,1:2" {
+		t.Errorf("GetMessages() cursors = %v", cursors)
+	}
+	if _, err := adapter.GetMessages(context.Background(), "dlq", PageRequest{Cursor: "0-1"}); !errors.Is(err, ErrBadRequest) {
+		t.Errorf("GetMessages() with a bad cursor error = %v, want a bad request", err)
+	}
+}
+
+func TestKafkaAdapter_Move(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "")
+	produceTestKafkaRecords(t, adapter, "dlq", 4, time.Now())
+	ctx := context.Background()
+
+	// 1:1 has 1:0 before it, which isn't being moved
+	messageIDs := []string{"0:0", "1:1", "0:9"}
+	results := adapter.Move(ctx, "dlq", "retry", messageIDs)
+	wantResults(t, "Move()", results, messageIDs, structs.MessageMoved, structs.MessageFailed, structs.MessageNotFound)
+	if !strings.Contains(results[1].Error, "message 1:0 before it isn't being moved") {
+		t.Errorf("Move() error of 1:1 = %q", results[1].Error)
+	}
+	if results[2].Error != "Did not find message 0:9" {
+		t.Fatalf("Move() error of 0:9 = %q", results[2].Error)
+	}
+
+	moved, err := adapter.GetMessagesInRange(ctx, "retry", "earliest", "")
+	if err != nil {
+		t.Fatalf("GetMessagesInRange(retry) error = %v", err)
+	}
+	if len(moved) != 1 || moved[0].Body != "record 0" || moved[0].Headers["index"] != "0" || moved[0].Headers["Key"] != "key-0" {
+		t.Errorf("records on retry after move = %v", moved)
+	}
+
+	// the group is committed past the moved record only, so the default browse still shows every other record
+	remaining, err := adapter.GetAllMessages(ctx, "dlq")
+	if err != nil {
+		t.Fatalf("GetAllMessages(dlq) error = %v", err)
+	}
+	if got := kafkaMessageIDs(remaining); got != "1:0,0:1,1:1" {
+		t.Errorf("GetAllMessages(dlq) after move = %v, want 1:0,0:1,1:1", got)
+	}
+
+	queues, err := adapter.GetAllQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if queues[0].Info["Size"] != "4" || queues[0].Info["Lag"] != "3" {
+		t.Errorf("dlq info after move = %v", queues[0].Info)
+	}
+
+	// records moved together run from the head
+	messageIDs = []string{"1:1", "1:0"}
+	results = adapter.Move(ctx, "dlq", "retry", messageIDs)
+	wantResults(t, "Move()", results, messageIDs, structs.MessageMoved, structs.MessageMoved)
+	remaining, _ = adapter.GetAllMessages(ctx, "dlq")
+	if got := kafkaMessageIDs(remaining); got != "0:1" {
+		t.Errorf("GetAllMessages(dlq) after moving the head of partition 1 = %v, want 0:1", got)
+	}
+
+	// moving a record the group is past never winds it back
+	if err := adapter.MoveOne(ctx, "dlq", "retry", "0:0"); err != nil {
+		t.Fatalf("MoveOne() error = %v", err)
+	}
+	remaining, _ = adapter.GetAllMessages(ctx, "dlq")
+	if got := kafkaMessageIDs(remaining); got != "0:1" {
+		t.Errorf("GetAllMessages(dlq) after moving an earlier record = %v, want 0:1", got)
+	}
+}
+
+func kafkaMessageIDs(messages []structs.StandardMessage) string {
+	var messageIDs []string
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.MessageID)
+	}
+	return strings.Join(messageIDs, ",")
+}
+
+func TestKafkaAdapter_Purge(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "earliest")
+	produceTestKafkaRecords(t, adapter, "dlq", 4, time.Now())
+
+	if err := adapter.Purge(context.Background(), "dlq"); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+
+	messages, err := adapter.GetAllMessages(context.Background(), "dlq")
+	if err != nil || len(messages) != 0 {
+		t.Errorf("GetAllMessages() after purge = %v, %v", messages, err)
+	}
+}
+
+func TestKafkaAdapter_Delete(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "")
+
+	if err := adapter.DeleteOne(context.Background(), "dlq", "0:0"); err != errKafkaDeleteNotSupported {
+		t.Errorf("DeleteOne() error = %v, want %v", err, errKafkaDeleteNotSupported)
+	}
+	if results := adapter.DeleteMany(context.Background(), "dlq", []string{"0:0"}); len(results) != 1 ||
+		results[0].Status != structs.MessageFailed || !errors.Is(results[0].Err, ErrNotSupported) {
+		t.Errorf("DeleteMany() results = %+v", results)
+	}
+}
+
+func TestKafkaAdapter_GetFilteredMessages(t *testing.T) {
+	adapter := newTestKafkaAdapter(t, "")
+	start := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	produceTestKafkaRecords(t, adapter, "dlq", 6, start)
+
+	messages, err := adapter.GetFilteredMessages(context.Background(), "dlq", MessageFilter{
+		FromDate: start.Add(1 * time.Millisecond),
+		ToDate:   start.Add(4 * time.Millisecond),
+		Body:     "record",
+		Headers:  map[string]string{"Partition": "1"},
+	})
+	if err != nil {
+		t.Fatalf("GetFilteredMessages() error = %v", err)
+	}
+	var got []string
+	for _, message := range messages {
+		got = append(got, message.MessageID)
+	}
+	if strings.Join(got, ",") != "1:0,1:1" {
+		t.Errorf("GetFilteredMessages() = %v, want the partition 1 records from the 1st to the 4th millisecond", got)
+	}
+}
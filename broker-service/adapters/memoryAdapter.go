@@ -0,0 +1,270 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// memoryFixture is the JSON file a MemoryAdapter is seeded from, e.g.
+//
+//	{"queues": [{"name": "orders.dlq", "messages": [{"id": "1", "headers": {"error": "timeout"}, "body": "..."}]}]}
+//
+// A message without an id gets a random one and a message without a timestamp gets the time it was loaded.
+type memoryFixture struct {
+	Queues []struct {
+		Name     string `json:"name"`
+		Messages []struct {
+			ID        string            `json:"id"`
+			Timestamp time.Time         `json:"timestamp"`
+			Headers   map[string]string `json:"headers"`
+			Body      string            `json:"body"`
+		} `json:"messages"`
+	} `json:"queues"`
+}
+
+// MemoryAdapter is a broker held in memory, for demos, frontend development and tests.  Its queues are the ones
+// in its fixture file, and moves, deletes and purges change them until the service restarts.
+type MemoryAdapter struct {
+	mutex  sync.Mutex
+	queues map[string][]structs.StandardMessage
+}
+
+// Returns an in-memory adapter:
+// fixtureFile: optional JSON file with the queues and messages to start with, no queues when empty
+func NewMemoryAdapter(ctx context.Context, fixtureFile string) (*MemoryAdapter, error) {
+	adapter := &MemoryAdapter{
+		queues: make(map[string][]structs.StandardMessage),
+	}
+	if fixtureFile == "" {
+		return adapter, nil
+	}
+
+	contents, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		return nil, err
+	}
+	var fixture memoryFixture
+	if err := json.Unmarshal(contents, &fixture); err != nil {
+		return nil, fmt.Errorf("unable to read fixture %s: %s", fixtureFile, err)
+	}
+
+	loaded := time.Now().UTC()
+	for _, queue := range fixture.Queues {
+		if _, ok := adapter.queues[queue.Name]; ok {
+			return nil, fmt.Errorf("queue %s is in fixture %s more than once", queue.Name, fixtureFile)
+		}
+		messages := []structs.StandardMessage{}
+		seen := make(map[string]bool)
+		for _, message := range queue.Messages {
+			if message.ID == "" {
+				message.ID = uuid.New().String()
+			}
+			if seen[message.ID] {
+				return nil, fmt.Errorf("message %s is on queue %s more than once", message.ID, queue.Name)
+			}
+			seen[message.ID] = true
+			if message.Timestamp.IsZero() {
+				message.Timestamp = loaded
+			}
+			messages = append(messages, structs.StandardMessage{
+				MessageID: message.ID,
+				Timestamp: message.Timestamp,
+				Headers:   message.Headers,
+				Body:      message.Body,
+			})
+		}
+		adapter.queues[queue.Name] = messages
+	}
+
+	return adapter, nil
+}
+
+// AddQueue creates an empty queue, leaving an existing queue as it is
+func (m *MemoryAdapter) AddQueue(queueName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.queues[queueName]; !ok {
+		m.queues[queueName] = []structs.StandardMessage{}
+	}
+}
+
+// Send adds a message with each body to the end of the queue, creating the queue if it doesn't exist, and
+// returns the new messages' IDs
+func (m *MemoryAdapter) Send(queueName string, headers map[string]string, bodies ...string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var ids []string
+	for _, body := range bodies {
+		message := structs.StandardMessage{
+			MessageID: uuid.New().String(),
+			Timestamp: time.Now().UTC(),
+			Headers:   make(map[string]string),
+			Body:      body,
+		}
+		for key, value := range headers {
+			message.Headers[key] = value
+		}
+		m.queues[queueName] = append(m.queues[queueName], message)
+		ids = append(ids, message.MessageID)
+	}
+	return ids
+}
+
+func (m *MemoryAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	queues := []Queue{}
+	for name, messages := range m.queues {
+		queues = append(queues, Queue{
+			Name: name,
+			Info: map[string]string{"Size": strconv.Itoa(len(messages))},
+		})
+	}
+	sort.Slice(queues, func(i, j int) bool { return queues[i].Name < queues[j].Name })
+	return queues, nil
+}
+
+func (m *MemoryAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	messages, err := m.getQueue(queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	// copies, so callers can't change the queue behind the lock
+	stdMessages := make([]structs.StandardMessage, len(messages))
+	for i, message := range messages {
+		stdMessages[i] = copyMemoryMessage(message)
+	}
+	return stdMessages, nil
+}
+
+func (m *MemoryAdapter) GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error) {
+	offset, err := pageOffset(page)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	messages, err := m.GetAllMessages(ctx, queueName)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	return offsetPage(messages, offset, pageLimit(page)), nil
+}
+
+func (m *MemoryAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, m.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (m *MemoryAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var moveErrors []error
+
+	if _, err := m.getQueue(fromQueue); err != nil {
+		return append(moveErrors, err)
+	}
+	if _, err := m.getQueue(toQueue); err != nil {
+		return append(moveErrors, err)
+	}
+	toName, _ := url.QueryUnescape(toQueue)
+
+	for _, messageID := range messageIDs {
+		message, err := m.remove(fromQueue, messageID)
+		if err != nil {
+			moveErrors = append(moveErrors, err)
+			continue
+		}
+		m.queues[toName] = append(m.queues[toName], message)
+	}
+	return moveErrors
+}
+
+func (m *MemoryAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(m.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+func (m *MemoryAdapter) Purge(ctx context.Context, queueName string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, err := m.getQueue(queueName); err != nil {
+		return err
+	}
+	name, _ := url.QueryUnescape(queueName)
+	m.queues[name] = []structs.StandardMessage{}
+	return nil
+}
+
+func (m *MemoryAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return resultError(m.DeleteMany(ctx, queueName, []string{messageID}))
+}
+
+func (m *MemoryAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, m.deleteMany(ctx, queueName, messageIDs))
+}
+
+func (m *MemoryAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var deleteErrors []error
+
+	if _, err := m.getQueue(queueName); err != nil {
+		return append(deleteErrors, err)
+	}
+	for _, messageID := range messageIDs {
+		if _, err := m.remove(queueName, messageID); err != nil {
+			deleteErrors = append(deleteErrors, err)
+		}
+	}
+	return deleteErrors
+}
+
+// getQueue returns the queue's messages, the caller holding the lock
+func (m *MemoryAdapter) getQueue(queueName string) ([]structs.StandardMessage, error) {
+	name, _ := url.QueryUnescape(queueName)
+	messages, ok := m.queues[name]
+	if !ok {
+		return nil, queueNotFound(name)
+	}
+	return messages, nil
+}
+
+// remove takes the message off the queue and returns it, the caller holding the lock
+func (m *MemoryAdapter) remove(queueName string, messageID string) (structs.StandardMessage, error) {
+	name, _ := url.QueryUnescape(queueName)
+	messages := m.queues[name]
+	for i, message := range messages {
+		if message.MessageID == messageID {
+			m.queues[name] = append(messages[:i:i], messages[i+1:]...)
+			return message, nil
+		}
+	}
+	return structs.StandardMessage{}, messageNotFound(messageID)
+}
+
+func copyMemoryMessage(message structs.StandardMessage) structs.StandardMessage {
+	headers := make(map[string]string, len(message.Headers))
+	for key, value := range message.Headers {
+		headers[key] = value
+	}
+	message.Headers = headers
+	return message
+}
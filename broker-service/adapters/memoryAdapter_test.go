@@ -0,0 +1,113 @@
+package adapters
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryAdapter(t *testing.T) {
+	adapter, err := NewMemoryAdapter(context.Background(), "")
+	if err != nil {
+		t.Fatalf("NewMemoryAdapter() error = %v", err)
+	}
+
+	testAdapter(t, adapterTestHarness{
+		adapter: adapter,
+		newQueue: func(t *testing.T) string {
+			queueName := "test-" + uuid.New().String()[:8]
+			adapter.AddQueue(queueName)
+			return queueName
+		},
+		send: func(t *testing.T, queueName string, bodies ...string) {
+			adapter.Send(queueName, nil, bodies...)
+		},
+	})
+}
+
+func TestMemoryAdapter_Fixture(t *testing.T) {
+	ctx := context.Background()
+	adapter, err := NewMemoryAdapter(ctx, "../fixtures/memory.json")
+	if err != nil {
+		t.Fatalf("NewMemoryAdapter() error = %v", err)
+	}
+
+	queues, err := adapter.GetAllQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if len(queues) != 4 || queues[0].Name != "emails" || queues[2].Name != "orders" || queues[3].Info["Size"] != "3" {
+		t.Fatalf("GetAllQueues() = %v", queues)
+	}
+
+	messages, err := adapter.GetAllMessages(ctx, "orders.dlq")
+	if err != nil {
+		t.Fatalf("GetAllMessages() error = %v", err)
+	}
+	first := messages[0]
+	if first.MessageID != "ID:orders-1" || first.Headers["error"] != "payment service timed out" || !first.Timestamp.Equal(time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("GetAllMessages() first message = %v", first)
+	}
+
+	// changing a browsed message leaves the queue alone
+	first.Headers["error"] = "changed"
+	if messages, _ := adapter.GetAllMessages(ctx, "orders.dlq"); messages[0].Headers["error"] != "payment service timed out" {
+		t.Errorf("GetAllMessages() returned the queue's own headers")
+	}
+
+	if err := adapter.MoveOne(ctx, "orders.dlq", "missing", first.MessageID); err == nil {
+		t.Errorf("MoveOne() to an unknown queue should fail")
+	}
+	if _, err := adapter.GetAllMessages(ctx, "missing"); err == nil {
+		t.Errorf("GetAllMessages() of an unknown queue should fail")
+	}
+}
+
+func TestMemoryAdapter_MoveToEscapedName(t *testing.T) {
+	ctx := context.Background()
+	adapter, err := NewMemoryAdapter(ctx, "")
+	if err != nil {
+		t.Fatalf("NewMemoryAdapter() error = %v", err)
+	}
+	adapter.AddQueue("orders.dlq")
+	adapter.AddQueue("a+b")
+	adapter.Send("orders.dlq", nil, "one")
+
+	messages, _ := adapter.GetAllMessages(ctx, "orders.dlq")
+	if err := adapter.MoveOne(ctx, "orders.dlq", "a%2Bb", messages[0].MessageID); err != nil {
+		t.Fatalf("MoveOne() to a%%2Bb error = %v", err)
+	}
+	if moved, err := adapter.GetAllMessages(ctx, "a%2Bb"); err != nil || len(moved) != 1 {
+		t.Errorf("GetAllMessages() of a+b = %v, %v, want the moved message", moved, err)
+	}
+}
+
+func TestMemoryAdapter_BadFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+	}{
+		{"not json", `queues:`},
+		{"repeated queue", `{"queues": [{"name": "a"}, {"name": "a"}]}`},
+		{"repeated message", `{"queues": [{"name": "a", "messages": [{"id": "1"}, {"id": "1"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixtureFile := filepath.Join(t.TempDir(), "fixture.json")
+			if err := ioutil.WriteFile(fixtureFile, []byte(tt.fixture), 0600); err != nil {
+				t.Fatalf("unable to write fixture: %v", err)
+			}
+			if _, err := NewMemoryAdapter(context.Background(), fixtureFile); err == nil {
+				t.Errorf("NewMemoryAdapter() of a bad fixture should fail")
+			}
+		})
+	}
+
+	if _, err := NewMemoryAdapter(context.Background(), "missing.json"); err == nil {
+		t.Errorf("NewMemoryAdapter() of a missing fixture should fail")
+	}
+}
@@ -0,0 +1,235 @@
+package adapters
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JournalState is how far a journaled move got
+type JournalState string
+
+const (
+	// JournalCopying is a move whose copy was sent to the destination but not yet confirmed
+	JournalCopying JournalState = "copying"
+	// JournalCopied is a move whose copy the destination has, with the original not yet off the source queue
+	JournalCopied JournalState = "copied"
+	// JournalUnresolved is a move a crash interrupted before its copy was confirmed, whose original is still on the
+	// source queue and whose copy may or may not be on the destination, left for an operator to check
+	JournalUnresolved JournalState = "unresolved"
+	// JournalDone is a move that is finished, one way or the other
+	JournalDone JournalState = "done"
+)
+
+// JournalEntry is the move of one message
+type JournalEntry struct {
+	ID        string
+	FromQueue string
+	ToQueue   string
+	MessageID string
+	// Fingerprint is a hash of the original's properties and payload, telling it apart from other messages with
+	// the same ID
+	Fingerprint string
+	State       JournalState
+	// Time is when the move began
+	Time time.Time
+}
+
+// MoveJournal is a write-ahead log, kept in a file, of moves that aren't finished.  An entry is written before a
+// message is copied to its destination, again once the broker has confirmed the copy and again once the original is
+// off the source queue, each write reaching the disk before the move goes on.  After a crash the journal tells which
+// moves left a message on both queues.  A nil journal records nothing.
+type MoveJournal struct {
+	mutex   sync.Mutex
+	path    string
+	file    *os.File
+	entries map[string]JournalEntry
+}
+
+// OpenMoveJournal opens the journal in the file, creating it when it doesn't exist, and rewrites it with only the
+// moves that aren't finished
+func OpenMoveJournal(path string) (*MoveJournal, error) {
+	entries, err := readMoveJournal(path)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &MoveJournal{path: path, entries: entries}
+	if err := journal.compact(); err != nil {
+		return nil, err
+	}
+
+	journal.file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open the move journal %s: %w", path, err)
+	}
+	return journal, nil
+}
+
+// readMoveJournal reads the latest state of every unfinished move.  A line the service was writing when it died is
+// skipped, as the move it was about went no further.
+func readMoveJournal(path string) (map[string]JournalEntry, error) {
+	entries := make(map[string]JournalEntry)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the move journal %s: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Printf("skipping unreadable line in the move journal %s: %s", path, err)
+			continue
+		}
+		if entry.State == JournalDone {
+			delete(entries, entry.ID)
+			continue
+		}
+		entries[entry.ID] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the move journal %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// compact writes the unfinished moves to a new file and puts it in place of the journal
+func (j *MoveJournal) compact() error {
+	temp := j.path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to rewrite the move journal %s: %w", j.path, err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range j.Entries() {
+		line, _ := json.Marshal(entry)
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to rewrite the move journal %s: %w", j.path, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to rewrite the move journal %s: %w", j.path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("unable to rewrite the move journal %s: %w", j.path, err)
+	}
+	return os.Rename(temp, j.path)
+}
+
+// Entries returns the moves that aren't finished, oldest first
+func (j *MoveJournal) Entries() []JournalEntry {
+	entries := []JournalEntry{}
+	if j == nil {
+		return entries
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	for _, entry := range j.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		if entries[a].Time.Equal(entries[b].Time) {
+			return entries[a].ID < entries[b].ID
+		}
+		return entries[a].Time.Before(entries[b].Time)
+	})
+	return entries
+}
+
+// begin records that a message is about to be copied to its destination, returning the ID of the move's entry
+func (j *MoveJournal) begin(fromQueue string, toQueue string, messageID string, fingerprint string) (string, error) {
+	if j == nil {
+		return "", nil
+	}
+
+	entry := JournalEntry{
+		ID:          uuid.New().String(),
+		FromQueue:   fromQueue,
+		ToQueue:     toQueue,
+		MessageID:   messageID,
+		Fingerprint: fingerprint,
+		State:       JournalCopying,
+		Time:        time.Now().UTC(),
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if err := j.write(entry); err != nil {
+		return "", err
+	}
+	j.entries[entry.ID] = entry
+	return entry.ID, nil
+}
+
+// mark records how far the move got
+func (j *MoveJournal) mark(id string, state JournalState) error {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry, ok := j.entries[id]
+	if !ok {
+		return fmt.Errorf("no move %s in the journal", id)
+	}
+	entry.State = state
+	if err := j.write(entry); err != nil {
+		return err
+	}
+
+	if state == JournalDone {
+		delete(j.entries, id)
+	} else {
+		j.entries[id] = entry
+	}
+	return nil
+}
+
+// write appends the entry to the file and waits for it to reach the disk
+func (j *MoveJournal) write(entry JournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("unable to write to the move journal %s: %w", j.path, err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("unable to write to the move journal %s: %w", j.path, err)
+	}
+	return nil
+}
+
+// Close closes the journal's file
+func (j *MoveJournal) Close() error {
+	if j == nil {
+		return nil
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	return j.file.Close()
+}
@@ -0,0 +1,60 @@
+package adapters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moves.journal")
+
+	journal, err := OpenMoveJournal(path)
+	if err != nil {
+		t.Fatalf("OpenMoveJournal() error = %v", err)
+	}
+	copied, err := journal.begin("orders.dlq", "orders", "order-1", "3f2a")
+	if err != nil {
+		t.Fatalf("begin() error = %v", err)
+	}
+	finished, _ := journal.begin("orders.dlq", "orders", "order-2", "")
+	copying, _ := journal.begin("orders.dlq", "orders", "order-3", "")
+	if err := journal.mark(copied, JournalCopied); err != nil {
+		t.Fatalf("mark() error = %v", err)
+	}
+	if err := journal.mark(finished, JournalDone); err != nil {
+		t.Fatalf("mark() error = %v", err)
+	}
+	journal.Close()
+
+	// the service died while writing a line
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	file.WriteString(`{"ID":"` + copying + `","State":"do`)
+	file.Close()
+
+	journal, err = OpenMoveJournal(path)
+	if err != nil {
+		t.Fatalf("OpenMoveJournal() again error = %v", err)
+	}
+	defer journal.Close()
+	entries := journal.Entries()
+	if len(entries) != 2 || entries[0].ID != copied || entries[0].State != JournalCopied || entries[0].MessageID != "order-1" ||
+		entries[0].Fingerprint != "3f2a" || entries[1].ID != copying || entries[1].State != JournalCopying {
+		t.Fatalf("Entries() after reopening = %+v, want order-1 copied and order-3 copying", entries)
+	}
+
+	if err := journal.mark(copied, JournalDone); err != nil {
+		t.Fatalf("mark() error = %v", err)
+	}
+	if err := journal.mark(finished, JournalDone); err == nil {
+		t.Errorf("mark() of a finished move should fail")
+	}
+	if entries := journal.Entries(); len(entries) != 1 || entries[0].ID != copying {
+		t.Errorf("Entries() = %+v, want only order-3", entries)
+	}
+
+	var none *MoveJournal
+	if id, err := none.begin("a", "b", "c", "d"); id != "" || err != nil || len(none.Entries()) != 0 {
+		t.Errorf("a nil journal recorded a move")
+	}
+}
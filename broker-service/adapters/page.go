@@ -0,0 +1,62 @@
+package adapters
+
+import (
+	"fmt"
+	"strconv"
+
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+const (
+	// DefaultPageLimit is how many messages a page holds when the request doesn't say
+	DefaultPageLimit = 100
+	// MaxPageLimit is the most messages a page can hold
+	MaxPageLimit = 1000
+)
+
+// pageLimit returns how many messages the page should hold
+func pageLimit(page PageRequest) int {
+	switch {
+	case page.Limit <= 0:
+		return DefaultPageLimit
+	case page.Limit > MaxPageLimit:
+		return MaxPageLimit
+	}
+	return page.Limit
+}
+
+// pageOffset reads an offset cursor, the position in the queue the page starts at.  Brokers without a position of
+// their own page by offset, so messages removed from the front of the queue between pages shift the rest forward.
+func pageOffset(page PageRequest) (int, error) {
+	if page.Cursor == "" {
+		return 0, nil
+	}
+	offset, err := strconv.Atoi(page.Cursor)
+	if err != nil || offset < 0 {
+		return 0, invalidCursor(page.Cursor)
+	}
+	return offset, nil
+}
+
+// invalidCursor returns the error for a cursor the broker never handed out
+func invalidCursor(cursor string) error {
+	return badRequest(fmt.Errorf("invalid cursor %s", cursor))
+}
+
+// offsetPage returns the page of messages starting at offset.  messages must start at the head of the queue and hold
+// one message past the page when there is one, so the page can tell whether it is the last.
+func offsetPage(messages []structs.StandardMessage, offset int, limit int) MessagePage {
+	page := MessagePage{Messages: []structs.StandardMessage{}}
+	if offset >= len(messages) {
+		return page
+	}
+
+	end := offset + limit
+	if end < len(messages) {
+		page.NextCursor = strconv.Itoa(end)
+	} else {
+		end = len(messages)
+	}
+	page.Messages = append(page.Messages, messages[offset:end]...)
+	return page
+}
@@ -0,0 +1,728 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+const (
+	// pulsarDefaultMaxMessages caps how many messages a single browse reads from a topic
+	pulsarDefaultMaxMessages = 1000
+	// pulsarReadTimeout is how long a reader waits for the next message before giving up on the topic
+	pulsarReadTimeout = 5 * time.Second
+)
+
+// PulsarAdapter browses and manages Apache Pulsar topics.
+// Topics are found through the admin REST API and read with a Reader, which never acknowledges anything.
+// Pulsar can't remove single messages from a topic, so deleting a message acknowledges (skips) it on the
+// configured subscription and a move publishes a copy to the destination topic before skipping the original.
+type PulsarAdapter struct {
+	client       pulsar.Client
+	adminURL     string
+	token        string
+	namespaces   []string
+	subscription string
+	maxMessages  int
+	httpClient   *http.Client
+}
+
+// Returns a Pulsar adapter:
+// brokerURL: the pulsar:// (or pulsar+ssl://) service URL, used to read and publish messages
+// adminURL: the HTTP URL of the admin REST API, used to find topics and their statistics
+// token: optional JWT used for both the service and the admin API
+// namespaces: optional comma separated tenant/namespace list, every namespace of every tenant when empty
+// subscription: the subscription whose backlog is browsed, and that deletes and moves skip messages on
+func NewPulsarAdapter(ctx context.Context, brokerURL, adminURL, token, namespaces, subscription string) (*PulsarAdapter, error) {
+
+	clientOptions := pulsar.ClientOptions{
+		URL:               brokerURL,
+		OperationTimeout:  30 * time.Second,
+		ConnectionTimeout: 10 * time.Second,
+	}
+	if token != "" {
+		clientOptions.Authentication = pulsar.NewAuthenticationToken(token)
+	}
+
+	client, err := pulsar.NewClient(clientOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var namespaceList []string
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			namespaceList = append(namespaceList, namespace)
+		}
+	}
+
+	return &PulsarAdapter{
+		client:       client,
+		adminURL:     strings.TrimRight(adminURL, "/"),
+		token:        token,
+		namespaces:   namespaceList,
+		subscription: subscription,
+		maxMessages:  pulsarDefaultMaxMessages,
+		httpClient:   &http.Client{Timeout: time.Second * 10},
+	}, nil
+}
+
+// pulsarTopicStats is the part of the admin API's topic stats the adapter uses
+type pulsarTopicStats struct {
+	MsgInCounter  int64 `json:"msgInCounter"`
+	StorageSize   int64 `json:"storageSize"`
+	Subscriptions map[string]struct {
+		MsgBacklog int64 `json:"msgBacklog"`
+	} `json:"subscriptions"`
+	Metadata struct {
+		Partitions int `json:"partitions"`
+	} `json:"metadata"`
+}
+
+// pulsarInternalStats is the part of the admin API's internal topic stats that says what a subscription has
+// already acknowledged
+type pulsarInternalStats struct {
+	Cursors map[string]struct {
+		MarkDeletePosition          string `json:"markDeletePosition"`
+		IndividuallyDeletedMessages string `json:"individuallyDeletedMessages"`
+	} `json:"cursors"`
+}
+
+// pulsarPartitionMetadata is returned by the admin API for a (possibly) partitioned topic
+type pulsarPartitionMetadata struct {
+	Partitions int `json:"partitions"`
+}
+
+func (p *PulsarAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+
+	namespaces := p.namespaces
+	if len(namespaces) == 0 {
+		var err error
+		namespaces, err = p.getAllNamespaces(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	queues := []Queue{}
+
+	for _, namespace := range namespaces {
+		var topics []string
+		if err := p.getAdmin(ctx, fmt.Sprintf("/admin/v2/persistent/%s", namespace), &topics); err != nil {
+			return nil, err
+		}
+
+		var partitionedTopics []string
+		if err := p.getAdmin(ctx, fmt.Sprintf("/admin/v2/persistent/%s/partitioned", namespace), &partitionedTopics); err != nil {
+			return nil, err
+		}
+
+		partitioned := make(map[string]bool)
+		for _, topic := range partitionedTopics {
+			partitioned[topic] = true
+			topics = append(topics, topic)
+		}
+
+		sort.Strings(topics)
+
+		for _, topic := range topics {
+			// partitions are listed as topics of their own, they show up as part of their partitioned topic
+			if name, _, ok := splitPartition(topic); ok && partitioned[name] {
+				continue
+			}
+
+			queueName := strings.TrimPrefix(topic, "persistent://")
+			info, err := p.getTopicInfo(ctx, queueName, partitioned[topic])
+			if err != nil {
+				log.Printf("unable to get stats for topic %s: %s", topic, err)
+				info = map[string]string{}
+			}
+
+			queues = append(queues, Queue{
+				Name: queueName,
+				Info: info,
+			})
+		}
+	}
+
+	return queues, nil
+}
+
+func (p *PulsarAdapter) getAllNamespaces(ctx context.Context) ([]string, error) {
+	var tenants []string
+	if err := p.getAdmin(ctx, "/admin/v2/tenants", &tenants); err != nil {
+		return nil, err
+	}
+
+	var namespaces []string
+	for _, tenant := range tenants {
+		var tenantNamespaces []string
+		if err := p.getAdmin(ctx, fmt.Sprintf("/admin/v2/namespaces/%s", tenant), &tenantNamespaces); err != nil {
+			return nil, err
+		}
+		namespaces = append(namespaces, tenantNamespaces...)
+	}
+	return namespaces, nil
+}
+
+// getTopicInfo turns a topic's stats into the Info map shown by the UI.  Size is the backlog of the configured
+// subscription, or the largest backlog of any subscription when there isn't one.
+func (p *PulsarAdapter) getTopicInfo(ctx context.Context, queueName string, partitioned bool) (map[string]string, error) {
+	statsPath := fmt.Sprintf("/admin/v2/persistent/%s/stats", queueName)
+	if partitioned {
+		statsPath = fmt.Sprintf("/admin/v2/persistent/%s/partitioned-stats", queueName)
+	}
+
+	var stats pulsarTopicStats
+	if err := p.getAdmin(ctx, statsPath, &stats); err != nil {
+		return nil, err
+	}
+
+	var subscriptions []string
+	var backlog int64
+	for name, subscription := range stats.Subscriptions {
+		subscriptions = append(subscriptions, name)
+		if p.subscription == "" && subscription.MsgBacklog > backlog {
+			backlog = subscription.MsgBacklog
+		}
+	}
+	if p.subscription != "" {
+		backlog = stats.Subscriptions[p.subscription].MsgBacklog
+	}
+	sort.Strings(subscriptions)
+
+	info := map[string]string{
+		"Size":          strconv.FormatInt(backlog, 10),
+		"MsgInCounter":  strconv.FormatInt(stats.MsgInCounter, 10),
+		"StorageSize":   strconv.FormatInt(stats.StorageSize, 10),
+		"Subscriptions": strings.Join(subscriptions, ","),
+	}
+	if partitioned {
+		info["Partitions"] = strconv.Itoa(stats.Metadata.Partitions)
+	}
+
+	return info, nil
+}
+
+func (p *PulsarAdapter) GetAllMessages(ctx context.Context, encodedQueueName string) ([]structs.StandardMessage, error) {
+	queueName, _ := url.QueryUnescape(encodedQueueName)
+
+	messages, err := p.readMessages(ctx, queueName, nil, p.maxMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, msg := range messages {
+		stdMessages = append(stdMessages, convertPulsarMessage(msg))
+	}
+
+	return stdMessages, nil
+}
+
+// GetMessages pages the topic by offset, reading it from the start up to the end of the page
+func (p *PulsarAdapter) GetMessages(ctx context.Context, encodedQueueName string, page PageRequest) (MessagePage, error) {
+	queueName, _ := url.QueryUnescape(encodedQueueName)
+
+	offset, err := pageOffset(page)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	limit := pageLimit(page)
+
+	messages, err := p.readMessages(ctx, queueName, nil, offset+limit+1)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, msg := range messages {
+		stdMessages = append(stdMessages, convertPulsarMessage(msg))
+	}
+	return offsetPage(stdMessages, offset, limit), nil
+}
+
+// readMessages reads up to limit messages of the topic without acknowledging anything.  Each partition is read up to
+// limit, and the first limit messages of them all by publish time are returned.  When a subscription is configured
+// only its backlog is read, otherwise everything the topic still holds.  With wanted set, only those message IDs are
+// returned and reading stops once they have all been found.
+func (p *PulsarAdapter) readMessages(ctx context.Context, queueName string, wanted map[string]bool, limit int) ([]pulsar.Message, error) {
+	partitions, err := p.getPartitions(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := []string{queueName}
+	if partitions > 0 {
+		topics = make([]string, 0, partitions)
+		for i := 0; i < partitions; i++ {
+			topics = append(topics, fmt.Sprintf("%s-partition-%d", queueName, i))
+		}
+	}
+
+	var messages []pulsar.Message
+	found := 0
+
+	for _, topic := range topics {
+		acked, start, err := p.getAcknowledged(ctx, topic)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := p.client.CreateReader(pulsar.ReaderOptions{
+			Topic:                   "persistent://" + topic,
+			StartMessageID:          start,
+			StartMessageIDInclusive: false,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create reader for %s: %s", topic, err)
+		}
+
+		read := 0
+		for reader.HasNext() && read < limit {
+			if wanted != nil && found == len(wanted) {
+				break
+			}
+
+			ctxForRead, cancelFunction := context.WithTimeout(ctx, pulsarReadTimeout)
+			msg, err := reader.Next(ctxForRead)
+			cancelFunction()
+			if err != nil {
+				reader.Close()
+				return nil, fmt.Errorf("unable to read from %s: %s", topic, err)
+			}
+
+			if acked(msg.ID()) {
+				continue
+			}
+			if wanted != nil {
+				if !wanted[formatPulsarMessageID(pulsarMessageID(msg))] {
+					continue
+				}
+				found++
+			}
+
+			messages = append(messages, msg)
+			read++
+		}
+
+		reader.Close()
+	}
+
+	sortPulsarMessages(messages)
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// sortPulsarMessages puts messages read from several partitions in publish order
+func sortPulsarMessages(messages []pulsar.Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		if !messages[i].PublishTime().Equal(messages[j].PublishTime()) {
+			return messages[i].PublishTime().Before(messages[j].PublishTime())
+		}
+		return pulsarMessageID(messages[i]).PartitionIdx() < pulsarMessageID(messages[j]).PartitionIdx()
+	})
+}
+
+// getPartitions returns how many partitions the topic has, zero for a non-partitioned topic
+func (p *PulsarAdapter) getPartitions(ctx context.Context, queueName string) (int, error) {
+	var metadata pulsarPartitionMetadata
+	if err := p.getAdmin(ctx, fmt.Sprintf("/admin/v2/persistent/%s/partitions", queueName), &metadata); err != nil {
+		var adminErr *pulsarAdminError
+		if errors.As(err, &adminErr) && adminErr.Status == http.StatusNotFound {
+			return 0, queueNotFound(queueName)
+		}
+		return 0, err
+	}
+	return metadata.Partitions, nil
+}
+
+// getAcknowledged works out which messages the configured subscription has already acknowledged on a single
+// (partition) topic.  It returns a check for acknowledged messages and the position to start reading after.
+func (p *PulsarAdapter) getAcknowledged(ctx context.Context, topic string) (func(pulsar.MessageID) bool, pulsar.MessageID, error) {
+	nothingAcked := func(pulsar.MessageID) bool { return false }
+
+	if p.subscription == "" {
+		return nothingAcked, pulsar.EarliestMessageID(), nil
+	}
+
+	var stats pulsarInternalStats
+	if err := p.getAdmin(ctx, fmt.Sprintf("/admin/v2/persistent/%s/internalStats", topic), &stats); err != nil {
+		return nil, nil, err
+	}
+
+	cursor, ok := stats.Cursors[p.subscription]
+	if !ok {
+		return nothingAcked, pulsar.EarliestMessageID(), nil
+	}
+
+	markDelete, ok := parsePulsarPosition(cursor.MarkDeletePosition)
+	if !ok || markDelete[0] < 0 {
+		return nothingAcked, pulsar.EarliestMessageID(), nil
+	}
+
+	ranges := parsePulsarDeletedRanges(cursor.IndividuallyDeletedMessages)
+	acked := func(id pulsar.MessageID) bool {
+		position := [2]int64{id.LedgerID(), id.EntryID()}
+		for _, deleted := range ranges {
+			if comparePulsarPositions(position, deleted[0]) > 0 && comparePulsarPositions(position, deleted[1]) <= 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	return acked, pulsar.NewMessageID(markDelete[0], markDelete[1], -1, 0), nil
+}
+
+func (p *PulsarAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, p.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (p *PulsarAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	var moveErrors []error
+
+	fromQueueName, _ := url.QueryUnescape(fromQueue)
+	toQueueName, _ := url.QueryUnescape(toQueue)
+
+	if p.subscription == "" {
+		return append(moveErrors, notSupported(errors.New("no subscription configured to skip moved messages on")))
+	}
+
+	messages, err := p.findMessages(ctx, fromQueueName, messageIDs)
+	if err != nil {
+		return append(moveErrors, err)
+	}
+
+	producer, err := p.client.CreateProducer(pulsar.ProducerOptions{
+		Topic: "persistent://" + toQueueName,
+	})
+	if err != nil {
+		return append(moveErrors, fmt.Errorf("unable to create producer for %s: %s", toQueueName, err))
+	}
+	defer producer.Close()
+
+	var sent []pulsar.MessageID
+	for _, messageID := range messageIDs {
+		msg, ok := messages[messageID]
+		if !ok {
+			moveErrors = append(moveErrors, messageNotFound(messageID))
+			continue
+		}
+
+		_, err := producer.Send(ctx, &pulsar.ProducerMessage{
+			Payload:     msg.Payload(),
+			Key:         msg.Key(),
+			OrderingKey: msg.OrderingKey(),
+			Properties:  msg.Properties(),
+			EventTime:   msg.EventTime(),
+		})
+		if err != nil {
+			log.Printf("error trying to send message %s, error is %s", messageID, err)
+			moveErrors = append(moveErrors, forMessage(messageID, err))
+			continue
+		}
+
+		sent = append(sent, pulsarMessageID(msg))
+	}
+
+	return append(moveErrors, p.acknowledge(ctx, fromQueueName, sent)...)
+}
+
+func (p *PulsarAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(p.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+// Purge skips the whole backlog of the configured subscription, or of every subscription on the topic when
+// no subscription is configured
+func (p *PulsarAdapter) Purge(ctx context.Context, encodedQueueName string) error {
+	queueName, _ := url.QueryUnescape(encodedQueueName)
+
+	subscriptions := []string{p.subscription}
+	if p.subscription == "" {
+		partitions, err := p.getPartitions(ctx, queueName)
+		if err != nil {
+			return err
+		}
+		info, err := p.getTopicInfo(ctx, queueName, partitions > 0)
+		if err != nil {
+			return err
+		}
+		subscriptions = strings.Split(info["Subscriptions"], ",")
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription == "" {
+			continue
+		}
+		path := fmt.Sprintf("/admin/v2/persistent/%s/subscription/%s/skip_all", queueName, url.PathEscape(subscription))
+		if err := p.postAdmin(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PulsarAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return resultError(p.DeleteMany(ctx, queueName, []string{messageID}))
+}
+
+// DeleteMany skips the messages on the configured subscription
+func (p *PulsarAdapter) DeleteMany(ctx context.Context, encodedQueueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, p.deleteMany(ctx, encodedQueueName, messageIDs))
+}
+
+func (p *PulsarAdapter) deleteMany(ctx context.Context, encodedQueueName string, messageIDs []string) []error {
+	var deleteErrors []error
+
+	queueName, _ := url.QueryUnescape(encodedQueueName)
+
+	if p.subscription == "" {
+		return append(deleteErrors, notSupported(errors.New("no subscription configured to skip deleted messages on")))
+	}
+
+	messages, err := p.findMessages(ctx, queueName, messageIDs)
+	if err != nil {
+		return append(deleteErrors, err)
+	}
+
+	var toAcknowledge []pulsar.MessageID
+	for _, messageID := range messageIDs {
+		msg, ok := messages[messageID]
+		if !ok {
+			deleteErrors = append(deleteErrors, messageNotFound(messageID))
+			continue
+		}
+		toAcknowledge = append(toAcknowledge, pulsarMessageID(msg))
+	}
+
+	return append(deleteErrors, p.acknowledge(ctx, queueName, toAcknowledge)...)
+}
+
+// findMessages reads the subscription backlog for the given message IDs
+func (p *PulsarAdapter) findMessages(ctx context.Context, queueName string, messageIDs []string) (map[string]pulsar.Message, error) {
+	wanted := make(map[string]bool)
+	for _, messageID := range messageIDs {
+		wanted[messageID] = true
+	}
+
+	messages, err := p.readMessages(ctx, queueName, wanted, p.maxMessages)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]pulsar.Message)
+	for _, msg := range messages {
+		found[formatPulsarMessageID(pulsarMessageID(msg))] = msg
+	}
+	return found, nil
+}
+
+// acknowledge acknowledges the messages on the configured subscription, which takes them out of its backlog
+func (p *PulsarAdapter) acknowledge(ctx context.Context, queueName string, messageIDs []pulsar.MessageID) []error {
+	var ackErrors []error
+
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	consumer, err := p.client.Subscribe(pulsar.ConsumerOptions{
+		Topic:             "persistent://" + queueName,
+		SubscriptionName:  p.subscription,
+		Type:              pulsar.Shared,
+		ReceiverQueueSize: 1,
+	})
+	if err != nil {
+		return append(ackErrors, fmt.Errorf("unable to subscribe to %s as %s: %s", queueName, p.subscription, err))
+	}
+	defer consumer.Close()
+
+	for _, messageID := range messageIDs {
+		// messages read from a non-partitioned topic carry no partition, its consumer is partition 0
+		partition := messageID.PartitionIdx()
+		if partition < 0 {
+			partition = 0
+		}
+		id := pulsar.NewMessageID(messageID.LedgerID(), messageID.EntryID(), messageID.BatchIdx(), partition)
+		if err := consumer.AckID(id); err != nil {
+			ackErrors = append(ackErrors, forMessage(formatPulsarMessageID(messageID),
+				fmt.Errorf("unable to acknowledge message %s: %s", formatPulsarMessageID(messageID), err)))
+		}
+	}
+
+	return ackErrors
+}
+
+// pulsarAdminError is an unsuccessful response from the admin API
+type pulsarAdminError struct {
+	Status  int
+	Message string
+}
+
+func (e *pulsarAdminError) Error() string {
+	return e.Message
+}
+
+func (e *pulsarAdminError) Is(target error) bool {
+	kind := httpStatusKind(e.Status)
+	return kind != nil && target == kind
+}
+
+func (p *PulsarAdapter) getAdmin(ctx context.Context, path string, result interface{}) error {
+	body, err := p.doAdmin(ctx, "GET", path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (p *PulsarAdapter) postAdmin(ctx context.Context, path string) error {
+	_, err := p.doAdmin(ctx, "POST", path)
+	return err
+}
+
+func (p *PulsarAdapter) doAdmin(ctx context.Context, method string, path string) ([]byte, error) {
+	url := p.adminURL + path
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &pulsarAdminError{Status: resp.StatusCode,
+			Message: fmt.Sprintf("pulsar admin %s %s returned %s: %s", method, url, resp.Status, string(body))}
+	}
+	return body, nil
+}
+
+func convertPulsarMessage(msg pulsar.Message) structs.StandardMessage {
+	headers := make(map[string]string)
+	for key, val := range msg.Properties() {
+		headers[key] = val
+	}
+
+	id := msg.ID()
+	headers["Topic"] = msg.Topic()
+	headers["Key"] = msg.Key()
+	headers["Producer Name"] = msg.ProducerName()
+	headers["Redelivery Count"] = strconv.FormatUint(uint64(msg.RedeliveryCount()), 10)
+	headers["Ledger ID"] = strconv.FormatInt(id.LedgerID(), 10)
+	headers["Entry ID"] = strconv.FormatInt(id.EntryID(), 10)
+	headers["Partition"] = strconv.Itoa(int(id.PartitionIdx()))
+	headers["Batch Index"] = strconv.Itoa(int(id.BatchIdx()))
+	if !msg.EventTime().IsZero() {
+		headers["Event Time"] = msg.EventTime().UTC().Format(time.RFC3339Nano)
+	}
+
+	return structs.StandardMessage{
+		MessageID: formatPulsarMessageID(pulsarMessageID(msg)),
+		Timestamp: msg.PublishTime().UTC(),
+		Headers:   headers,
+		Body:      string(msg.Payload()),
+	}
+}
+
+// formatPulsarMessageID writes a message ID as ledgerId:entryId:partition:batchIndex, the partition being -1
+// for a non-partitioned topic
+func formatPulsarMessageID(id pulsar.MessageID) string {
+	return fmt.Sprintf("%d:%d:%d:%d", id.LedgerID(), id.EntryID(), id.PartitionIdx(), id.BatchIdx())
+}
+
+// pulsarMessageID returns the message's ID with its partition taken from the topic it was read from; readers
+// on a single partition topic don't know which partition of the partitioned topic they are reading
+func pulsarMessageID(msg pulsar.Message) pulsar.MessageID {
+	id := msg.ID()
+	_, partition, _ := splitPartition(msg.Topic())
+	return pulsar.NewMessageID(id.LedgerID(), id.EntryID(), id.BatchIdx(), int32(partition))
+}
+
+var pulsarPartitionPattern = regexp.MustCompile(`^(.*)-partition-(\d+)$`)
+
+// splitPartition splits a partition's topic name into the partitioned topic's name and the partition index
+func splitPartition(topic string) (string, int, bool) {
+	match := pulsarPartitionPattern.FindStringSubmatch(topic)
+	if match == nil {
+		return topic, -1, false
+	}
+	partition, _ := strconv.Atoi(match[2])
+	return match[1], partition, true
+}
+
+// parsePulsarPosition parses a ledgerId:entryId cursor position
+func parsePulsarPosition(position string) ([2]int64, bool) {
+	parts := strings.Split(strings.TrimSpace(position), ":")
+	if len(parts) != 2 {
+		return [2]int64{}, false
+	}
+	ledger, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return [2]int64{}, false
+	}
+	entry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return [2]int64{}, false
+	}
+	return [2]int64{ledger, entry}, true
+}
+
+var pulsarDeletedRangePattern = regexp.MustCompile(`\((-?\d+:-?\d+)\.\.(-?\d+:-?\d+)\]`)
+
+// parsePulsarDeletedRanges parses a cursor's individually deleted messages, e.g. [(3:1..3:4], (3:6..3:9]].
+// Each range excludes its start and includes its end.
+func parsePulsarDeletedRanges(ranges string) [][2][2]int64 {
+	var parsed [][2][2]int64
+	for _, match := range pulsarDeletedRangePattern.FindAllStringSubmatch(ranges, -1) {
+		from, ok := parsePulsarPosition(match[1])
+		if !ok {
+			continue
+		}
+		to, ok := parsePulsarPosition(match[2])
+		if !ok {
+			continue
+		}
+		parsed = append(parsed, [2][2]int64{from, to})
+	}
+	return parsed
+}
+
+func comparePulsarPositions(a [2]int64, b [2]int64) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
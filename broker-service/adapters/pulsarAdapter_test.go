@@ -0,0 +1,271 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// newFakePulsarAdmin serves the admin API calls GetAllQueues and Purge make, recording every POST
+func newFakePulsarAdmin(t *testing.T) (*httptest.Server, func() []string) {
+	var mutex sync.Mutex
+	var posts []string
+
+	responses := map[string]interface{}{
+		"/admin/v2/tenants":                               []string{"public"},
+		"/admin/v2/namespaces/public":                     []string{"public/default"},
+		"/admin/v2/persistent/public/default":             []string{"persistent://public/default/orders", "persistent://public/default/events-partition-0", "persistent://public/default/events-partition-1"},
+		"/admin/v2/persistent/public/default/partitioned": []string{"persistent://public/default/events"},
+		"/admin/v2/persistent/public/default/orders/stats": map[string]interface{}{
+			"msgInCounter": 12,
+			"storageSize":  2048,
+			"subscriptions": map[string]interface{}{
+				"brokerui": map[string]interface{}{"msgBacklog": 3},
+				"audit":    map[string]interface{}{"msgBacklog": 7},
+			},
+		},
+		"/admin/v2/persistent/public/default/orders/partitions": map[string]interface{}{"partitions": 0},
+		"/admin/v2/persistent/public/default/events/partitioned-stats": map[string]interface{}{
+			"msgInCounter":  5,
+			"storageSize":   512,
+			"subscriptions": map[string]interface{}{"brokerui": map[string]interface{}{"msgBacklog": 5}},
+			"metadata":      map[string]interface{}{"partitions": 2},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost {
+			mutex.Lock()
+			posts = append(posts, r.URL.Path)
+			mutex.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string(nil), posts...)
+	}
+}
+
+func TestPulsarAdapter_GetAllQueues(t *testing.T) {
+	server, _ := newFakePulsarAdmin(t)
+
+	tests := []struct {
+		name         string
+		subscription string
+		ordersSize   string
+	}{
+		{"configured subscription", "brokerui", "3"},
+		{"largest backlog", "", "7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter, err := NewPulsarAdapter(context.Background(), "pulsar://localhost:6650", server.URL, "secret", "", tt.subscription)
+			if err != nil {
+				t.Fatalf("NewPulsarAdapter() error = %v", err)
+			}
+			defer adapter.client.Close()
+
+			queues, err := adapter.GetAllQueues(context.Background())
+			if err != nil {
+				t.Fatalf("GetAllQueues() error = %v", err)
+			}
+			if len(queues) != 2 {
+				t.Fatalf("GetAllQueues() returned %d queues, want 2: %v", len(queues), queues)
+			}
+
+			events, orders := queues[0], queues[1]
+			if events.Name != "public/default/events" || orders.Name != "public/default/orders" {
+				t.Fatalf("GetAllQueues() names = %s, %s", events.Name, orders.Name)
+			}
+			if events.Info["Partitions"] != "2" || events.Info["Size"] != "5" {
+				t.Errorf("GetAllQueues() events info = %v", events.Info)
+			}
+			if orders.Info["Size"] != tt.ordersSize || orders.Info["Subscriptions"] != "audit,brokerui" || orders.Info["StorageSize"] != "2048" {
+				t.Errorf("GetAllQueues() orders info = %v", orders.Info)
+			}
+		})
+	}
+}
+
+func TestPulsarAdapter_Purge(t *testing.T) {
+	server, posts := newFakePulsarAdmin(t)
+
+	adapter, err := NewPulsarAdapter(context.Background(), "pulsar://localhost:6650", server.URL, "secret", "public/default", "")
+	if err != nil {
+		t.Fatalf("NewPulsarAdapter() error = %v", err)
+	}
+	defer adapter.client.Close()
+
+	if err := adapter.Purge(context.Background(), "public%2Fdefault%2Forders"); err != nil {
+		t.Fatalf("Purge() error = %v", err)
+	}
+
+	got := posts()
+	sort.Strings(got)
+	want := []string{
+		"/admin/v2/persistent/public/default/orders/subscription/audit/skip_all",
+		"/admin/v2/persistent/public/default/orders/subscription/brokerui/skip_all",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Purge() posted %v, want %v", got, want)
+	}
+}
+
+// fakePulsarMessage is a message read from a partition, published at a time
+type fakePulsarMessage struct {
+	pulsar.Message
+	topic       string
+	id          pulsar.MessageID
+	publishTime time.Time
+}
+
+func (m fakePulsarMessage) Topic() string          { return m.topic }
+func (m fakePulsarMessage) ID() pulsar.MessageID   { return m.id }
+func (m fakePulsarMessage) PublishTime() time.Time { return m.publishTime }
+
+func TestSortPulsarMessages(t *testing.T) {
+	at := time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+	message := func(partition int, entry int64, seconds int) pulsar.Message {
+		return fakePulsarMessage{
+			topic:       fmt.Sprintf("persistent://public/default/events-partition-%d", partition),
+			id:          pulsar.NewMessageID(1, entry, -1, -1),
+			publishTime: at.Add(time.Duration(seconds) * time.Second),
+		}
+	}
+
+	// partition 0 was read first, all of it
+	messages := []pulsar.Message{message(0, 1, 0), message(0, 2, 2), message(0, 3, 3), message(1, 1, 1), message(1, 2, 3)}
+	sortPulsarMessages(messages)
+
+	var order []string
+	for _, msg := range messages {
+		order = append(order, formatPulsarMessageID(pulsarMessageID(msg)))
+	}
+	if fmt.Sprint(order) != "[1:1:0:-1 1:1:1:-1 1:2:0:-1 1:3:0:-1 1:2:1:-1]" {
+		t.Errorf("sortPulsarMessages() = %v, want publish order with partition 0 first on a tie", order)
+	}
+}
+
+func TestParsePulsarDeletedRanges(t *testing.T) {
+	ranges := parsePulsarDeletedRanges("[(3:1..3:4], (3:6..3:9]]")
+	if len(ranges) != 2 {
+		t.Fatalf("parsePulsarDeletedRanges() = %v", ranges)
+	}
+	if ranges[0] != [2][2]int64{{3, 1}, {3, 4}} || ranges[1] != [2][2]int64{{3, 6}, {3, 9}} {
+		t.Errorf("parsePulsarDeletedRanges() = %v", ranges)
+	}
+	if ranges := parsePulsarDeletedRanges("[]"); len(ranges) != 0 {
+		t.Errorf("parsePulsarDeletedRanges(empty) = %v", ranges)
+	}
+}
+
+// newTestPulsarAdapter returns an adapter on the broker given by PULSAR_TEST_URL and PULSAR_TEST_ADMIN_URL,
+// e.g. a standalone broker started with: docker run -p 6650:6650 -p 8080:8080 apachepulsar/pulsar bin/pulsar standalone
+func newTestPulsarAdapter(t *testing.T) *PulsarAdapter {
+	brokerURL := os.Getenv("PULSAR_TEST_URL")
+	adminURL := os.Getenv("PULSAR_TEST_ADMIN_URL")
+	if brokerURL == "" || adminURL == "" {
+		t.Skip("PULSAR_TEST_URL and PULSAR_TEST_ADMIN_URL not set")
+	}
+
+	adapter, err := NewPulsarAdapter(context.Background(), brokerURL, adminURL, os.Getenv("PULSAR_TEST_TOKEN"), "public/default", "brokerui")
+	if err != nil {
+		t.Fatalf("NewPulsarAdapter() error = %v", err)
+	}
+	t.Cleanup(adapter.client.Close)
+	return adapter
+}
+
+// createTestPulsarTopic creates a topic with the adapter's subscription on it and sends count messages
+func createTestPulsarTopic(t *testing.T, adapter *PulsarAdapter, count int) string {
+	topic := fmt.Sprintf("public/default/brokerui-test-%d", time.Now().UnixNano())
+
+	consumer, err := adapter.client.Subscribe(pulsar.ConsumerOptions{
+		Topic:            "persistent://" + topic,
+		SubscriptionName: adapter.subscription,
+	})
+	if err != nil {
+		t.Fatalf("unable to subscribe to %s: %v", topic, err)
+	}
+	consumer.Close()
+
+	producer, err := adapter.client.CreateProducer(pulsar.ProducerOptions{Topic: "persistent://" + topic})
+	if err != nil {
+		t.Fatalf("unable to create producer for %s: %v", topic, err)
+	}
+	defer producer.Close()
+
+	for i := 0; i < count; i++ {
+		_, err := producer.Send(context.Background(), &pulsar.ProducerMessage{
+			Payload:    []byte("message " + strconv.Itoa(i)),
+			Key:        strconv.Itoa(i),
+			Properties: map[string]string{"index": strconv.Itoa(i)},
+		})
+		if err != nil {
+			t.Fatalf("unable to send to %s: %v", topic, err)
+		}
+	}
+	return topic
+}
+
+func TestPulsarAdapter_MoveAndDelete(t *testing.T) {
+	adapter := newTestPulsarAdapter(t)
+	ctx := context.Background()
+
+	from := createTestPulsarTopic(t, adapter, 3)
+	to := createTestPulsarTopic(t, adapter, 0)
+
+	messages, err := adapter.GetAllMessages(ctx, from)
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("GetAllMessages() = %v, %v", messages, err)
+	}
+	if messages[0].Headers["index"] != "0" || messages[0].Headers["Key"] != "0" || messages[0].Body != "message 0" {
+		t.Errorf("GetAllMessages() first message = %v", messages[0])
+	}
+
+	if err := adapter.MoveOne(ctx, from, to, messages[0].MessageID); err != nil {
+		t.Fatalf("MoveOne() error = %v", err)
+	}
+	if err := resultError(adapter.DeleteMany(ctx, from, []string{messages[1].MessageID})); err != nil {
+		t.Fatalf("DeleteMany() error = %v", err)
+	}
+
+	remaining, err := adapter.GetAllMessages(ctx, from)
+	if err != nil || len(remaining) != 1 || remaining[0].MessageID != messages[2].MessageID {
+		t.Errorf("GetAllMessages(from) after move and delete = %v, %v", remaining, err)
+	}
+
+	moved, err := adapter.GetAllMessages(ctx, to)
+	if err != nil || len(moved) != 1 || moved[0].Body != "message 0" || moved[0].Headers["index"] != "0" {
+		t.Errorf("GetAllMessages(to) after move = %v, %v", moved, err)
+	}
+
+	if err := adapter.DeleteOne(ctx, from, "1:2:-1:-1"); err == nil {
+		t.Errorf("DeleteOne() of an unknown message should fail")
+	}
+}
@@ -1,7 +1,10 @@
 package adapters
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,10 +12,12 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	amqp9 "github.com/streadway/amqp"
 	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
 )
@@ -23,7 +28,9 @@ type RabbitMQAdapter struct {
 	consoleURL       string
 	host             string
 	url              string
+	supervisor       *connectionSupervisor
 	getAMQPPublisher func() (RabbitMQChannel, error)
+	journal          *MoveJournal
 }
 
 // RabbitMQChannel is an interface with the methods on amqp.Channel that are necessary for sending notifications.
@@ -32,6 +39,7 @@ type RabbitMQChannel interface {
 	io.Closer
 	Confirm(noWait bool) error
 	NotifyConfirm(ack, nack chan uint64) (chan uint64, chan uint64)
+	NotifyReturn(c chan amqp9.Return) chan amqp9.Return
 	Publish(exchange, key string, mandatory, immediate bool, msg amqp9.Publishing) error
 	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp9.Table) error
 	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp9.Table) (amqp9.Queue, error)
@@ -51,21 +59,20 @@ var RabbitMQGetMessagesRequestBody = struct {
 	"50000",            //limit number of messages to get at once
 	"ack_requeue_true", //don't actually remove messages from queue
 	"auto",
-	50000,
+	rabbitBrowseTruncate,
 }
 
-// RabbitMQRemoveOneMessageRequestBody is used to remove a single message from a queue
-var RabbitMQRemoveOneMessageRequestBody = struct {
-	Count    string `json:"count"`
-	Ackmode  string `json:"ackmode"`
-	Encoding string `json:"encoding"`
-	Truncate int    `json:"truncate"`
-}{
-	"1",                 //limit number of messages to get at once
-	"ack_requeue_false", //remove message from queue
-	"auto",
-	50000,
-}
+// rabbitBrowseTruncate is how many bytes of each payload the management API returns when a queue is browsed
+const rabbitBrowseTruncate = 50000
+
+// rabbitMQReceiveTimeout is how long a pass over a queue waits for its next message before taking the queue to be done
+const rabbitMQReceiveTimeout = 1 * time.Second
+
+// rabbitMQSettlePrefetch is how many messages a move or delete lets the broker hand over before they are settled
+const rabbitMQSettlePrefetch = 1000
+
+// rabbitMovedFromHeader is the header a moved message carries the name of the queue it was moved from in
+const rabbitMovedFromHeader = "x-brokerui-moved-from"
 
 // RabbitQueueInfo meta data for a RabbitMQ queue
 type RabbitQueueInfo []struct {
@@ -76,50 +83,306 @@ type RabbitQueueInfo []struct {
 }
 
 // RabbitMessages for parsing a collection of messages
-type RabbitMessages []struct {
-	QueueName  string `json:"routing_key"`
-	Properties struct {
-		Headers struct {
-			CorrelationID string `json:"correlationID"`
-			MessageID     string `json:"messageID"`
-			Timestamp     string `json:"timestamp"`
-		} `json:"headers"`
-	} `json:"properties"`
-	Body string `json:"payload"`
+type RabbitMessages []RabbitMessage
+
+// RabbitMessage is a message as the management API returns it
+type RabbitMessage struct {
+	QueueName       string                  `json:"routing_key"`
+	Exchange        string                  `json:"exchange"`
+	Redelivered     bool                    `json:"redelivered"`
+	Properties      RabbitMessageProperties `json:"properties"`
+	Body            string                  `json:"payload"`
+	PayloadEncoding string                  `json:"payload_encoding"`
+	// PayloadBytes is the size of the whole payload, which the management API may have truncated
+	PayloadBytes int `json:"payload_bytes"`
+}
+
+// RabbitMessageProperties are the AMQP 0.9.1 basic properties of a message, named as the management API names them
+type RabbitMessageProperties struct {
+	ContentType     string                 `json:"content_type,omitempty"`
+	ContentEncoding string                 `json:"content_encoding,omitempty"`
+	DeliveryMode    uint8                  `json:"delivery_mode,omitempty"`
+	Priority        uint8                  `json:"priority,omitempty"`
+	CorrelationID   string                 `json:"correlation_id,omitempty"`
+	ReplyTo         string                 `json:"reply_to,omitempty"`
+	Expiration      string                 `json:"expiration,omitempty"`
+	MessageID       string                 `json:"message_id,omitempty"`
+	Timestamp       int64                  `json:"timestamp,omitempty"`
+	Type            string                 `json:"type,omitempty"`
+	UserID          string                 `json:"user_id,omitempty"`
+	AppID           string                 `json:"app_id,omitempty"`
+	ClusterID       string                 `json:"cluster_id,omitempty"`
+	Headers         map[string]interface{} `json:"headers,omitempty"`
+}
+
+// UnmarshalJSON reads properties the management API gives as an empty list when a message has none, and keeps
+// numbers in headers as json.Number so integers aren't turned into floats
+func (p *RabbitMessageProperties) UnmarshalJSON(data []byte) error {
+	*p = RabbitMessageProperties{}
+	if isEmptyJSONList(data) {
+		return nil
+	}
+
+	type properties RabbitMessageProperties
+	var raw struct {
+		properties
+		Headers json.RawMessage `json:"headers"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = RabbitMessageProperties(raw.properties)
+	if len(raw.Headers) == 0 || isEmptyJSONList(raw.Headers) || string(raw.Headers) == "null" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw.Headers))
+	decoder.UseNumber()
+	return decoder.Decode(&p.Headers)
+}
+
+func isEmptyJSONList(data []byte) bool {
+	return string(bytes.Join(bytes.Fields(data), nil)) == "[]"
+}
+
+// rabbitTimestampLayout is the layout of the timestamp header messages published by the service used to carry
+const rabbitTimestampLayout = "2006-01-02T15:04:05.000Z"
+
+// messageID is the ID the message is known by: the messageID header the service has always read, else the AMQP
+// message ID, else an ID made from the message's properties, payload size and the part of the payload browsing
+// returns.  Reading the queue through the management API doesn't change any of those, so the made up ID stays the
+// same from one read to the next, and a message received over AMQP gets the same ID.
+func (m RabbitMessage) messageID() string {
+	if id := m.header("messageID"); id != "" {
+		return id
+	}
+	if m.Properties.MessageID != "" {
+		return m.Properties.MessageID
+	}
+
+	properties, _ := json.Marshal(m.Properties)
+	payload, err := m.payload()
+	if err != nil {
+		payload = []byte(m.Body)
+	}
+	size := m.PayloadBytes
+	if size == 0 {
+		size = len(payload)
+	}
+	if len(payload) > rabbitBrowseTruncate {
+		payload = payload[:rabbitBrowseTruncate]
+	}
+	hash := sha256.New()
+	hash.Write(properties)
+	fmt.Fprintf(hash, "%d:", size)
+	hash.Write(payload)
+	return fmt.Sprintf("sha256:%x", hash.Sum(nil)[:16])
+}
+
+// fingerprint is a hash of the message's properties and whole payload, telling a received message apart from others
+// with the same ID.  Browsing may truncate the payload, so it is only taken of messages received over AMQP.
+func (m RabbitMessage) fingerprint() string {
+	properties, _ := json.Marshal(m.Properties)
+	payload, err := m.payload()
+	if err != nil {
+		payload = []byte(m.Body)
+	}
+	hash := sha256.New()
+	hash.Write(properties)
+	hash.Write(payload)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// payload returns the message's payload as the bytes it was published with
+func (m RabbitMessage) payload() ([]byte, error) {
+	if m.PayloadEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(m.Body)
+	}
+	return []byte(m.Body), nil
+}
+
+// movedFrom returns a copy of the message with a header naming the queue it is being moved from, the name already
+// unescaped
+func (m RabbitMessage) movedFrom(queueName string) RabbitMessage {
+	headers := make(map[string]interface{}, len(m.Properties.Headers)+1)
+	for name, value := range m.Properties.Headers {
+		headers[name] = value
+	}
+	headers[rabbitMovedFromHeader] = queueName
+	m.Properties.Headers = headers
+	return m
+}
+
+// publishing rebuilds the message as it was published, with every property and header.  The user ID is only kept
+// when it is the adapter's own, as RabbitMQ refuses messages claiming to come from another user.
+func (m RabbitMessage) publishing(username string) (amqp9.Publishing, error) {
+	body, err := m.payload()
+	if err != nil {
+		return amqp9.Publishing{}, fmt.Errorf("unable to decode the payload of message %s: %w", m.messageID(), err)
+	}
+
+	properties := m.Properties
+	msg := amqp9.Publishing{
+		ContentType:     properties.ContentType,
+		ContentEncoding: properties.ContentEncoding,
+		DeliveryMode:    properties.DeliveryMode,
+		Priority:        properties.Priority,
+		CorrelationId:   properties.CorrelationID,
+		ReplyTo:         properties.ReplyTo,
+		Expiration:      properties.Expiration,
+		MessageId:       properties.MessageID,
+		Type:            properties.Type,
+		AppId:           properties.AppID,
+		Body:            body,
+	}
+	if properties.Timestamp != 0 {
+		msg.Timestamp = time.Unix(properties.Timestamp, 0).UTC()
+	}
+	if properties.UserID == username {
+		msg.UserId = properties.UserID
+	}
+	if len(properties.Headers) > 0 {
+		msg.Headers = rabbitHeaderTable(properties.Headers)
+	}
+	return msg, nil
+}
+
+// rabbitHeaderTable turns headers read from the management API back into an AMQP table
+func rabbitHeaderTable(headers map[string]interface{}) amqp9.Table {
+	table := make(amqp9.Table, len(headers))
+	for name, value := range headers {
+		table[name] = rabbitHeaderValue(value)
+	}
+	return table
+}
+
+func rabbitHeaderValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		return rabbitHeaderTable(value)
+	case []interface{}:
+		values := make([]interface{}, len(value))
+		for i, item := range value {
+			values[i] = rabbitHeaderValue(item)
+		}
+		return values
+	}
+	return value
+}
+
+// timestamp is when the message was published, from its AMQP timestamp or else the timestamp header
+func (m RabbitMessage) timestamp() time.Time {
+	if m.Properties.Timestamp != 0 {
+		return time.Unix(m.Properties.Timestamp, 0).UTC()
+	}
+	timestamp, _ := time.Parse(rabbitTimestampLayout, m.header("timestamp"))
+	return timestamp
+}
+
+// header returns the text of a header, or an empty string when the message doesn't have it
+func (m RabbitMessage) header(name string) string {
+	value, ok := m.Properties.Headers[name]
+	if !ok {
+		return ""
+	}
+	return rabbitHeaderText(value)
+}
+
+// headers lists every header and basic property of the message for display
+func (m RabbitMessage) headers() map[string]string {
+	headers := make(map[string]string)
+	for name, value := range m.Properties.Headers {
+		headers[name] = rabbitHeaderText(value)
+	}
+
+	properties := m.Properties
+	for name, value := range map[string]string{
+		"Content Type":     properties.ContentType,
+		"Content Encoding": properties.ContentEncoding,
+		"Correlation ID":   properties.CorrelationID,
+		"Reply To":         properties.ReplyTo,
+		"Expiration":       properties.Expiration,
+		"Message ID":       properties.MessageID,
+		"Type":             properties.Type,
+		"User ID":          properties.UserID,
+		"App ID":           properties.AppID,
+		"Cluster ID":       properties.ClusterID,
+		"Exchange":         m.Exchange,
+		"Routing Key":      m.QueueName,
+	} {
+		if value != "" {
+			headers[name] = value
+		}
+	}
+	if properties.DeliveryMode != 0 {
+		headers["Delivery Mode"] = strconv.Itoa(int(properties.DeliveryMode))
+	}
+	if properties.Priority != 0 {
+		headers["Priority"] = strconv.Itoa(int(properties.Priority))
+	}
+	if properties.Timestamp != 0 {
+		headers["Timestamp"] = strconv.FormatInt(properties.Timestamp, 10)
+	}
+	headers["Redelivered"] = strconv.FormatBool(m.Redelivered)
+	return headers
+}
+
+// rabbitHeaderText shows strings as they are and any other header value, such as the x-death table, as JSON
+func rabbitHeaderText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	text, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(text)
 }
 
 // RabbitPublishMessageRequestBody for publishing a message to a queue
 type RabbitPublishMessageRequestBody struct {
-	Properties struct {
-		Headers struct {
-			CorrelationID string `json:"correlationID"`
-			MessageID     string `json:"messageID"`
-			Timestamp     string `json:"timestamp"`
-		} `json:"headers"`
-	} `json:"properties"`
-	RoutingKey      string `json:"routing_key"`
-	Payload         string `json:"payload"`
-	PayloadEncoding string `json:"payload_encoding"`
+	Properties      RabbitMessageProperties `json:"properties"`
+	RoutingKey      string                  `json:"routing_key"`
+	Payload         string                  `json:"payload"`
+	PayloadEncoding string                  `json:"payload_encoding"`
 }
 
 // Returns a RabbitMQ AMQP0.9 adapter:
-// brokerURL: the AMQP URL for the broker (required if planning on using operations Move, MoveOne, DeleteOne, DeleteMany)
+// brokerURL: comma separated AMQP URLs for the broker, tried in order until one connects (required if planning on
+// using operations Move, MoveOne, DeleteOne, DeleteMany)
 // consoleURL: the HTTP URL for the broker (required for all operations)
 // username, pwd: username and password credentials required to connect
 // host: virtual hostname for rabbitMQ
+// The AMQP connection is supervised, so the adapter connects once the broker comes up and reconnects after an outage.
 func NewRabbitMQAdapter(ctx context.Context, brokerURL, consoleURL, username, pwd, host string) (*RabbitMQAdapter, error) {
 
-	url := brokerURL
-	url = strings.Replace(url, "amqp://", "", 1)
-	fullURL := username + ":" + pwd + "@" + url
+	dial := func(url string) (io.Closer, <-chan error, error) {
+		dialURL, err := rabbitMQDialURL(url, username, pwd)
+		if err != nil {
+			return nil, nil, err
+		}
+		amqpConn, err := amqp9.Dial(dialURL)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	dialURL := fmt.Sprintf("amqp://%s", fullURL)
-	amqpConn, err := amqp9.Dial(dialURL)
-	if err != nil {
-		log.Printf("Error connecting to AMQP 0.9 broker with %s: %s", dialURL, err)
-	} else {
-		log.Println("Connected to AMQP 0.9 broker with", dialURL)
+		closed := make(chan error, 1)
+		notifyClose := amqpConn.NotifyClose(make(chan *amqp9.Error, 1))
+		go func() {
+			if amqpErr, ok := <-notifyClose; ok && amqpErr != nil {
+				closed <- amqpErr
+			}
+			close(closed)
+		}()
+		return amqpConn, closed, nil
 	}
+	supervisor := newConnectionSupervisor("AMQP 0.9 broker", strings.Split(brokerURL, ","), dial)
 
 	return &RabbitMQAdapter{
 		username:   username,
@@ -127,40 +390,102 @@ func NewRabbitMQAdapter(ctx context.Context, brokerURL, consoleURL, username, pw
 		consoleURL: consoleURL,
 		url:        brokerURL,
 		host:       host,
+		supervisor: supervisor,
 		getAMQPPublisher: func() (RabbitMQChannel, error) {
-			return amqpConn.Channel()
+			conn, err := supervisor.get()
+			if err != nil {
+				return nil, err
+			}
+			channel, err := conn.(*amqp9.Connection).Channel()
+			if err != nil {
+				supervisor.failed(conn, err)
+				return nil, err
+			}
+			return channel, nil
 		}}, nil
 }
 
+// rabbitMQDialURL adds the credentials to a broker URL, which may leave out the amqp:// scheme
+func rabbitMQDialURL(brokerURL, username, pwd string) (string, error) {
+	brokerURL = strings.TrimSpace(brokerURL)
+	if !strings.Contains(brokerURL, "://") {
+		brokerURL = "amqp://" + brokerURL
+	}
+	dialURL, err := neturl.Parse(brokerURL)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		dialURL.User = neturl.UserPassword(username, pwd)
+	}
+	return dialURL.String(), nil
+}
+
+// Capabilities limits moves to the adapter's virtual host
+func (r *RabbitMQAdapter) Capabilities() Capabilities {
+	capabilities := defaultCapabilities(r)
+	capabilities.MoveScope = "vhost"
+	capabilities.Journal = r.journal != nil
+	return capabilities
+}
+
+// ConnectionStatus returns the state of the AMQP connection used to move and delete messages
+func (r *RabbitMQAdapter) ConnectionStatus() ConnectionStatus {
+	return r.supervisor.ConnectionStatus()
+}
+
 func (r *RabbitMQAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	return r.getMessages(ctx, queueName, RabbitMQGetMessagesRequestBody.Count)
+}
+
+// GetMessages pages the queue by offset.  The management API can only get messages from the head of the queue,
+// so it gets everything up to the end of the page and requeues it.
+func (r *RabbitMQAdapter) GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error) {
+	offset, err := pageOffset(page)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	limit := pageLimit(page)
+
+	messages, err := r.getMessages(ctx, queueName, strconv.Itoa(offset+limit+1))
+	if err != nil {
+		return MessagePage{}, err
+	}
+	return offsetPage(messages, offset, limit), nil
+}
+
+// getMessages gets up to count messages from the head of the queue through the management API, requeueing them
+func (r *RabbitMQAdapter) getMessages(ctx context.Context, queueName string, count string) ([]structs.StandardMessage, error) {
 
 	httpClient := &http.Client{Timeout: time.Second * 10}
 	var resp *http.Response
 
 	url := fmt.Sprintf("%s/api/queues/%s/%s/get", r.consoleURL, r.host, queueName)
 	log.Printf("attempting to get queue information from %s", url)
-	body, err := json.Marshal(RabbitMQGetMessagesRequestBody)
+	requestBody := RabbitMQGetMessagesRequestBody
+	requestBody.Count = count
+	body, err := json.Marshal(requestBody)
 	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
 	if err != nil {
 		log.Printf("we were unable to get a http.NewRequest for consoleURL %s, error is %s", url, err.Error())
-		return nil, nil
+		return nil, err
 	}
 	req.SetBasicAuth(r.username, r.pwd)
 
 	resp, err = httpClient.Do(req)
 	if err != nil {
 		log.Printf("error returned from this attempt was %s", err.Error())
-		return nil, nil
+		return nil, unavailable(err)
 	}
 
 	if resp == nil {
 		log.Printf(fmt.Sprintf("we were not able to retrieve info about the queue from the console consoleURL: %s", url))
-		return nil, nil
+		return nil, unavailable(errors.New(fmt.Sprintf("we were not able to retrieve info about the queue from the console consoleURL: %s", url)))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil
+		return nil, httpStatusError("the RabbitMQ management API", resp, queueName)
 	}
 
 	respbody, err := ioutil.ReadAll(resp.Body)
@@ -180,8 +505,9 @@ func (r *RabbitMQAdapter) GetAllMessages(ctx context.Context, queueName string)
 
 	for _, message := range messages {
 		queueInfoResult = append(queueInfoResult, structs.StandardMessage{
-			MessageID: message.Properties.Headers.MessageID,
-			Headers:   map[string]string{"CorrelationID": message.Properties.Headers.CorrelationID},
+			MessageID: message.messageID(),
+			Timestamp: message.timestamp(),
+			Headers:   message.headers(),
 			Body:      message.Body,
 		})
 	}
@@ -199,7 +525,7 @@ func (r *RabbitMQAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		log.Printf(fmt.Sprintf("bad status code"))
-		return nil, nil
+		return nil, httpStatusError("the RabbitMQ management API", resp, "")
 	}
 
 	respbody, err := ioutil.ReadAll(resp.Body)
@@ -228,76 +554,116 @@ func (r *RabbitMQAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
 	return queueInfoResult, nil
 }
 
-func (r *RabbitMQAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
-	errors := []error{}
-	for _, messageID := range messageIDs {
-		if err := r.MoveOne(ctx, fromQueue, toQueue, messageID); err != nil {
-			errors = append(errors, err)
-		}
-	}
-	return errors
+func (r *RabbitMQAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, r.move(ctx, fromQueue, toQueue, messageIDs))
 }
 
-func (r *RabbitMQAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
-	messagesToRequeue := []RabbitMessages{}
-	queueLength := r.getQueueLength(ctx, fromQueue)
-	log.Printf("queue length is: %d", queueLength)
+// move publishes each message to toQueue and takes it off fromQueue once the broker has confirmed the copy.  With a
+// journal every move is recorded before the copy is sent and finished once the original is off fromQueue.
+func (r *RabbitMQAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	fromQueue, _ = neturl.QueryUnescape(fromQueue)
+	toQueue, _ = neturl.QueryUnescape(toQueue)
 
-	for i := 0; i < queueLength; i++ {
-		// removing a message from fromQueue
-		err, resp := r.removeOneMessageFromQueue(fromQueue)
+	copied := make(map[string]string)
+	moveErrors := r.settleQueue(ctx, fromQueue, messageIDs, func(publisher *rabbitPublisher, message RabbitMessage) error {
+		msg, err := message.movedFrom(fromQueue).publishing(r.username)
 		if err != nil {
-			log.Printf("failed to remove message from %s", fromQueue)
-			continue
-		}
-		if resp == nil {
-			log.Printf("response was nil - failed to remove message from %s", fromQueue)
-			continue
+			return err
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			continue
+		entryID, err := r.journal.begin(fromQueue, toQueue, message.messageID(), message.fingerprint())
+		if err != nil {
+			return err
+		}
+		if err := publisher.publish(toQueue, msg); err != nil {
+			log.Printf("Could not move to %s, requeued to %s: Error: %s", toQueue, fromQueue, err.Error())
+			r.finishJournalEntry(entryID, JournalDone)
+			return err
 		}
+		r.finishJournalEntry(entryID, JournalCopied)
+		copied[message.messageID()] = entryID
+		return nil
+	})
 
-		respbody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			continue
+	// the channel is closed by now, so the broker has taken the acks of every message that didn't fail
+	failed := make(map[string]bool)
+	for _, err := range moveErrors {
+		if messageID, ok := errorMessageID(err); ok {
+			failed[messageID] = true
 		}
+	}
+	for messageID, entryID := range copied {
+		if !failed[messageID] {
+			r.finishJournalEntry(entryID, JournalDone)
+		}
+	}
+	return moveErrors
+}
 
-		rabbitMessages := RabbitMessages{}
+// finishJournalEntry marks how far a move got.  The move has already happened, so failing to record it only means
+// the journal is replayed for a move that needs nothing more.
+func (r *RabbitMQAdapter) finishJournalEntry(entryID string, state JournalState) {
+	if err := r.journal.mark(entryID, state); err != nil {
+		log.Printf("unable to mark move %s %s in the journal: %s", entryID, state, err)
+	}
+}
 
-		err = json.Unmarshal(respbody, &rabbitMessages)
-		if err != nil {
-			fmt.Printf("error: %v", err)
+// UseJournal records moves in the journal, so the moves a crash interrupts can be finished by ReplayJournal
+func (r *RabbitMQAdapter) UseJournal(journal *MoveJournal) {
+	r.journal = journal
+}
+
+// JournalEntries returns the moves the journal holds that aren't finished
+func (r *RabbitMQAdapter) JournalEntries() []JournalEntry {
+	return r.journal.Entries()
+}
+
+// ReplayJournal finishes the moves a crash interrupted.  A move whose copy the broker confirmed left the original
+// on the source queue, which is deleted.  A message ID needn't be unique, so only a message with the original's
+// fingerprint is.  A move whose copy wasn't confirmed left the original where it was, but the destination may have
+// the copy as well, so it is marked unresolved and stays in the journal for an operator to check.
+func (r *RabbitMQAdapter) ReplayJournal(ctx context.Context) []error {
+	var replayErrors []error
+
+	for _, entry := range r.journal.Entries() {
+		switch entry.State {
+		case JournalUnresolved:
+			continue
+		case JournalCopying:
+			log.Printf("moving message %s from %s to %s was interrupted before the copy was confirmed, it is still on %s and may be on %s too",
+				entry.MessageID, entry.FromQueue, entry.ToQueue, entry.FromQueue, entry.ToQueue)
+			if err := r.journal.mark(entry.ID, JournalUnresolved); err != nil {
+				replayErrors = append(replayErrors, err)
+			}
 			continue
 		}
 
-		if len(rabbitMessages) != 1 {
-			log.Printf("Loop: Expected 1 message and got %d", len(rabbitMessages))
+		// the copy was confirmed, so the original is to be taken off the source queue
+		messageIDs := []string{entry.MessageID}
+		err := resultError(messageResults(messageIDs, structs.MessageDeleted,
+			r.settleQueue(ctx, entry.FromQueue, messageIDs, func(_ *rabbitPublisher, message RabbitMessage) error {
+				if message.fingerprint() != entry.Fingerprint {
+					return errOtherRabbitMessage
+				}
+				return nil
+			})))
+		// the original may have been acked before the crash, or its queue deleted since
+		if err != nil && !errors.Is(err, ErrMessageNotFound) && !errors.Is(err, ErrQueueNotFound) {
+			replayErrors = append(replayErrors, fmt.Errorf("unable to finish moving message %s from %s to %s: %w",
+				entry.MessageID, entry.FromQueue, entry.ToQueue, err))
 			continue
 		}
-		rabbitMessage := rabbitMessages[0]
-		if messageID == rabbitMessage.Properties.Headers.MessageID {
-			// move to new queue
-			log.Printf("attempting to publish message %+v to queue: %s", rabbitMessage, toQueue)
-			err := r.publishMessage(rabbitMessages, toQueue)
-			if err != nil {
-				messagesToRequeue = append(messagesToRequeue, rabbitMessages)
-				log.Printf("Could not move to %s, requeued to %s: Error: %s", toQueue, fromQueue, err.Error())
-			}
-		} else {
-			messagesToRequeue = append(messagesToRequeue, rabbitMessages)
-			log.Printf("added message: %+v to the requeueMessages list", rabbitMessages)
-		}
-	}
+		log.Printf("finished moving message %s from %s to %s", entry.MessageID, entry.FromQueue, entry.ToQueue)
 
-	err := r.requeueMessages(messagesToRequeue, fromQueue)
-	if err != nil {
-		return err
+		if err := r.journal.mark(entry.ID, JournalDone); err != nil {
+			replayErrors = append(replayErrors, err)
+		}
 	}
+	return replayErrors
+}
 
-	return nil
+func (r *RabbitMQAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(r.Move(ctx, fromQueue, toQueue, []string{messageID}))
 }
 
 func (r *RabbitMQAdapter) Purge(ctx context.Context, queueName string) error {
@@ -316,87 +682,37 @@ func (r *RabbitMQAdapter) Purge(ctx context.Context, queueName string) error {
 	resp, err = httpClient.Do(req)
 	if err != nil {
 		log.Printf("error returned from this attempt was %s", err.Error())
-		return err
+		return unavailable(err)
 	}
 
 	if resp == nil {
 		log.Printf(fmt.Sprintf("we were not able to purge the queue from the console consoleURL: %s", url))
-		return nil
+		return unavailable(errors.New(fmt.Sprintf("we were not able to purge the queue from the console consoleURL: %s", url)))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	// the management API answers a purge with 204 No Content
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		log.Printf(fmt.Sprintf("bad status code from the console consoleURL: %s", url))
-		return nil
+		return httpStatusError("the RabbitMQ management API", resp, queueName)
 	}
 	return nil
 }
 
 func (r *RabbitMQAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
-	messagesToRequeue := []RabbitMessages{}
-	queueLength := r.getQueueLength(ctx, queueName)
-	log.Printf("queue %s length is: %d", queueName, queueLength)
-
-	for i := 0; i < queueLength; i++ {
-		// removing a message from fromQueue
-		err, resp := r.removeOneMessageFromQueue(queueName)
-		if err != nil {
-			log.Printf("failed to remove message from %s", queueName)
-			continue
-		}
-		if resp == nil {
-			log.Printf("response was nil - failed to remove message from %s", queueName)
-			continue
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			fmt.Printf("bad status code: %v", err)
-			continue
-		}
-
-		respbody, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			fmt.Printf("resp body error: %v", err)
-			continue
-		}
-
-		rabbitMessages := RabbitMessages{}
-
-		err = json.Unmarshal(respbody, &rabbitMessages)
-		if err != nil {
-			fmt.Printf("unmarshal error: %v", err)
-			continue
-		}
-
-		if len(rabbitMessages) != 1 {
-			log.Printf("Loop: Expected 1 message and got %d", len(rabbitMessages))
-			continue
-		}
-		rabbitMessage := rabbitMessages[0]
-		if messageID != rabbitMessage.Properties.Headers.MessageID {
-			log.Printf("added message: %+v to the requeueMessages list", rabbitMessages)
-			messagesToRequeue = append(messagesToRequeue, rabbitMessages)
-		}
-	}
-
-	err := r.requeueMessages(messagesToRequeue, queueName)
-	if err != nil {
-		fmt.Printf("requeue error: %v", err)
-		return err
-	}
+	return resultError(r.DeleteMany(ctx, queueName, []string{messageID}))
+}
 
-	return nil
+func (r *RabbitMQAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, r.deleteMany(ctx, queueName, messageIDs))
 }
 
-func (r *RabbitMQAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
-	errors := []error{}
-	for _, messageID := range messageIDs {
-		if err := r.DeleteOne(ctx, queueName, messageID); err != nil {
-			errors = append(errors, err)
-		}
-	}
-	return errors
+func (r *RabbitMQAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	queueName, _ = neturl.QueryUnescape(queueName)
+
+	return r.settleQueue(ctx, queueName, messageIDs, func(*rabbitPublisher, RabbitMessage) error {
+		return nil
+	})
 }
 
 func (r *RabbitMQAdapter) getQueues() (*http.Response, error) {
@@ -414,20 +730,20 @@ func (r *RabbitMQAdapter) getQueues() (*http.Response, error) {
 	resp, err = httpClient.Do(req)
 	if err != nil {
 		log.Printf("error returned from this attempt was %s", err.Error())
-		return nil, err
+		return nil, unavailable(err)
 	}
 	if resp == nil {
 		log.Printf(fmt.Sprintf("we were not able to retrieve info about the queue from the console consoleURL: %s", url))
-		return nil, errors.New(fmt.Sprintf("we were not able to retrieve info about the queue from the console consoleURL: %s", url))
+		return nil, unavailable(errors.New(fmt.Sprintf("we were not able to retrieve info about the queue from the console consoleURL: %s", url)))
 	}
 	return resp, nil
 }
 
-func (r *RabbitMQAdapter) getQueueLength(ctx context.Context, queueName string) int {
+func (r *RabbitMQAdapter) getQueueLength(ctx context.Context, queueName string) (int, error) {
 	queues, err := r.GetAllQueues(ctx)
 	if err != nil {
 		log.Printf("Could not retrieve queues from GetAllQueues")
-		return 0
+		return 0, err
 	}
 
 	for _, q := range queues {
@@ -440,28 +756,13 @@ func (r *RabbitMQAdapter) getQueueLength(ctx context.Context, queueName string)
 			queueSize, err := strconv.Atoi(i)
 			if err != nil {
 				log.Printf("Error converting queue size string to int")
-				return 0
+				return 0, err
 			}
 			log.Printf("found a queue: %s", queueName)
-			return queueSize
+			return queueSize, nil
 		}
 	}
-	return 0
-}
-
-func (r *RabbitMQAdapter) requeueMessages(messages []RabbitMessages, queueName string) error {
-	for _, message := range messages {
-		err := r.publishMessage(message, queueName)
-		if err != nil {
-			log.Printf("Unable to requeue message to %s: %+v", queueName, message)
-		}
-	}
-
-	return nil
-}
-
-func (r *RabbitMQAdapter) publishMessage(message RabbitMessages, toQueue string) error {
-	return r.sendMessageAMQP(message, toQueue)
+	return 0, queueNotFound(queueName)
 }
 
 func (r *RabbitMQAdapter) sendMessageHTTP(message RabbitMessages, toQueue string) error {
@@ -480,7 +781,7 @@ func (r *RabbitMQAdapter) sendMessageHTTP(message RabbitMessages, toQueue string
 		Properties:      rabbitMessage.Properties,
 		RoutingKey:      toQueue,
 		Payload:         rabbitMessage.Body,
-		PayloadEncoding: "string",
+		PayloadEncoding: rabbitPayloadEncoding(rabbitMessage),
 	})
 	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
 	if err != nil {
@@ -498,98 +799,262 @@ func (r *RabbitMQAdapter) sendMessageHTTP(message RabbitMessages, toQueue string
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("status not StatusOK")
+		return httpStatusError("the RabbitMQ management API", resp, "")
 
 	}
 	return nil
 }
 
-func (r *RabbitMQAdapter) sendMessageAMQP(message RabbitMessages, toQueue string) error {
+// rabbitPayloadEncoding is the encoding the management API gave the message's payload in
+func rabbitPayloadEncoding(message RabbitMessage) string {
+	if message.PayloadEncoding == "" {
+		return "string"
+	}
+	return message.PayloadEncoding
+}
 
-	//parse message for sending via AMQP09
-	if len(message) != 1 {
-		log.Printf("publish: Expected 1 message and got %d", len(message))
-		return errors.New(fmt.Sprintf("Expected 1 message and got %d", len(message)))
+// errOtherRabbitMessage is returned by a settleQueue handle for a message that has a wanted ID but isn't the message
+// wanted, so the pass keeps looking
+var errOtherRabbitMessage = errors.New("a different message with the same ID")
+
+// settleQueue makes a single pass over the queue with an AMQP consumer that acks by hand.  The first message with
+// each of messageIDs is passed to handle and acked once handle succeeds.  Every other message is requeued as soon as
+// it arrives.  RabbitMQ puts a requeued message back where it was, so the pass is over once the consumer is handed a
+// message it has already passed over, or the queue goes quiet.  The prefetch caps the messages the consumer holds at
+// rabbitMQSettlePrefetch, which is also how far into a classic queue a pass reaches.  Nothing is acked before it has
+// been dealt with, so if the service dies partway through the broker requeues whatever it had handed over: a message
+// being moved can end up on both queues, but never on neither.
+func (r *RabbitMQAdapter) settleQueue(ctx context.Context, queueName string, messageIDs []string,
+	handle func(publisher *rabbitPublisher, message RabbitMessage) error) []error {
+
+	var settleErrors []error
+
+	// the consumer can't tell a queue that doesn't exist from any other failure, so the management API is asked
+	if _, err := r.getQueueLength(ctx, queueName); err != nil {
+		return append(settleErrors, err)
 	}
-	rabbitMessage := message[0]
 
-	timestamp, err := time.Parse("2006-01-02T15:04:05.000Z", rabbitMessage.Properties.Headers.Timestamp)
+	channel, err := r.getAMQPPublisher()
 	if err != nil {
-		timestamp = time.Now().UTC()
+		return append(settleErrors, err)
 	}
+	defer channel.Close()
 
-	msg := amqp9.Publishing{
-		MessageId:     rabbitMessage.Properties.Headers.MessageID,
-		CorrelationId: rabbitMessage.Properties.Headers.CorrelationID,
-		Body:          []byte(rabbitMessage.Body),
-		DeliveryMode:  amqp9.Persistent,
-		ContentType:   "application/json",
-		Timestamp:     timestamp,
-		Headers: amqp9.Table{
-			"MessageID":     rabbitMessage.Properties.Headers.MessageID,
-			"CorrelationID": rabbitMessage.Properties.Headers.CorrelationID,
-			"Timestamp":     rabbitMessage.Properties.Headers.Timestamp,
-		},
+	publisher, err := newRabbitPublisher(channel)
+	if err != nil {
+		return append(settleErrors, err)
 	}
 
-	//connect channel
-	publisher, err := r.getAMQPPublisher()
+	if err := channel.Qos(rabbitMQSettlePrefetch, 0, false); err != nil {
+		return append(settleErrors, fmt.Errorf("unable to set the prefetch on %s: %w", queueName, err))
+	}
+	consumerTag := "brokerui-" + uuid.New().String()
+	deliveries, err := channel.Consume(queueName, consumerTag, false, false, false, false, nil)
 	if err != nil {
-		return err
+		return append(settleErrors, fmt.Errorf("unable to consume from %s: %w", queueName, err))
+	}
+
+	wanted := make(map[string]bool)
+	for _, messageID := range messageIDs {
+		wanted[messageID] = true
+	}
+	passed := make(map[string]bool)
+	requeue := func(delivery amqp9.Delivery, fingerprint string) {
+		passed[fingerprint] = true
+		// if this fails the broker requeues the message when the channel closes
+		if err := delivery.Nack(false, true); err != nil {
+			log.Printf("unable to requeue message %d on %s: %s", delivery.DeliveryTag, queueName, err)
+		}
 	}
-	defer publisher.Close()
 
+	for len(wanted) > 0 {
+		delivery, ok := nextRabbitDelivery(ctx, deliveries)
+		if !ok {
+			break
+		}
+
+		message := rabbitDeliveryMessage(delivery)
+		fingerprint := message.fingerprint()
+		if delivery.Redelivered && passed[fingerprint] {
+			requeue(delivery, fingerprint)
+			break
+		}
+
+		messageID := message.messageID()
+		if !wanted[messageID] {
+			requeue(delivery, fingerprint)
+			continue
+		}
+
+		if err := handle(publisher, message); err != nil {
+			requeue(delivery, fingerprint)
+			if err != errOtherRabbitMessage {
+				delete(wanted, messageID)
+				settleErrors = append(settleErrors, forMessage(messageID, err))
+			}
+			continue
+		}
+		delete(wanted, messageID)
+		if err := delivery.Ack(false); err != nil {
+			log.Printf("error trying to ack message %s, error is %s", messageID, err)
+			settleErrors = append(settleErrors, forMessage(messageID, err))
+		}
+	}
+
+	if err := channel.Cancel(consumerTag, false); err != nil {
+		log.Printf("unable to cancel the consumer on %s: %s", queueName, err)
+	}
+	// the messages handed over but not looked at are requeued when the channel closes
+
+	for _, messageID := range messageIDs {
+		if wanted[messageID] {
+			settleErrors = append(settleErrors, messageNotFound(messageID))
+		}
+	}
+	return settleErrors
+}
+
+// nextRabbitDelivery waits for the consumer's next message, giving up once the queue has gone quiet
+func nextRabbitDelivery(ctx context.Context, deliveries <-chan amqp9.Delivery) (amqp9.Delivery, bool) {
+	timeout := time.NewTimer(rabbitMQReceiveTimeout)
+	defer timeout.Stop()
+
+	select {
+	case delivery, ok := <-deliveries:
+		return delivery, ok
+	case <-timeout.C:
+		return amqp9.Delivery{}, false
+	case <-ctx.Done():
+		return amqp9.Delivery{}, false
+	}
+}
+
+// rabbitPublisher publishes on a channel in confirm mode, waiting for the broker to take each message
+type rabbitPublisher struct {
+	channel  RabbitMQChannel
+	ack      chan uint64
+	nack     chan uint64
+	returned chan amqp9.Return
+}
+
+func newRabbitPublisher(channel RabbitMQChannel) (*rabbitPublisher, error) {
 	// Set to Confirm mode, so we get delivery confirmation
-	if err = publisher.Confirm(false); err != nil {
-		return err
+	if err := channel.Confirm(false); err != nil {
+		return nil, err
 	}
 
-	// Get channels for delivery confirmation/rejection
-	ack, nack := publisher.NotifyConfirm(make(chan uint64, 1), make(chan uint64, 1))
+	// Get channels for delivery confirmation/rejection, and for mandatory messages the broker couldn't route
+	ack, nack := channel.NotifyConfirm(make(chan uint64, 1), make(chan uint64, 1))
+	returned := channel.NotifyReturn(make(chan amqp9.Return, 1))
+	return &rabbitPublisher{channel: channel, ack: ack, nack: nack, returned: returned}, nil
+}
 
-	// Send each Message one at a time (easier to confirm delivery)
-	if err = publisher.Publish(toQueue, toQueue, true, false, msg); err != nil {
+// publish sends one message to the queue and waits for the broker to confirm it.  The listeners are closed when the
+// broker closes the channel, say for an exchange that doesn't exist, so only an ack actually sent counts as a confirm.
+func (p *rabbitPublisher) publish(toQueue string, msg amqp9.Publishing) error {
+	if err := p.channel.Publish(toQueue, toQueue, true, false, msg); err != nil {
 		return err
-	} else {
+	}
 
-		// We don't want to wait on the Message delivery forever
-		timeout := time.After(30 * time.Second)
+	// We don't want to wait on the Message delivery forever
+	timeout := time.NewTimer(30 * time.Second)
+	defer timeout.Stop()
 
-		// In the case of a failure or timeout, log entire Message so it can be recovered
+	select {
+	case _, ok := <-p.ack:
+		if !ok {
+			return fmt.Errorf("Message ID: %s; the channel closed before the broker confirmed the message", msg.MessageId)
+		}
+		// The broker returns a message it couldn't route before it acks it
 		select {
-		case _ = <-ack:
+		case returned, ok := <-p.returned:
+			return p.returnedError(msg, returned, ok)
+		default:
 			return nil
-		case response := <-nack:
-			err = errors.New(fmt.Sprintf("Message ID: %s; Nack: %+v", msg.MessageId, response))
-			return err
-		case _ = <-timeout:
-			err = errors.New(fmt.Sprintf("Message ID: %s; Timeout", msg.MessageId))
-			return err
 		}
+	case returned, ok := <-p.returned:
+		return p.returnedError(msg, returned, ok)
+	case response, ok := <-p.nack:
+		if !ok {
+			return fmt.Errorf("Message ID: %s; the channel closed before the broker confirmed the message", msg.MessageId)
+		}
+		return fmt.Errorf("Message ID: %s; Nack: %+v", msg.MessageId, response)
+	case <-timeout.C:
+		return fmt.Errorf("Message ID: %s; Timeout", msg.MessageId)
 	}
-
 }
 
-func (r *RabbitMQAdapter) removeOneMessageFromQueue(fromQueue string) (error, *http.Response) {
-	httpClient := &http.Client{Timeout: time.Second * 10}
-	var resp *http.Response
+// returnedError is the failure of a publish the broker handed back, or whose channel closed
+func (p *rabbitPublisher) returnedError(msg amqp9.Publishing, returned amqp9.Return, ok bool) error {
+	if !ok {
+		return fmt.Errorf("Message ID: %s; the channel closed before the broker confirmed the message", msg.MessageId)
+	}
+	return fmt.Errorf("Message ID: %s; Returned: %d %s", msg.MessageId, returned.ReplyCode, returned.ReplyText)
+}
 
-	url := fmt.Sprintf("%s/api/queues/%s/%s/get", r.consoleURL, r.host, fromQueue)
-	log.Printf("attempting to get queue information from %s", url)
-	body, err := json.Marshal(RabbitMQRemoveOneMessageRequestBody)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
-	if err != nil {
-		log.Printf("we were unable to get a http.NewRequest for consoleURL %s, error is %s", url, err.Error())
-		return nil, nil
+// rabbitDeliveryMessage reads a delivery the way the management API shows the message, so it gets the same ID as
+// when the queue is browsed
+func rabbitDeliveryMessage(delivery amqp9.Delivery) RabbitMessage {
+	message := RabbitMessage{
+		QueueName:   delivery.RoutingKey,
+		Exchange:    delivery.Exchange,
+		Redelivered: delivery.Redelivered,
+		Properties: RabbitMessageProperties{
+			ContentType:     delivery.ContentType,
+			ContentEncoding: delivery.ContentEncoding,
+			DeliveryMode:    delivery.DeliveryMode,
+			Priority:        delivery.Priority,
+			CorrelationID:   delivery.CorrelationId,
+			ReplyTo:         delivery.ReplyTo,
+			Expiration:      delivery.Expiration,
+			MessageID:       delivery.MessageId,
+			Type:            delivery.Type,
+			UserID:          delivery.UserId,
+			AppID:           delivery.AppId,
+		},
+		Body:            base64.StdEncoding.EncodeToString(delivery.Body),
+		PayloadEncoding: "base64",
+		PayloadBytes:    len(delivery.Body),
 	}
-	req.SetBasicAuth(r.username, r.pwd)
-	resp, err = httpClient.Do(req)
-	if err != nil {
-		log.Printf("error returned from this attempt was %s", err.Error())
-		return err, nil
+	if !delivery.Timestamp.IsZero() {
+		message.Properties.Timestamp = delivery.Timestamp.Unix()
 	}
-	if resp == nil {
-		log.Printf(fmt.Sprintf("we were not able to retrieve info about the queue from the console consoleURL: %s", url))
+	if len(delivery.Headers) > 0 {
+		message.Properties.Headers = rabbitTableHeaders(delivery.Headers)
+	}
+	return message
+}
+
+// rabbitTableHeaders turns an AMQP table into headers as the management API gives them, with numbers as
+// json.Number and timestamps as seconds
+func rabbitTableHeaders(table amqp9.Table) map[string]interface{} {
+	headers := make(map[string]interface{}, len(table))
+	for name, value := range table {
+		headers[name] = rabbitTableValue(value)
+	}
+	return headers
+}
+
+func rabbitTableValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case amqp9.Table:
+		return rabbitTableHeaders(value)
+	case []interface{}:
+		values := make([]interface{}, len(value))
+		for i, item := range value {
+			values[i] = rabbitTableValue(item)
+		}
+		return values
+	case time.Time:
+		return json.Number(strconv.FormatInt(value.Unix(), 10))
+	case []byte:
+		return string(value)
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(value), 'g', -1, 32))
+	case float64:
+		return json.Number(strconv.FormatFloat(value, 'g', -1, 64))
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return json.Number(fmt.Sprintf("%d", value))
 	}
-	return err, resp
+	return value
 }
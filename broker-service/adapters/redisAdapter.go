@@ -0,0 +1,548 @@
+package adapters
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+const (
+	// redisDefaultMaxMessages caps how many entries a single browse returns
+	redisDefaultMaxMessages = 1000
+	// redisScanCount is the SCAN batch size used to find keys
+	redisScanCount = 1000
+
+	redisTypeStream = "stream"
+	redisTypeList   = "list"
+)
+
+// redisStreamScript deletes stream entries from KEYS[1], adding them to the stream KEYS[2] first when it is given.
+// It returns 1 for every ID in ARGV that was found and 0 for the others.
+var redisStreamScript = redis.NewScript(`
+if KEYS[2] then
+	local destinationType = redis.call('TYPE', KEYS[2])['ok']
+	if destinationType ~= 'stream' and destinationType ~= 'none' then
+		return redis.error_reply('destination ' .. KEYS[2] .. ' is a ' .. destinationType .. ', not a stream')
+	end
+end
+local results = {}
+for i, id in ipairs(ARGV) do
+	local entries = redis.call('XRANGE', KEYS[1], id, id)
+	if #entries == 0 then
+		results[i] = 0
+	else
+		if KEYS[2] then
+			redis.call('XADD', KEYS[2], '*', unpack(entries[1][2]))
+		end
+		redis.call('XDEL', KEYS[1], id)
+		results[i] = 1
+	end
+end
+return results
+`)
+
+// redisListScript removes list entries from KEYS[1], pushing them onto the list KEYS[2] first when it is given.
+// It only looks at the ARGV[2] entries from index ARGV[1], so a large list doesn't hold up the server.  Entries are
+// identified by the SHA-1 of their value, so the rest of ARGV holds hashes.  It returns 1 for every hash that was
+// found and 0 for the others, then how many entries it looked at.
+var redisListScript = redis.NewScript(`
+if KEYS[2] then
+	local destinationType = redis.call('TYPE', KEYS[2])['ok']
+	if destinationType ~= 'list' and destinationType ~= 'none' then
+		return redis.error_reply('destination ' .. KEYS[2] .. ' is a ' .. destinationType .. ', not a list')
+	end
+end
+local start = tonumber(ARGV[1])
+local window = redis.call('LRANGE', KEYS[1], start, start + tonumber(ARGV[2]) - 1)
+local values = {}
+local counts = {}
+for _, value in ipairs(window) do
+	local hash = redis.sha1hex(value)
+	values[hash] = value
+	counts[hash] = (counts[hash] or 0) + 1
+end
+local results = {}
+for i = 3, #ARGV do
+	local hash = ARGV[i]
+	if (counts[hash] or 0) == 0 then
+		results[i - 2] = 0
+	else
+		redis.call('LREM', KEYS[1], 1, values[hash])
+		if KEYS[2] then
+			redis.call('RPUSH', KEYS[2], values[hash])
+		end
+		counts[hash] = counts[hash] - 1
+		results[i - 2] = 1
+	end
+end
+results[#ARGV - 1] = #window
+return results
+`)
+
+// RedisAdapter browses and manages Redis streams and lists, such as the dead-letter keys of background job systems.
+// Every stream or list whose key matches the key pattern is a queue.  A stream entry's ID is its stream ID.  List
+// entries have no IDs, so an entry's ID is the SHA-1 of its value, with -2, -3... added for repeated values.
+// Deletes and moves run as Lua scripts, so each is atomic; a list is gone through maxMessages entries at a time.
+type RedisAdapter struct {
+	client      *redis.Client
+	keyPattern  string
+	bodyField   string
+	maxMessages int
+}
+
+// Returns a Redis adapter:
+// redisURL: redis:// or rediss:// URL of the server, e.g. redis://localhost:6379/0
+// user, pass: optional credentials, override those in the URL
+// keyPattern: the pattern keys must match to be listed, every key when empty
+// bodyField: optional stream entry field shown as the message body, the other fields becoming headers
+func NewRedisAdapter(ctx context.Context, redisURL, user, pass, keyPattern, bodyField string) (*RedisAdapter, error) {
+
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	if user != "" {
+		options.Username = user
+	}
+	if pass != "" {
+		options.Password = pass
+	}
+
+	client := redis.NewClient(options)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if keyPattern == "" {
+		keyPattern = "*"
+	}
+
+	return &RedisAdapter{
+		client:      client,
+		keyPattern:  keyPattern,
+		bodyField:   bodyField,
+		maxMessages: redisDefaultMaxMessages,
+	}, nil
+}
+
+func (r *RedisAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+	var keys []string
+	iterator := r.client.Scan(ctx, 0, r.keyPattern, redisScanCount).Iterator()
+	for iterator.Next(ctx) {
+		keys = append(keys, iterator.Val())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, redisError(err)
+	}
+	sort.Strings(keys)
+
+	pipe := r.client.Pipeline()
+	types := make([]*redis.StatusCmd, len(keys))
+	for i, key := range keys {
+		types[i] = pipe.Type(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	pipe = r.client.Pipeline()
+	keyTypes := make(map[string]string)
+	lengths := make(map[string]*redis.IntCmd)
+	groups := make(map[string]*redis.XInfoGroupsCmd)
+	var queueKeys []string
+	for i, key := range keys {
+		switch types[i].Val() {
+		case redisTypeStream:
+			lengths[key] = pipe.XLen(ctx, key)
+			groups[key] = pipe.XInfoGroups(ctx, key)
+		case redisTypeList:
+			lengths[key] = pipe.LLen(ctx, key)
+		default:
+			continue
+		}
+		keyTypes[key] = types[i].Val()
+		queueKeys = append(queueKeys, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	queues := []Queue{}
+	for _, key := range queueKeys {
+		info := map[string]string{
+			"Type": keyTypes[key],
+			"Size": strconv.FormatInt(lengths[key].Val(), 10),
+		}
+		if groupsCmd, ok := groups[key]; ok {
+			info["Groups"] = strconv.Itoa(len(groupsCmd.Val()))
+		}
+		queues = append(queues, Queue{Name: key, Info: info})
+	}
+
+	return queues, nil
+}
+
+func (r *RedisAdapter) GetAllMessages(ctx context.Context, queueName string) ([]structs.StandardMessage, error) {
+	key, keyType, err := r.getKey(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+
+	switch keyType {
+	case redisTypeStream:
+		entries, err := r.client.XRangeN(ctx, key, "-", "+", int64(r.maxMessages)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			stdMessages = append(stdMessages, r.convertStreamEntry(key, entry))
+		}
+	case redisTypeList:
+		values, err := r.client.LRange(ctx, key, 0, int64(r.maxMessages-1)).Result()
+		if err != nil {
+			return nil, err
+		}
+		stdMessages = convertListValues(key, values)
+	}
+
+	return stdMessages, nil
+}
+
+// Capabilities pages streams by entry ID and lists by offset
+func (r *RedisAdapter) Capabilities() Capabilities {
+	capabilities := defaultCapabilities(r)
+	capabilities.Filters = []string{FilterDate}
+	capabilities.Paging = []string{PagingPosition, PagingOffset}
+	return capabilities
+}
+
+// GetFilteredMessages reads only the stream entries added between the filter's dates, as entry IDs start with the
+// time they were added, and applies the rest of the filter to them
+func (r *RedisAdapter) GetFilteredMessages(ctx context.Context, queueName string, filter MessageFilter) ([]structs.StandardMessage, error) {
+	key, keyType, err := r.getKey(ctx, queueName)
+	if err != nil {
+		return nil, err
+	}
+	if keyType != redisTypeStream {
+		messages, err := r.GetAllMessages(ctx, queueName)
+		if err != nil {
+			return nil, err
+		}
+		return filter.Apply(messages), nil
+	}
+
+	start, end := "-", "+"
+	if !filter.FromDate.IsZero() {
+		start = strconv.FormatInt(filter.FromDate.UnixNano()/int64(time.Millisecond), 10)
+	}
+	if !filter.ToDate.IsZero() {
+		end = strconv.FormatInt(filter.ToDate.UnixNano()/int64(time.Millisecond), 10)
+	}
+	entries, err := r.client.XRangeN(ctx, key, start, end, int64(r.maxMessages)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	stdMessages := []structs.StandardMessage{}
+	for _, entry := range entries {
+		stdMessages = append(stdMessages, r.convertStreamEntry(key, entry))
+	}
+	return filter.Apply(stdMessages), nil
+}
+
+// GetMessages pages a stream by entry ID, the cursor being the ID to start at, and a list by offset
+func (r *RedisAdapter) GetMessages(ctx context.Context, queueName string, page PageRequest) (MessagePage, error) {
+	key, keyType, err := r.getKey(ctx, queueName)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	limit := pageLimit(page)
+
+	if keyType == redisTypeList {
+		offset, err := pageOffset(page)
+		if err != nil {
+			return MessagePage{}, err
+		}
+		// a list entry's ID counts the values like it before it, so the list is read from its head
+		values, err := r.client.LRange(ctx, key, 0, int64(offset+limit)).Result()
+		if err != nil {
+			return MessagePage{}, err
+		}
+		return offsetPage(convertListValues(key, values), offset, limit), nil
+	}
+
+	start := "-"
+	if page.Cursor != "" {
+		if _, err := parseRedisStreamID(page.Cursor); err != nil {
+			return MessagePage{}, invalidCursor(page.Cursor)
+		}
+		start = page.Cursor
+	}
+	entries, err := r.client.XRangeN(ctx, key, start, "+", int64(limit+1)).Result()
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	messagePage := MessagePage{Messages: []structs.StandardMessage{}}
+	if len(entries) > limit {
+		messagePage.NextCursor = entries[limit].ID
+		entries = entries[:limit]
+	}
+	for _, entry := range entries {
+		messagePage.Messages = append(messagePage.Messages, r.convertStreamEntry(key, entry))
+	}
+	return messagePage, nil
+}
+
+// convertListValues turns the values at the head of a list into messages
+func convertListValues(key string, values []string) []structs.StandardMessage {
+	stdMessages := []structs.StandardMessage{}
+	ids := redisListEntryIDs(values)
+	for i, value := range values {
+		stdMessages = append(stdMessages, structs.StandardMessage{
+			MessageID: ids[i],
+			Headers: map[string]string{
+				"Key":   key,
+				"Index": strconv.Itoa(i),
+			},
+			Body: value,
+		})
+	}
+	return stdMessages
+}
+
+// getKey returns the queue's key and whether it is a stream or a list.  A key that doesn't exist is an empty
+// stream or list as far as Redis is concerned, so it is treated as an empty list.
+func (r *RedisAdapter) getKey(ctx context.Context, queueName string) (string, string, error) {
+	key, _ := url.QueryUnescape(queueName)
+
+	keyType, err := r.client.Type(ctx, key).Result()
+	if err != nil {
+		return "", "", redisError(err)
+	}
+	switch keyType {
+	case redisTypeStream, redisTypeList:
+		return key, keyType, nil
+	case "none":
+		return key, redisTypeList, nil
+	default:
+		return "", "", fmt.Errorf("%s is a %s, not a stream or list", key, keyType)
+	}
+}
+
+func (r *RedisAdapter) Move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, r.move(ctx, fromQueue, toQueue, messageIDs))
+}
+
+func (r *RedisAdapter) move(ctx context.Context, fromQueue string, toQueue string, messageIDs []string) []error {
+	var moveErrors []error
+
+	toKey, _ := url.QueryUnescape(toQueue)
+	return append(moveErrors, r.runScript(ctx, fromQueue, []string{toKey}, messageIDs)...)
+}
+
+func (r *RedisAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
+	return resultError(r.Move(ctx, fromQueue, toQueue, []string{messageID}))
+}
+
+// Purge empties a stream, keeping the stream and its consumer groups, or deletes a list
+func (r *RedisAdapter) Purge(ctx context.Context, queueName string) error {
+	key, keyType, err := r.getKey(ctx, queueName)
+	if err != nil {
+		return err
+	}
+
+	if keyType == redisTypeStream {
+		return r.client.XTrimMaxLen(ctx, key, 0).Err()
+	}
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *RedisAdapter) DeleteOne(ctx context.Context, queueName string, messageID string) error {
+	return resultError(r.DeleteMany(ctx, queueName, []string{messageID}))
+}
+
+func (r *RedisAdapter) DeleteMany(ctx context.Context, queueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, r.deleteMany(ctx, queueName, messageIDs))
+}
+
+func (r *RedisAdapter) deleteMany(ctx context.Context, queueName string, messageIDs []string) []error {
+	return r.runScript(ctx, queueName, nil, messageIDs)
+}
+
+// runScript deletes (or with a destination, moves) the entries in one script run, so other clients never see
+// an entry in both places or in neither
+func (r *RedisAdapter) runScript(ctx context.Context, queueName string, destination []string, messageIDs []string) []error {
+	var scriptErrors []error
+
+	key, keyType, err := r.getKey(ctx, queueName)
+	if err != nil {
+		return append(scriptErrors, err)
+	}
+
+	var scriptIDs []string
+	var args []interface{}
+	for _, messageID := range messageIDs {
+		if keyType == redisTypeList {
+			// repeated values share a hash, the suffix only tells them apart in the UI
+			args = append(args, strings.SplitN(messageID, "-", 2)[0])
+		} else if isRedisStreamID(messageID) {
+			args = append(args, messageID)
+		} else {
+			// the stream commands fail the whole script on an ID that isn't one
+			scriptErrors = append(scriptErrors, messageNotFound(messageID))
+			continue
+		}
+		scriptIDs = append(scriptIDs, messageID)
+	}
+	if len(args) == 0 {
+		return scriptErrors
+	}
+
+	if keyType == redisTypeList {
+		return append(scriptErrors, r.runListScript(ctx, key, destination, scriptIDs, args)...)
+	}
+
+	results, err := redisStreamScript.Run(ctx, r.client, append([]string{key}, destination...), args...).Int64Slice()
+	if err != nil {
+		return append(scriptErrors, redisError(err))
+	}
+
+	for i, found := range results {
+		if found == 0 {
+			scriptErrors = append(scriptErrors, messageNotFound(scriptIDs[i]))
+		}
+	}
+	return scriptErrors
+}
+
+// runListScript goes through the list a window of maxMessages entries at a time, one script run each, until every
+// hash has been found or the list ends.  An entry taken out of a window moves the ones after it up, so the next
+// window starts that many entries sooner.
+func (r *RedisAdapter) runListScript(ctx context.Context, key string, destination []string, messageIDs []string, hashes []interface{}) []error {
+	var scriptErrors []error
+
+	start := 0
+	for len(hashes) > 0 {
+		args := append([]interface{}{start, r.maxMessages}, hashes...)
+		results, err := redisListScript.Run(ctx, r.client, append([]string{key}, destination...), args...).Int64Slice()
+		if err != nil {
+			return append(scriptErrors, forMessages(messageIDs, redisError(err))...)
+		}
+
+		var missingIDs []string
+		var missing []interface{}
+		for i, found := range results[:len(hashes)] {
+			if found == 0 {
+				missingIDs = append(missingIDs, messageIDs[i])
+				missing = append(missing, hashes[i])
+			}
+		}
+		looked := int(results[len(hashes)])
+		start += looked - (len(hashes) - len(missing))
+		messageIDs, hashes = missingIDs, missing
+		if looked < r.maxMessages {
+			break
+		}
+	}
+
+	for _, messageID := range messageIDs {
+		scriptErrors = append(scriptErrors, messageNotFound(messageID))
+	}
+	return scriptErrors
+}
+
+// convertStreamEntry shows the body field as the body and the other fields as headers, or every field as a JSON
+// body when there is no body field
+func (r *RedisAdapter) convertStreamEntry(key string, entry redis.XMessage) structs.StandardMessage {
+	headers := map[string]string{
+		"Key": key,
+	}
+
+	var body string
+	if value, ok := entry.Values[r.bodyField]; ok && r.bodyField != "" {
+		body = fmt.Sprint(value)
+		for field, value := range entry.Values {
+			if field != r.bodyField {
+				headers[field] = fmt.Sprint(value)
+			}
+		}
+	} else {
+		fields, _ := json.Marshal(entry.Values)
+		body = string(fields)
+	}
+
+	timestamp, _ := parseRedisStreamID(entry.ID)
+
+	return structs.StandardMessage{
+		MessageID: entry.ID,
+		Timestamp: timestamp,
+		Headers:   headers,
+		Body:      body,
+	}
+}
+
+// parseRedisStreamID returns the time a stream entry was added from its ID, e.g. 1526919030474-55
+func parseRedisStreamID(id string) (time.Time, error) {
+	millis, err := strconv.ParseInt(strings.SplitN(id, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid stream ID " + id)
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC(), nil
+}
+
+// isRedisStreamID tells whether id is a stream entry ID, e.g. 1526919030474-55 or 1526919030474
+func isRedisStreamID(id string) bool {
+	for _, part := range strings.SplitN(id, "-", 2) {
+		if _, err := strconv.ParseUint(part, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// redisListEntryIDs gives every list value an ID: the SHA-1 of the value, with -2, -3... added when the value
+// appears more than once
+func redisListEntryIDs(values []string) []string {
+	ids := make([]string, len(values))
+	seen := make(map[string]int)
+	for i, value := range values {
+		sum := sha1.Sum([]byte(value))
+		hash := hex.EncodeToString(sum[:])
+		seen[hash]++
+		ids[i] = hash
+		if seen[hash] > 1 {
+			ids[i] = hash + "-" + strconv.Itoa(seen[hash])
+		}
+	}
+	return ids
+}
+
+// redisError marks the error Redis or the client gave
+func redisError(err error) error {
+	var netErr net.Error
+	message := err.Error()
+	switch {
+	case strings.HasPrefix(message, "NOAUTH"), strings.HasPrefix(message, "WRONGPASS"), strings.HasPrefix(message, "NOPERM"):
+		return unauthorized(err)
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, redis.ErrClosed):
+		return unavailable(err)
+	}
+	return err
+}
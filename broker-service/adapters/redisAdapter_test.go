@@ -0,0 +1,198 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// newTestRedisAdapter starts an in-process Redis fake and connects an adapter to it
+func newTestRedisAdapter(t *testing.T, keyPattern, bodyField string) (*RedisAdapter, *miniredis.Miniredis) {
+	redisServer := miniredis.RunT(t)
+
+	adapter, err := NewRedisAdapter(context.Background(), "redis://"+redisServer.Addr(), "", "", keyPattern, bodyField)
+	if err != nil {
+		t.Fatalf("NewRedisAdapter() error = %v", err)
+	}
+	t.Cleanup(func() { adapter.client.Close() })
+	return adapter, redisServer
+}
+
+var testRedisKeyCount int32
+
+func newTestRedisKey() string {
+	return fmt.Sprintf("dlq:%d", atomic.AddInt32(&testRedisKeyCount, 1))
+}
+
+func TestRedisAdapter_Streams(t *testing.T) {
+	adapter, redisServer := newTestRedisAdapter(t, "", "body")
+
+	testAdapter(t, adapterTestHarness{
+		adapter: adapter,
+		newQueue: func(t *testing.T) string {
+			return newTestRedisKey()
+		},
+		send: func(t *testing.T, queueName string, bodies ...string) {
+			for _, body := range bodies {
+				if _, err := redisServer.XAdd(queueName, "*", []string{"body", body}); err != nil {
+					t.Fatalf("unable to add to %s: %v", queueName, err)
+				}
+			}
+		},
+	})
+}
+
+func TestRedisAdapter_Lists(t *testing.T) {
+	adapter, redisServer := newTestRedisAdapter(t, "", "")
+
+	testAdapter(t, adapterTestHarness{
+		adapter: adapter,
+		newQueue: func(t *testing.T) string {
+			return newTestRedisKey()
+		},
+		send: func(t *testing.T, queueName string, bodies ...string) {
+			for _, body := range bodies {
+				if _, err := redisServer.Push(queueName, body); err != nil {
+					t.Fatalf("unable to push to %s: %v", queueName, err)
+				}
+			}
+		},
+		noTimestamps: true,
+	})
+}
+
+func TestRedisAdapter_GetAllQueues(t *testing.T) {
+	adapter, redisServer := newTestRedisAdapter(t, "dlq:*", "")
+
+	redisServer.XAdd("dlq:orders", "*", []string{"body", "one"})
+	redisServer.XAdd("dlq:orders", "*", []string{"body", "two"})
+	redisServer.Push("dlq:jobs", "job")
+	redisServer.Set("dlq:config", "not a queue")
+	redisServer.Push("jobs", "not matching")
+
+	queues, err := adapter.GetAllQueues(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllQueues() error = %v", err)
+	}
+	if len(queues) != 2 || queues[0].Name != "dlq:jobs" || queues[1].Name != "dlq:orders" {
+		t.Fatalf("GetAllQueues() = %v", queues)
+	}
+	if info := queues[0].Info; info["Type"] != "list" || info["Size"] != "1" {
+		t.Errorf("GetAllQueues() list info = %v", info)
+	}
+	if info := queues[1].Info; info["Type"] != "stream" || info["Size"] != "2" || info["Groups"] != "0" {
+		t.Errorf("GetAllQueues() stream info = %v", info)
+	}
+}
+
+func TestRedisAdapter_StreamBodies(t *testing.T) {
+	ctx := context.Background()
+	adapter, redisServer := newTestRedisAdapter(t, "", "body")
+	redisServer.XAdd("dlq:orders", "1526919030474-0", []string{"body", "payload", "error", "timeout"})
+	redisServer.XAdd("dlq:orders", "1526919030475-0", []string{"error", "no body"})
+
+	messages, err := adapter.GetAllMessages(ctx, "dlq%3Aorders")
+	if err != nil {
+		t.Fatalf("GetAllMessages() error = %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("GetAllMessages() = %v", messages)
+	}
+	first := messages[0]
+	if first.MessageID != "1526919030474-0" || first.Body != "payload" || first.Headers["error"] != "timeout" || first.Headers["Key"] != "dlq:orders" {
+		t.Errorf("GetAllMessages() first message = %v", first)
+	}
+	if first.Timestamp.UnixNano() != 1526919030474*1e6 {
+		t.Errorf("GetAllMessages() first timestamp = %v", first.Timestamp)
+	}
+	// an entry without the body field shows all of its fields
+	if messages[1].Body != `{"error":"no body"}` {
+		t.Errorf("GetAllMessages() second body = %s", messages[1].Body)
+	}
+}
+
+func TestRedisAdapter_RepeatedListValues(t *testing.T) {
+	ctx := context.Background()
+	adapter, redisServer := newTestRedisAdapter(t, "", "")
+	redisServer.Push("dlq:jobs", "same", "other", "same")
+
+	messages, err := adapter.GetAllMessages(ctx, "dlq:jobs")
+	if err != nil {
+		t.Fatalf("GetAllMessages() error = %v", err)
+	}
+	if len(messages) != 3 || messages[2].MessageID != messages[0].MessageID+"-2" || messages[1].Headers["Index"] != "1" {
+		t.Fatalf("GetAllMessages() = %v", messages)
+	}
+
+	if err := resultError(adapter.DeleteMany(ctx, "dlq:jobs", []string{messages[0].MessageID, messages[2].MessageID})); err != nil {
+		t.Fatalf("DeleteMany() error = %v", err)
+	}
+	if values, _ := redisServer.List("dlq:jobs"); len(values) != 1 || values[0] != "other" {
+		t.Errorf("list after deleting repeated values = %v", values)
+	}
+}
+
+func TestRedisAdapter_ListWindows(t *testing.T) {
+	ctx := context.Background()
+	adapter, redisServer := newTestRedisAdapter(t, "", "")
+	redisServer.Push("dlq:jobs", "a", "b", "c", "d", "e")
+
+	messages, err := adapter.GetAllMessages(ctx, "dlq:jobs")
+	if err != nil || len(messages) != 5 {
+		t.Fatalf("GetAllMessages() = %v, %v", messages, err)
+	}
+
+	// each script run looks at two entries
+	adapter.maxMessages = 2
+	messageIDs := []string{messages[0].MessageID, messages[3].MessageID, messages[4].MessageID, "unknown"}
+	results := adapter.Move(ctx, "dlq:jobs", "jobs", messageIDs)
+	wantResults(t, "Move()", results, messageIDs, structs.MessageMoved, structs.MessageMoved, structs.MessageMoved, structs.MessageNotFound)
+	if values, _ := redisServer.List("dlq:jobs"); fmt.Sprint(values) != "[b c]" {
+		t.Errorf("list after the move = %v", values)
+	}
+	if values, _ := redisServer.List("jobs"); fmt.Sprint(values) != "[a d e]" {
+		t.Errorf("destination after the move = %v", values)
+	}
+}
+
+func TestRedisAdapter_MoveBetweenTypes(t *testing.T) {
+	ctx := context.Background()
+	adapter, redisServer := newTestRedisAdapter(t, "", "")
+	id, _ := redisServer.XAdd("dlq:orders", "*", []string{"body", "one"})
+	redisServer.Push("dlq:jobs", "job")
+
+	if err := adapter.MoveOne(ctx, "dlq:orders", "dlq:jobs", id); err == nil {
+		t.Errorf("MoveOne() from a stream to a list should fail")
+	}
+	if messages, _ := adapter.GetAllMessages(ctx, "dlq:orders"); len(messages) != 1 {
+		t.Errorf("GetAllMessages() after failed move = %v", messages)
+	}
+
+	redisServer.Set("dlq:config", "value")
+	if _, err := adapter.GetAllMessages(ctx, "dlq:config"); err == nil {
+		t.Errorf("GetAllMessages() of a string key should fail")
+	}
+}
+
+func TestRedisAdapter_GetFilteredMessages(t *testing.T) {
+	ctx := context.Background()
+	adapter, redisServer := newTestRedisAdapter(t, "", "body")
+	redisServer.XAdd("dlq:orders", "1526919030474-0", []string{"body", "timeout", "error", "timeout"})
+	redisServer.XAdd("dlq:orders", "1526919030475-0", []string{"body", "refused", "error", "refused"})
+	redisServer.XAdd("dlq:orders", "1526919030475-1", []string{"body", "timeout again", "error", "timeout"})
+	redisServer.XAdd("dlq:orders", "1526919030476-0", []string{"body", "timeout at last", "error", "timeout"})
+
+	messages, err := adapter.GetFilteredMessages(ctx, "dlq:orders", MessageFilter{
+		FromDate: time.Unix(0, 1526919030475*1e6),
+		ToDate:   time.Unix(0, 1526919030475*1e6),
+		Headers:  map[string]string{"error": "time"},
+	})
+	if err != nil || len(messages) != 1 || messages[0].MessageID != "1526919030475-1" {
+		t.Errorf("GetFilteredMessages() = %v, %v, want the timeout within the millisecond", messages, err)
+	}
+}
@@ -0,0 +1,108 @@
+package adapters
+
+import (
+	"errors"
+
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// messageError is an error about one message of an operation on many messages
+type messageError struct {
+	messageID string
+	err       error
+}
+
+func (e *messageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *messageError) Unwrap() error {
+	return e.err
+}
+
+// forMessage ties err to the message, so it is reported for that message alone
+func forMessage(messageID string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &messageError{messageID: messageID, err: err}
+}
+
+// forMessages ties err to each of the messages, for a failure that befell all of them
+func forMessages(messageIDs []string, err error) []error {
+	var errs []error
+	for _, messageID := range messageIDs {
+		errs = append(errs, forMessage(messageID, err))
+	}
+	return errs
+}
+
+// errorMessageID returns the message an error is about, if it is about one
+func errorMessageID(err error) (string, bool) {
+	var msgErr *messageError
+	if errors.As(err, &msgErr) {
+		return msgErr.messageID, true
+	}
+	var notFound *MessageNotFoundError
+	if errors.As(err, &notFound) {
+		return notFound.MessageID, true
+	}
+	return "", false
+}
+
+// messageResults gives each of messageIDs its result, in order.  An error about a message is that message's result.
+// An error about no message in particular, such as the queue not being found, is the result of every message
+// without an error of its own, or a result without a message ID when there are no messages, so it isn't lost.  The
+// other messages have the status.
+func messageResults(messageIDs []string, status structs.MessageStatus, errs []error) []structs.MessageResult {
+	messageErrors := make(map[string]error)
+	var general error
+	for _, err := range errs {
+		messageID, ok := errorMessageID(err)
+		if !ok {
+			if general == nil {
+				general = err
+			}
+			continue
+		}
+		if _, seen := messageErrors[messageID]; !seen {
+			messageErrors[messageID] = err
+		}
+	}
+
+	if len(messageIDs) == 0 && general != nil {
+		return []structs.MessageResult{messageResult("", status, general)}
+	}
+
+	results := make([]structs.MessageResult, len(messageIDs))
+	for i, messageID := range messageIDs {
+		err, ok := messageErrors[messageID]
+		if !ok {
+			err = general
+		}
+		results[i] = messageResult(messageID, status, err)
+	}
+	return results
+}
+
+// messageResult is the result of a message: the status, or the error when there is one
+func messageResult(messageID string, status structs.MessageStatus, err error) structs.MessageResult {
+	if err == nil {
+		return structs.MessageResult{MessageID: messageID, Status: status}
+	}
+	status = structs.MessageFailed
+	if errors.Is(err, ErrMessageNotFound) {
+		status = structs.MessageNotFound
+	}
+	return structs.MessageResult{MessageID: messageID, Status: status, Error: err.Error(), Err: err}
+}
+
+// resultError returns the error of the first message that wasn't moved or deleted, for operations on one message
+func resultError(results []structs.MessageResult) error {
+	for _, result := range results {
+		if result.Err != nil {
+			return result.Err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jmsSelectorBatchSize caps how many message IDs go into one selector, keeping it small enough for brokers to parse
+const jmsSelectorBatchSize = 100
+
+// jmsStringLiteral quotes a value as a JMS selector string literal
+func jmsStringLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// amqpNoPrefix is how ActiveMQ prefixes the JMSMessageID of a message whose AMQP message ID is a string without the
+// ID: prefix
+const amqpNoPrefix = "ID:AMQP_NO_PREFIX:"
+
+// messageIDSelector returns a JMS selector matching the messages with the given IDs.  An ID without the ID: prefix
+// is also matched as ActiveMQ knows the message sent by an AMQP 1.0 client with that ID.
+func messageIDSelector(messageIDs []string) string {
+	var literals []string
+	for _, messageID := range messageIDs {
+		literals = append(literals, jmsStringLiteral(messageID))
+		if !strings.HasPrefix(messageID, "ID:") {
+			literals = append(literals, jmsStringLiteral(amqpNoPrefix+messageID))
+		}
+	}
+	if len(literals) == 1 {
+		return "JMSMessageID = " + literals[0]
+	}
+	return "JMSMessageID IN (" + strings.Join(literals, ", ") + ")"
+}
+
+// filterSelector returns a JMS selector for the criteria of the filter a selector can evaluate, or an empty selector
+// when there are none.  The message ID is only selected on for brokers whose message IDs are JMSMessageIDs.  The
+// rest of the filter is left to MessageFilter.Matches.
+func filterSelector(filter MessageFilter, selectMessageID bool) string {
+	var conditions []string
+	if selectMessageID && filter.MessageID != "" {
+		conditions = append(conditions, "JMSMessageID LIKE "+jmsContainsPattern(filter.MessageID))
+	}
+	if filter.CorrelationID != "" {
+		conditions = append(conditions, "JMSCorrelationID LIKE "+jmsContainsPattern(filter.CorrelationID))
+	}
+	if !filter.FromDate.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("JMSTimestamp >= %d", filter.FromDate.UnixNano()/int64(time.Millisecond)))
+	}
+	if !filter.ToDate.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("JMSTimestamp <= %d", filter.ToDate.UnixNano()/int64(time.Millisecond)))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// jmsContainsPattern returns a LIKE pattern matching values that contain text
+func jmsContainsPattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text)
+	return jmsStringLiteral("%"+escaped+"%") + ` ESCAPE '\'`
+}
+
+// headerSelector returns a JMS selector matching the messages whose headers hold exactly the given string values.
+// Header names must be JMS identifiers, so they can't smuggle in a selector of their own.
+func headerSelector(headers map[string]string) (string, error) {
+	if len(headers) == 0 {
+		return "", badRequest(fmt.Errorf("no headers given to select messages by"))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if !isJMSIdentifier(name) {
+			return "", badRequest(fmt.Errorf("%q can't be used in a selector, header names must be letters, digits, _ or $", name))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	conditions := make([]string, len(names))
+	for i, name := range names {
+		conditions[i] = name + " = " + jmsStringLiteral(headers[name])
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// isJMSIdentifier tells whether name is a JMS selector identifier, and not one of the selector's keywords
+func isJMSIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !letter && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	switch strings.ToUpper(name) {
+	case "NULL", "TRUE", "FALSE", "NOT", "AND", "OR", "BETWEEN", "LIKE", "IN", "IS", "ESCAPE":
+		return false
+	}
+	return true
+}
@@ -2,156 +2,1135 @@ package adapters
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/url"
-	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"gitlab.com/ciorg/bridge/brokerUI/broker-service/configuration"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/uuid"
 	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
 )
 
+// NewSQSAdapter returns an SQS adapter.  Credentials come from, in order of preference:
+// ACCESS_KEY/SECRET_KEY (and SESSION_TOKEN) when set, otherwise the named PROFILE from the shared config files,
+// otherwise the default AWS credential chain (environment, shared config, container or instance role).
+// When ROLE_ARN is set those credentials are used to assume that role (with EXTERNAL_ID if given).
+// ENDPOINT points the adapter at something other than AWS, like LocalStack or ElasticMQ.
 func NewSQSAdapter(config configuration.BrokerConfiguration) *SQSAdapter {
 
 	region := config.All["REGION"]
 	accessKey := config.All["ACCESS_KEY"]
 	secretKey := config.All["SECRET_KEY"]
+	sessionToken := config.All["SESSION_TOKEN"]
+	endpoint := config.All["ENDPOINT"]
+	profile := config.All["PROFILE"]
+	roleArn := config.All["ROLE_ARN"]
+	externalID := config.All["EXTERNAL_ID"]
 
-	sessionConfig := &aws.Config{
-		Region:      aws.String(region),
-		Credentials: credentials.NewStaticCredentials(accessKey, secretKey, ""),
+	sessionConfig := aws.Config{}
+	if region != "" {
+		sessionConfig.Region = aws.String(region)
+	}
+	if endpoint != "" {
+		sessionConfig.Endpoint = aws.String(endpoint)
+	}
+	if accessKey != "" || secretKey != "" {
+		sessionConfig.Credentials = credentials.NewStaticCredentials(accessKey, secretKey, sessionToken)
 	}
 
-	awsSession, err := session.NewSession(sessionConfig)
+	awsSession, err := session.NewSessionWithOptions(session.Options{
+		Config:            sessionConfig,
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
 	if err != nil {
 		log.Printf("error establishing AWS session: %s\n", err)
 		return nil
 	}
 
-	return &SQSAdapter{awsSession: awsSession}
+	if roleArn != "" {
+		awsSession = awsSession.Copy(&aws.Config{
+			Credentials: stscreds.NewCredentials(awsSession, roleArn, func(provider *stscreds.AssumeRoleProvider) {
+				provider.RoleSessionName = "broker-service"
+				if externalID != "" {
+					provider.ExternalID = aws.String(externalID)
+				}
+			}),
+		})
+	}
+
+	adapter := &SQSAdapter{
+		awsSession:                 awsSession,
+		maxBrowseMessages:          intConfigValue(config.All, "MAX_MESSAGES", sqsDefaultMaxBrowseMessages),
+		browseReceivers:            intConfigValue(config.All, "RECEIVERS", sqsDefaultBrowseReceivers),
+		browseVisibilityTimeout:    int64(intConfigValue(config.All, "VISIBILITY_TIMEOUT", sqsDefaultBrowseVisibilityTimeout)),
+		cloudWatchMetrics:          strings.EqualFold(config.All["CLOUDWATCH_METRICS"], "true"),
+		defaultMessageGroupID:      sqsDefaultMessageGroupID,
+		regenerateDeduplicationIDs: strings.EqualFold(config.All["REGENERATE_DEDUPLICATION_IDS"], "true"),
+		queuePrefix:                config.All["QUEUE_PREFIX"],
+		queueURLs:                  make(map[string]string),
+	}
+	if groupID := config.All["MESSAGE_GROUP_ID"]; groupID != "" {
+		adapter.defaultMessageGroupID = groupID
+	}
+
+	return adapter
 
 }
 
+// intConfigValue reads a positive number from the broker configuration, falling back to the default when the
+// value is missing or unusable
+func intConfigValue(values map[string]string, key string, defaultValue int) int {
+	value, ok := values[key]
+	if !ok || value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number <= 0 {
+		log.Printf("ignoring invalid %s value %q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+const (
+	// sqsMaxBatchSize is the most messages SQS will hand out or accept in a single call
+	sqsMaxBatchSize = 10
+	// sqsMaxListQueuesResults is the largest page of queue URLs SQS will return
+	sqsMaxListQueuesResults = 1000
+	// sqsOperationVisibilityTimeout is how long (seconds) messages stay hidden while a move or delete is working on them
+	sqsOperationVisibilityTimeout = 60
+	// sqsMaxSearchSeconds is how long a move, delete or redrive keeps receiving to find its messages, so the first ones
+	// found are still hidden while they are dealt with
+	sqsMaxSearchSeconds = sqsOperationVisibilityTimeout / 2
+
+	// sqsDefaultMaxBrowseMessages caps how many messages a single browse returns
+	sqsDefaultMaxBrowseMessages = 1000
+	// sqsDefaultBrowseReceivers is how many ReceiveMessage loops run in parallel while browsing
+	sqsDefaultBrowseReceivers = 4
+	// sqsDefaultBrowseVisibilityTimeout is how long (seconds) messages stay hidden while a browse is draining the queue
+	sqsDefaultBrowseVisibilityTimeout = 60
+
+	// sqsQueueAttributeNameRedriveAllowPolicy is newer than the SDK's queue attribute constants
+	sqsQueueAttributeNameRedriveAllowPolicy = "RedriveAllowPolicy"
+	// sqsMessageSystemAttributeNameDeadLetterQueueSourceArn is set by SQS on messages it has dead-lettered
+	sqsMessageSystemAttributeNameDeadLetterQueueSourceArn = "DeadLetterQueueSourceArn"
+
+	// sqsDefaultMessageGroupID is the group messages from a standard queue are put in when sent to a FIFO queue
+	sqsDefaultMessageGroupID = "brokerui"
+
+	// sqsQueueInfoWorkers is how many GetQueueAttributes calls run in parallel when listing queues
+	sqsQueueInfoWorkers = 10
+)
+
 type SQSAdapter struct {
-	awsSession *session.Session
+	awsSession                 *session.Session
+	maxBrowseMessages          int
+	browseReceivers            int
+	browseVisibilityTimeout    int64
+	cloudWatchMetrics          bool
+	defaultMessageGroupID      string
+	regenerateDeduplicationIDs bool
+	queuePrefix                string
+
+	queueURLMutex sync.Mutex
+	queueURLs     map[string]string
 }
 
 func (s *SQSAdapter) GetAllMessages(ctx context.Context, encodedQueueName string) ([]structs.StandardMessage, error) {
+	return s.browseMessages(ctx, encodedQueueName, s.maxBrowseMessages)
+}
 
-	queueName, _ := url.QueryUnescape(encodedQueueName)
+// GetMessages pages the queue by offset, browsing it up to the end of the page.  Only FIFO queues keep their
+// messages in order, so pages of a standard queue can repeat or miss messages.
+func (s *SQSAdapter) GetMessages(ctx context.Context, encodedQueueName string, page PageRequest) (MessagePage, error) {
+	offset, err := pageOffset(page)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	limit := pageLimit(page)
+
+	messages, err := s.browseMessages(ctx, encodedQueueName, offset+limit+1)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	return offsetPage(messages, offset, limit), nil
+}
+
+// browseMessages receives up to limit of the queue's messages and makes them visible again
+func (s *SQSAdapter) browseMessages(ctx context.Context, encodedQueueName string, limit int) ([]structs.StandardMessage, error) {
+
+	svc, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	queueName, err := s.getQueueURL(ctx, svc, encodedQueueName)
+	if err != nil {
+		return nil, err
+	}
+
+	received, _, err := s.receiveAllMessages(ctx, svc, queueName, s.browseVisibilityTimeout, limit, time.Time{})
+	if isFIFOQueue(queueName) {
+		sortBySequenceNumber(received)
+	}
+
+	// browsing is done, so there is no reason to keep anything hidden from other consumers
+	defer s.releaseMessages(context.Background(), svc, queueName, received)
+
+	if err != nil {
+		return nil, err
+	}
 
 	messages := make([]structs.StandardMessage, 0)
+	seen := make(map[string]bool)
+
+	for _, message := range received {
+		messageID := aws.StringValue(message.MessageId)
+		if seen[messageID] {
+			continue
+		}
+		seen[messageID] = true
+
+		if len(messages) >= limit {
+			continue
+		}
+
+		messages = append(messages, convertSQSMessage(message))
+	}
+
+	return messages, nil
+}
+
+// receiveAllMessages drains the queue with several receivers running side by side.  Everything received stays
+// hidden for the visibility timeout, so each receiver only ever sees messages the others have not.  The
+// receivers stop when the queue hands back nothing, if limit is above zero once limit messages have been seen and,
+// if the deadline isn't zero, once it has passed.  Receives already in flight then are left to finish rather than
+// cancelled, so every message taken off the queue is returned and can be made visible again.  drained tells
+// whether the receivers stopped because the queue had nothing left to hand out.
+func (s *SQSAdapter) receiveAllMessages(ctx context.Context, svc *sqs.SQS, queueURL string,
+	visibilityTimeout int64, limit int, deadline time.Time) ([]*sqs.Message, bool, error) {
+	var mutex sync.Mutex
+	var waitGroup sync.WaitGroup
+	var received []*sqs.Message
+	var receiveErr error
+	stopped := false
+	drained := false
+
+	uniqueIDs := make(map[string]bool)
+
+	// a FIFO queue hands out messages in order, so receivers in parallel would only be waiting on each other
+	receivers := s.browseReceivers
+	if isFIFOQueue(queueURL) {
+		receivers = 1
+	}
+
+	for i := 0; i < receivers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for {
+				mutex.Lock()
+				if !deadline.IsZero() && time.Now().After(deadline) {
+					stopped = true
+				}
+				done := stopped
+				mutex.Unlock()
+				if done {
+					return
+				}
+
+				receiveMessageOutput, err := svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+					AttributeNames: []*string{
+						aws.String(sqs.QueueAttributeNameAll),
+					},
+					MessageAttributeNames: []*string{
+						aws.String(sqs.QueueAttributeNameAll),
+					},
+					QueueUrl:            aws.String(queueURL),
+					MaxNumberOfMessages: aws.Int64(sqsMaxBatchSize),
+					WaitTimeSeconds:     aws.Int64(1),
+					VisibilityTimeout:   aws.Int64(visibilityTimeout),
+				})
+
+				mutex.Lock()
+				if err != nil {
+					if receiveErr == nil {
+						receiveErr = err
+					}
+					stopped = true
+					mutex.Unlock()
+					return
+				}
+				if receiveMessageOutput == nil || len(receiveMessageOutput.Messages) == 0 {
+					drained = true
+					mutex.Unlock()
+					return
+				}
 
-	svc := sqs.New(s.awsSession, nil)
-	receiveMessagesInput := &sqs.ReceiveMessageInput{
+				received = append(received, receiveMessageOutput.Messages...)
+				for _, message := range receiveMessageOutput.Messages {
+					uniqueIDs[aws.StringValue(message.MessageId)] = true
+				}
+				if limit > 0 && len(uniqueIDs) >= limit {
+					stopped = true
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	waitGroup.Wait()
+
+	return received, drained, receiveErr
+}
+
+func convertSQSMessage(message *sqs.Message) structs.StandardMessage {
+	timestamp, _ := parseEpochMillis(aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]))
+
+	headers := make(map[string]string)
+	for attributekey, attributeval := range message.Attributes {
+		headers[attributekey] = *attributeval
+	}
+	if firstReceived, ok := parseEpochMillis(aws.StringValue(
+		message.Attributes[sqs.MessageSystemAttributeNameApproximateFirstReceiveTimestamp])); ok {
+		headers["FirstReceived"] = firstReceived.Format(time.RFC3339Nano)
+	}
+	for attributeKey, attributeVal := range message.MessageAttributes {
+		if attributeVal.StringValue != nil {
+			headers[attributeKey] = *attributeVal.StringValue
+		} else {
+			headers[attributeKey] = base64.StdEncoding.EncodeToString(attributeVal.BinaryValue)
+		}
+	}
+
+	return structs.StandardMessage{
+		MessageID: *message.MessageId,
+		Timestamp: timestamp,
+		Headers:   headers,
+		Body:      *message.Body,
+	}
+}
+
+func (s *SQSAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+
+	queues := make([]Queue, 0)
+	svc, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	listQueuesInput := &sqs.ListQueuesInput{
+		MaxResults: aws.Int64(sqsMaxListQueuesResults),
+	}
+	if s.queuePrefix != "" {
+		listQueuesInput.QueueNamePrefix = aws.String(s.queuePrefix)
+	}
+
+	var queueURLs []string
+	err = svc.ListQueuesPagesWithContext(ctx, listQueuesInput, func(listQueuesOutput *sqs.ListQueuesOutput, lastPage bool) bool {
+		for _, queueUrl := range listQueuesOutput.QueueUrls {
+			if queueUrl == nil {
+				continue
+			}
+			queue := Queue{
+				Name: queueNameFromURL(*queueUrl),
+				Info: map[string]string{"URL": *queueUrl},
+			}
+			queues = append(queues, queue)
+			queueURLs = append(queueURLs, *queueUrl)
+			s.cacheQueueURL(queue.Name, *queueUrl)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, sqsError("", err)
+	}
+
+	s.addQueueInfo(ctx, svc, queues, queueURLs)
+	linkDeadLetterQueues(queues)
+
+	return queues, nil
+}
+
+// linkDeadLetterQueues records on every dead-letter queue which queues send their failed messages to it
+func linkDeadLetterQueues(queues []Queue) {
+	byArn := make(map[string]*Queue)
+	for i := range queues {
+		if arn := queues[i].Info["Arn"]; arn != "" {
+			byArn[arn] = &queues[i]
+		}
+	}
+
+	sources := make(map[string][]string)
+	for _, queue := range queues {
+		target := queue.Info["DeadLetterTargetArn"]
+		if _, ok := byArn[target]; ok {
+			sources[target] = append(sources[target], queue.Name)
+		}
+	}
+
+	for arn, sourceQueues := range sources {
+		sort.Strings(sourceQueues)
+		byArn[arn].Info["SourceQueues"] = strings.Join(sourceQueues, ",")
+	}
+}
+
+// addQueueInfo fills in each queue's Info with its attributes.  The lookups run a few at a time since accounts
+// can have a lot of queues.  A queue whose attributes can't be read is still listed, just without statistics.
+func (s *SQSAdapter) addQueueInfo(ctx context.Context, svc *sqs.SQS, queues []Queue, queueURLs []string) {
+	var waitGroup sync.WaitGroup
+	work := make(chan int)
+
+	for i := 0; i < sqsQueueInfoWorkers; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for i := range work {
+				info, err := s.getQueueInfo(ctx, svc, queueURLs[i])
+				if err != nil {
+					log.Printf("unable to get attributes for queue %s: %s", queueURLs[i], err)
+					continue
+				}
+				for key, value := range info {
+					queues[i].Info[key] = value
+				}
+			}
+		}()
+	}
+
+	for i := range queues {
+		work <- i
+	}
+	close(work)
+
+	waitGroup.Wait()
+}
+
+// getQueueInfo turns a queue's SQS attributes into the Info map shown by the UI
+func (s *SQSAdapter) getQueueInfo(ctx context.Context, svc *sqs.SQS, queueURL string) (map[string]string, error) {
+	output, err := svc.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(queueURL),
 		AttributeNames: []*string{
-			aws.String(sqs.MessageSystemAttributeNameSentTimestamp),
-		},
-		MessageAttributeNames: []*string{
 			aws.String(sqs.QueueAttributeNameAll),
 		},
-		QueueUrl:            aws.String(queueName),
-		MaxNumberOfMessages: aws.Int64(10), // max 10
-		WaitTimeSeconds:     aws.Int64(3),  // max 20
-		VisibilityTimeout:   aws.Int64(20), // max 20
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	receiveMessageOutput, err :=
-		svc.ReceiveMessage(receiveMessagesInput)
+	attributes := aws.StringValueMap(output.Attributes)
+	info := map[string]string{
+		"Size":       attributes[sqs.QueueAttributeNameApproximateNumberOfMessages],
+		"NotVisible": attributes[sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible],
+		"Delayed":    attributes[sqs.QueueAttributeNameApproximateNumberOfMessagesDelayed],
+		"Arn":        attributes[sqs.QueueAttributeNameQueueArn],
+	}
 
-	if err != nil {
-		return nil, err
+	if attributes[sqs.QueueAttributeNameFifoQueue] == "true" {
+		info["Fifo"] = "true"
+		info["ContentBasedDeduplication"] = attributes[sqs.QueueAttributeNameContentBasedDeduplication]
 	}
 
-	if receiveMessageOutput == nil || len(receiveMessageOutput.Messages) == 0 {
-		return messages, nil
+	if created, ok := parseEpochSeconds(attributes[sqs.QueueAttributeNameCreatedTimestamp]); ok {
+		info["Created"] = created.Format(time.RFC3339)
+	}
+	if modified, ok := parseEpochSeconds(attributes[sqs.QueueAttributeNameLastModifiedTimestamp]); ok {
+		info["LastModified"] = modified.Format(time.RFC3339)
 	}
 
-	for _, message := range receiveMessageOutput.Messages {
-		timestamp, _ := time.Parse("", *message.Attributes["SentTimestamp"])
+	if redrivePolicy := attributes[sqs.QueueAttributeNameRedrivePolicy]; redrivePolicy != "" {
+		info["RedrivePolicy"] = redrivePolicy
 
-		headers := make(map[string]string)
-		for attributekey, attributeval := range message.Attributes {
-			headers[attributekey] = *attributeval
+		var policy sqsRedrivePolicy
+		if err := json.Unmarshal([]byte(redrivePolicy), &policy); err != nil {
+			log.Printf("unable to parse redrive policy for queue %s: %s", queueURL, err)
+		} else {
+			info["DeadLetterTargetArn"] = policy.DeadLetterTargetArn
+			info["MaxReceiveCount"] = policy.MaxReceiveCount.String()
 		}
-		for attributeKey, attributeVal := range message.MessageAttributes {
-			headers[attributeKey] = attributeVal.String()
+	}
+
+	if redriveAllowPolicy := attributes[sqsQueueAttributeNameRedriveAllowPolicy]; redriveAllowPolicy != "" {
+		info["RedriveAllowPolicy"] = redriveAllowPolicy
+
+		var policy sqsRedriveAllowPolicy
+		if err := json.Unmarshal([]byte(redriveAllowPolicy), &policy); err != nil {
+			log.Printf("unable to parse redrive allow policy for queue %s: %s", queueURL, err)
+		} else {
+			info["RedrivePermission"] = policy.RedrivePermission
 		}
+	}
 
-		stdMsg := structs.StandardMessage{
-			MessageID: *message.MessageId,
-			Timestamp: timestamp,
-			Headers:   headers,
-			Body:      *message.Body,
+	if s.cloudWatchMetrics {
+		age, err := s.getOldestMessageAge(ctx, queueURL)
+		if err != nil {
+			log.Printf("unable to get the oldest message age for queue %s: %s", queueURL, err)
+		} else if age != "" {
+			info["OldestMessageAge"] = age
 		}
+	}
 
-		messages = append(messages, stdMsg)
+	return info, nil
+}
+
+// getOldestMessageAge looks up the age (seconds) of the oldest message in the queue.  SQS only publishes this
+// through CloudWatch, so it is the most recent ApproximateAgeOfOldestMessage data point, if there is one.
+func (s *SQSAdapter) getOldestMessageAge(ctx context.Context, queueURL string) (string, error) {
+	queueName := queueNameFromURL(queueURL)
+	now := time.Now().UTC()
+
+	output, err := cloudwatch.New(s.awsSession).GetMetricStatisticsWithContext(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String("AWS/SQS"),
+		MetricName: aws.String("ApproximateAgeOfOldestMessage"),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("QueueName"), Value: aws.String(queueName)},
+		},
+		StartTime:  aws.Time(now.Add(-15 * time.Minute)),
+		EndTime:    aws.Time(now),
+		Period:     aws.Int64(60),
+		Statistics: []*string{aws.String(cloudwatch.StatisticMaximum)},
+	})
+	if err != nil {
+		return "", err
 	}
 
-	// NOTE: no need to NACK.  Message will automatically go back into queue in 20 seconds.
+	var latest *cloudwatch.Datapoint
+	for _, datapoint := range output.Datapoints {
+		if latest == nil || aws.TimeValue(datapoint.Timestamp).After(aws.TimeValue(latest.Timestamp)) {
+			latest = datapoint
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
 
-	// TODO this only gets 10 messages at a time.  Loop back and get more.  Might have to be in multiple go routines?
+	return strconv.FormatFloat(aws.Float64Value(latest.Maximum), 'f', 0, 64), nil
+}
 
-	return messages, nil
+// sqsRedrivePolicy is the JSON document SQS keeps in a queue's RedrivePolicy attribute
+type sqsRedrivePolicy struct {
+	DeadLetterTargetArn string      `json:"deadLetterTargetArn"`
+	MaxReceiveCount     json.Number `json:"maxReceiveCount"`
 }
 
-func (s *SQSAdapter) GetAllQueues(ctx context.Context) ([]Queue, error) {
+// sqsRedriveAllowPolicy is the JSON document SQS keeps in a dead-letter queue's RedriveAllowPolicy attribute
+type sqsRedriveAllowPolicy struct {
+	RedrivePermission string   `json:"redrivePermission"`
+	SourceQueueArns   []string `json:"sourceQueueArns"`
+}
 
-	queues := make([]Queue, 0)
-	svc := sqs.New(s.awsSession, nil)
-	if svc == nil {
-		log.Println("There was a problem getting a new SQS instance")
-		os.Exit(1)
+// parseEpochMillis converts SQS message timestamps (milliseconds since the epoch) to a time
+func parseEpochMillis(value string) (time.Time, bool) {
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
 	}
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC(), true
+}
 
-	listQueuesOutput, err := svc.ListQueues(nil)
+// parseEpochSeconds converts SQS queue timestamps (seconds since the epoch) to a time
+func parseEpochSeconds(value string) (time.Time, bool) {
+	seconds, err := strconv.ParseInt(value, 10, 64)
 	if err != nil {
-		return nil, err
+		return time.Time{}, false
 	}
-	if listQueuesOutput == nil || len(listQueuesOutput.QueueUrls) == 0 {
-		return queues, nil
+	return time.Unix(seconds, 0).UTC(), true
+}
+
+func (s *SQSAdapter) Move(ctx context.Context, fromEncodedQueueName string, toEncodedQueueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageMoved, s.move(ctx, fromEncodedQueueName, toEncodedQueueName, messageIDs))
+}
+
+func (s *SQSAdapter) move(ctx context.Context, fromEncodedQueueName string, toEncodedQueueName string, messageIDs []string) []error {
+	var moveErrors []error
+
+	svc, err := s.getClient()
+	if err != nil {
+		return append(moveErrors, err)
 	}
 
-	for _, queueUrl := range listQueuesOutput.QueueUrls {
-		if queueUrl == nil {
-			continue
+	fromQueue, err := s.getQueueURL(ctx, svc, fromEncodedQueueName)
+	if err != nil {
+		return append(moveErrors, err)
+	}
+	toQueue, err := s.getQueueURL(ctx, svc, toEncodedQueueName)
+	if err != nil {
+		return append(moveErrors, err)
+	}
+
+	found, others, findErrors := s.receiveMessagesByID(ctx, svc, fromQueue, messageIDs)
+	moveErrors = append(moveErrors, findErrors...)
+	defer s.releaseMessages(ctx, svc, fromQueue, others)
+
+	var toMove []*sqs.Message
+	for _, messageID := range messageIDs {
+		if message, ok := found[messageID]; ok {
+			toMove = append(toMove, message)
 		}
-		queue := Queue{
-			Name: *queueUrl,
-			Info: nil,
+	}
+
+	return append(moveErrors, s.moveReceivedMessages(ctx, svc, fromQueue, toQueue, toMove)...)
+}
+
+// moveReceivedMessages sends messages that are currently hidden on the source queue to the destination and then
+// deletes the ones the destination accepted.  Anything that could not be sent is made visible on the source again.
+func (s *SQSAdapter) moveReceivedMessages(ctx context.Context, svc *sqs.SQS, fromQueue string, toQueue string,
+	messages []*sqs.Message) []error {
+
+	if isFIFOQueue(fromQueue) {
+		sortBySequenceNumber(messages)
+	}
+
+	sent, moveErrors := s.sendMessages(ctx, svc, toQueue, messages)
+
+	var unsent []*sqs.Message
+	for _, message := range messages {
+		if !containsMessage(sent, message) {
+			unsent = append(unsent, message)
 		}
-		queues = append(queues, queue)
 	}
+	s.releaseMessages(ctx, svc, fromQueue, unsent)
 
-	return queues, nil
+	return append(moveErrors, s.deleteMessages(ctx, svc, fromQueue, sent)...)
 }
 
-func (s *SQSAdapter) Move(ctx context.Context, fromEncodedQueueName string, toEncodedQueueName string, messageIDs []string) []error {
-	result := make([]error, 1)
-	result[1] = errors.New("Not implemented")
-	return result
+// Redrive sends dead-lettered messages back to the queue they came from.  SQS stamps the source queue ARN on
+// messages it dead-letters; for messages without it the source has to be the only queue whose redrive policy
+// points at this dead-letter queue.
+func (s *SQSAdapter) Redrive(ctx context.Context, encodedQueueName string, messageIDs []string) []structs.MessageResult {
+	redriven, redriveErrors, remainder := s.redrive(ctx, encodedQueueName, messageIDs)
+	results := messageResults(redriven, structs.MessageRedriven, redriveErrors)
+	if remainder != nil {
+		results = append(results, messageResult("", structs.MessageRedriven, remainder))
+	}
+	return results
+}
+
+// redrive returns the IDs of the messages it redrove, or tried to: the given ones, or those it received when
+// redriving the whole queue.  Redriving the whole queue receives for at most sqsMaxSearchSeconds, so the receipt
+// handles are still good when the messages are deleted; if the queue may hold more, remainder says so.
+func (s *SQSAdapter) redrive(ctx context.Context, encodedQueueName string, messageIDs []string) ([]string, []error, error) {
+	var redriveErrors []error
+	var remainder error
+
+	svc, err := s.getClient()
+	if err != nil {
+		return messageIDs, append(redriveErrors, err), nil
+	}
+
+	queueName, err := s.getQueueURL(ctx, svc, encodedQueueName)
+	if err != nil {
+		return messageIDs, append(redriveErrors, err), nil
+	}
+
+	sourceQueues, err := s.getDeadLetterSourceQueues(ctx, svc, queueName)
+	if err != nil {
+		return messageIDs, append(redriveErrors, err), nil
+	}
+
+	var received []*sqs.Message
+	var others []*sqs.Message
+	if len(messageIDs) == 0 {
+		var drained bool
+		deadline := time.Now().Add(sqsMaxSearchSeconds * time.Second)
+		received, drained, err = s.receiveAllMessages(ctx, svc, queueName, sqsOperationVisibilityTimeout, 0, deadline)
+		if err != nil {
+			s.releaseMessages(ctx, svc, queueName, received)
+			return messageIDs, append(redriveErrors, err), nil
+		}
+		if !drained {
+			remainder = fmt.Errorf("stopped receiving from %s after %d seconds, redrive again for the messages left on it",
+				queueNameFromURL(queueName), sqsMaxSearchSeconds)
+		}
+		received, others = latestReceives(received)
+		for _, message := range received {
+			messageIDs = append(messageIDs, aws.StringValue(message.MessageId))
+		}
+	} else {
+		var found map[string]*sqs.Message
+		var findErrors []error
+		found, others, findErrors = s.receiveMessagesByID(ctx, svc, queueName, messageIDs)
+		redriveErrors = append(redriveErrors, findErrors...)
+		for _, messageID := range messageIDs {
+			if message, ok := found[messageID]; ok {
+				received = append(received, message)
+			}
+		}
+	}
+	defer s.releaseMessages(ctx, svc, queueName, others)
+
+	bySourceQueue := make(map[string][]*sqs.Message)
+	var unknownSource []*sqs.Message
+	for _, message := range received {
+		sourceQueue := ""
+		if sourceArn := aws.StringValue(message.Attributes[sqsMessageSystemAttributeNameDeadLetterQueueSourceArn]); sourceArn != "" {
+			sourceQueue, err = s.getQueueURLForArn(ctx, svc, sourceArn)
+			if err != nil {
+				redriveErrors = append(redriveErrors, forMessage(aws.StringValue(message.MessageId),
+					fmt.Errorf("unable to find source queue for message %s: %s", aws.StringValue(message.MessageId), err)))
+				unknownSource = append(unknownSource, message)
+				continue
+			}
+		} else if len(sourceQueues) == 1 {
+			sourceQueue = sourceQueues[0]
+		} else {
+			redriveErrors = append(redriveErrors, forMessage(aws.StringValue(message.MessageId),
+				fmt.Errorf("unable to tell which of %d source queues message %s came from", len(sourceQueues), aws.StringValue(message.MessageId))))
+			unknownSource = append(unknownSource, message)
+			continue
+		}
+		bySourceQueue[sourceQueue] = append(bySourceQueue[sourceQueue], message)
+	}
+	s.releaseMessages(ctx, svc, queueName, unknownSource)
+
+	for sourceQueue, messages := range bySourceQueue {
+		redriveErrors = append(redriveErrors, s.moveReceivedMessages(ctx, svc, queueName, sourceQueue, messages)...)
+	}
+
+	return messageIDs, redriveErrors, remainder
+}
+
+// getDeadLetterSourceQueues lists the URLs of the queues whose redrive policy sends messages to this queue
+func (s *SQSAdapter) getDeadLetterSourceQueues(ctx context.Context, svc *sqs.SQS, queueURL string) ([]string, error) {
+	output, err := svc.ListDeadLetterSourceQueuesWithContext(ctx, &sqs.ListDeadLetterSourceQueuesInput{
+		QueueUrl: aws.String(queueURL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return aws.StringValueSlice(output.QueueUrls), nil
+}
+
+// getQueueURLForArn looks up a queue's URL from its ARN (arn:aws:sqs:region:account:name)
+func (s *SQSAdapter) getQueueURLForArn(ctx context.Context, svc *sqs.SQS, arn string) (string, error) {
+	parts := strings.Split(arn, ":")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("invalid queue ARN %s", arn)
+	}
+
+	output, err := svc.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{
+		QueueName:              aws.String(parts[5]),
+		QueueOwnerAWSAccountId: aws.String(parts[4]),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.QueueUrl), nil
+}
+
+// latestReceives splits messages into the most recent receive of each message ID and the older receives, whose
+// receipt handles can no longer be used to delete the message
+func latestReceives(messages []*sqs.Message) ([]*sqs.Message, []*sqs.Message) {
+	latest := make(map[string]int)
+	var unique []*sqs.Message
+	var older []*sqs.Message
+
+	for _, message := range messages {
+		messageID := aws.StringValue(message.MessageId)
+		if i, ok := latest[messageID]; ok {
+			older = append(older, unique[i])
+			unique[i] = message
+			continue
+		}
+		latest[messageID] = len(unique)
+		unique = append(unique, message)
+	}
+
+	return unique, older
 }
 
 func (s *SQSAdapter) MoveOne(ctx context.Context, fromQueue string, toQueue string, messageID string) error {
-	return errors.New("Not implemented")
+	return resultError(s.Move(ctx, fromQueue, toQueue, []string{messageID}))
 }
 
 func (s *SQSAdapter) Purge(ctx context.Context, encodedQueueName string) error {
-	return errors.New("Not implemented")
+	svc, err := s.getClient()
+	if err != nil {
+		return err
+	}
+
+	queueName, err := s.getQueueURL(ctx, svc, encodedQueueName)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.PurgeQueueWithContext(ctx, &sqs.PurgeQueueInput{
+		QueueUrl: aws.String(queueName),
+	})
+	return err
 }
 
 func (s *SQSAdapter) DeleteOne(ctx context.Context, encodedQueueName string, messageID string) error {
-	return errors.New("Not implemented")
+	return resultError(s.DeleteMany(ctx, encodedQueueName, []string{messageID}))
+}
+
+func (s *SQSAdapter) DeleteMany(ctx context.Context, encodedQueueName string, messageIDs []string) []structs.MessageResult {
+	return messageResults(messageIDs, structs.MessageDeleted, s.deleteMany(ctx, encodedQueueName, messageIDs))
+}
+
+func (s *SQSAdapter) deleteMany(ctx context.Context, encodedQueueName string, messageIDs []string) []error {
+	var deleteErrors []error
+
+	svc, err := s.getClient()
+	if err != nil {
+		return append(deleteErrors, err)
+	}
+
+	queueName, err := s.getQueueURL(ctx, svc, encodedQueueName)
+	if err != nil {
+		return append(deleteErrors, err)
+	}
+
+	found, others, findErrors := s.receiveMessagesByID(ctx, svc, queueName, messageIDs)
+	deleteErrors = append(deleteErrors, findErrors...)
+	defer s.releaseMessages(ctx, svc, queueName, others)
+
+	var toDelete []*sqs.Message
+	for _, messageID := range messageIDs {
+		if message, ok := found[messageID]; ok {
+			toDelete = append(toDelete, message)
+		}
+	}
+
+	return append(deleteErrors, s.deleteMessages(ctx, svc, queueName, toDelete)...)
+}
+
+// getQueueURL turns the queue name used by the UI into the queue URL SQS needs.  Names are looked up once and
+// remembered; a full queue URL (which is what older clients send) is used as it is.
+func (s *SQSAdapter) getQueueURL(ctx context.Context, svc *sqs.SQS, encodedQueueName string) (string, error) {
+	queueName, _ := url.QueryUnescape(encodedQueueName)
+	if strings.HasPrefix(queueName, "https://") || strings.HasPrefix(queueName, "http://") {
+		return queueName, nil
+	}
+
+	s.queueURLMutex.Lock()
+	queueURL, ok := s.queueURLs[queueName]
+	s.queueURLMutex.Unlock()
+	if ok {
+		return queueURL, nil
+	}
+
+	output, err := svc.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{
+		QueueName: aws.String(queueName),
+	})
+	if err != nil {
+		return "", sqsError(queueName, fmt.Errorf("unable to find queue %s: %w", queueName, err))
+	}
+
+	queueURL = aws.StringValue(output.QueueUrl)
+	s.cacheQueueURL(queueName, queueURL)
+	return queueURL, nil
+}
+
+func (s *SQSAdapter) cacheQueueURL(queueName string, queueURL string) {
+	s.queueURLMutex.Lock()
+	defer s.queueURLMutex.Unlock()
+
+	if s.queueURLs == nil {
+		s.queueURLs = make(map[string]string)
+	}
+	s.queueURLs[queueName] = queueURL
+}
+
+// queueNameFromURL returns the short queue name, the last part of its URL
+func queueNameFromURL(queueURL string) string {
+	return queueURL[strings.LastIndex(queueURL, "/")+1:]
 }
 
-func (s *SQSAdapter) DeleteMany(ctx context.Context, encodedQueueName string, messageIDs []string) []error {
-	result := make([]error, 1)
-	result[1] = errors.New("Not implemented")
-	return result
+// getClient returns an SQS client on the adapter's AWS session
+func (s *SQSAdapter) getClient() (*sqs.SQS, error) {
+	if s.awsSession == nil {
+		return nil, unavailable(errors.New("SQS adapter has no AWS session"))
+	}
+	return sqs.New(s.awsSession), nil
+}
+
+// sqsError marks the error AWS gave for a request about the queue
+func sqsError(queueName string, err error) error {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return err
+	}
+	switch awsErr.Code() {
+	case sqs.ErrCodeQueueDoesNotExist:
+		if queueName != "" {
+			return queueNotFound(queueName)
+		}
+	case "AccessDenied", "AccessDeniedException", "InvalidClientTokenId", "SignatureDoesNotMatch",
+		"UnrecognizedClientException", "ExpiredToken", "MissingAuthenticationToken":
+		return unauthorized(err)
+	case request.ErrCodeRequestError, request.ErrCodeResponseTimeout:
+		return unavailable(err)
+	}
+	return err
+}
+
+// receiveMessagesByID receives from the queue until every requested message ID has been seen, the queue has
+// nothing left to hand out or sqsMaxSearchSeconds have passed, as after the visibility timeout the queue starts
+// handing out the messages already received again.  Matching messages are returned keyed by message ID, along with
+// their receipt handles; everything else that was received along the way is returned so it can be made visible
+// again.  The errors say which messages weren't found.
+func (s *SQSAdapter) receiveMessagesByID(ctx context.Context, svc *sqs.SQS, queueURL string,
+	messageIDs []string) (map[string]*sqs.Message, []*sqs.Message, []error) {
+
+	var findErrors []error
+
+	wanted := make(map[string]bool)
+	for _, messageID := range messageIDs {
+		wanted[messageID] = true
+	}
+
+	found := make(map[string]*sqs.Message)
+	var others []*sqs.Message
+
+	deadline := time.Now().Add(sqsMaxSearchSeconds * time.Second)
+	searched := true
+
+	for len(found) < len(wanted) {
+		if time.Now().After(deadline) {
+			searched = false
+			break
+		}
+
+		receiveMessageOutput, err := svc.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			AttributeNames: []*string{
+				aws.String(sqs.QueueAttributeNameAll),
+			},
+			MessageAttributeNames: []*string{
+				aws.String(sqs.QueueAttributeNameAll),
+			},
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: aws.Int64(sqsMaxBatchSize),
+			WaitTimeSeconds:     aws.Int64(1),
+			VisibilityTimeout:   aws.Int64(sqsOperationVisibilityTimeout),
+		})
+		if err != nil {
+			for _, message := range found {
+				others = append(others, message)
+			}
+			s.releaseMessages(ctx, svc, queueURL, others)
+			return nil, nil, append(findErrors, err)
+		}
+
+		if receiveMessageOutput == nil || len(receiveMessageOutput.Messages) == 0 {
+			break
+		}
+
+		for _, message := range receiveMessageOutput.Messages {
+			messageID := aws.StringValue(message.MessageId)
+			if !wanted[messageID] {
+				others = append(others, message)
+				continue
+			}
+			if previous, ok := found[messageID]; ok {
+				// received twice; only the latest receipt handle is good for a delete
+				others = append(others, previous)
+			}
+			found[messageID] = message
+		}
+	}
+
+	for _, messageID := range messageIDs {
+		if _, ok := found[messageID]; ok {
+			continue
+		}
+		if searched {
+			findErrors = append(findErrors, messageNotFound(messageID))
+			continue
+		}
+		findErrors = append(findErrors, forMessage(messageID, fmt.Errorf(
+			"message %s was not found in the %d seconds spent searching %s, the queue may hold too many messages to search",
+			messageID, sqsMaxSearchSeconds, queueNameFromURL(queueURL))))
+	}
+	return found, others, findErrors
+}
+
+// sendMessages copies the given messages onto the destination queue in batches.  The messages that the
+// destination accepted are returned so the caller knows which ones are safe to remove from the source.
+func (s *SQSAdapter) sendMessages(ctx context.Context, svc *sqs.SQS, queueURL string, messages []*sqs.Message) ([]*sqs.Message, []error) {
+	var sent []*sqs.Message
+	var sendErrors []error
+
+	for start := 0; start < len(messages); start += sqsMaxBatchSize {
+		batch := messages[start:minInt(start+sqsMaxBatchSize, len(messages))]
+
+		entries := make([]*sqs.SendMessageBatchRequestEntry, 0, len(batch))
+		byEntryID := make(map[string]*sqs.Message)
+		for i, message := range batch {
+			entryID := strconv.Itoa(i)
+			byEntryID[entryID] = message
+			entry := &sqs.SendMessageBatchRequestEntry{
+				Id:                aws.String(entryID),
+				MessageBody:       message.Body,
+				MessageAttributes: message.MessageAttributes,
+			}
+			if isFIFOQueue(queueURL) {
+				entry.MessageGroupId, entry.MessageDeduplicationId = s.fifoIDs(message)
+			}
+			entries = append(entries, entry)
+		}
+
+		output, err := svc.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			err = fmt.Errorf("unable to send messages to %s: %s", queueURL, err)
+			for _, message := range batch {
+				sendErrors = append(sendErrors, forMessage(aws.StringValue(message.MessageId), err))
+			}
+			continue
+		}
+
+		for _, success := range output.Successful {
+			sent = append(sent, byEntryID[aws.StringValue(success.Id)])
+		}
+		for _, failure := range output.Failed {
+			message := byEntryID[aws.StringValue(failure.Id)]
+			sendErrors = append(sendErrors, forMessage(aws.StringValue(message.MessageId), fmt.Errorf("unable to send message %s to %s: %s",
+				aws.StringValue(message.MessageId), queueURL, aws.StringValue(failure.Message))))
+		}
+	}
+
+	return sent, sendErrors
+}
+
+// fifoIDs works out the message group and deduplication IDs a message is sent to a FIFO queue with.  Messages
+// keep the IDs they already have, so their order and deduplication carry over.  Messages from a standard queue
+// go into the configured default group and are deduplicated on their message ID.  When regenerating is switched
+// on every send gets a fresh deduplication ID, which is needed to send a message back to a queue that saw the
+// same ID within the last five minutes (SQS would silently drop it).
+func (s *SQSAdapter) fifoIDs(message *sqs.Message) (*string, *string) {
+	groupID := aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameMessageGroupId])
+	if groupID == "" {
+		groupID = s.defaultMessageGroupID
+	}
+
+	deduplicationID := aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameMessageDeduplicationId])
+	if deduplicationID == "" {
+		deduplicationID = aws.StringValue(message.MessageId)
+	}
+	if s.regenerateDeduplicationIDs {
+		deduplicationID = uuid.New().String()
+	}
+
+	return aws.String(groupID), aws.String(deduplicationID)
+}
+
+// isFIFOQueue tells whether the queue is a FIFO queue; SQS requires their names to end in .fifo
+func isFIFOQueue(queueURL string) bool {
+	return strings.HasSuffix(queueURL, ".fifo")
+}
+
+// sortBySequenceNumber puts FIFO messages back in the order they were sent.  Sequence numbers are up to 128 bit
+// integers, so they are compared as digit strings.
+func sortBySequenceNumber(messages []*sqs.Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		a := aws.StringValue(messages[i].Attributes[sqs.MessageSystemAttributeNameSequenceNumber])
+		b := aws.StringValue(messages[j].Attributes[sqs.MessageSystemAttributeNameSequenceNumber])
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+}
+
+// deleteMessages removes the given messages from the queue in batches using the receipt handles from their
+// most recent receive.
+func (s *SQSAdapter) deleteMessages(ctx context.Context, svc *sqs.SQS, queueURL string, messages []*sqs.Message) []error {
+	var deleteErrors []error
+
+	for start := 0; start < len(messages); start += sqsMaxBatchSize {
+		batch := messages[start:minInt(start+sqsMaxBatchSize, len(messages))]
+
+		entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, len(batch))
+		byEntryID := make(map[string]*sqs.Message)
+		for i, message := range batch {
+			entryID := strconv.Itoa(i)
+			byEntryID[entryID] = message
+			entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(entryID),
+				ReceiptHandle: message.ReceiptHandle,
+			})
+		}
+
+		output, err := svc.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+			QueueUrl: aws.String(queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			err = fmt.Errorf("unable to delete messages from %s: %s", queueURL, err)
+			for _, message := range batch {
+				deleteErrors = append(deleteErrors, forMessage(aws.StringValue(message.MessageId), err))
+			}
+			continue
+		}
+
+		for _, failure := range output.Failed {
+			message := byEntryID[aws.StringValue(failure.Id)]
+			deleteErrors = append(deleteErrors, forMessage(aws.StringValue(message.MessageId), fmt.Errorf("unable to delete message %s from %s: %s",
+				aws.StringValue(message.MessageId), queueURL, aws.StringValue(failure.Message))))
+		}
+	}
+
+	return deleteErrors
+}
+
+// releaseMessages makes received messages visible again straight away rather than waiting out their
+// visibility timeout.
+func (s *SQSAdapter) releaseMessages(ctx context.Context, svc *sqs.SQS, queueURL string, messages []*sqs.Message) {
+	for start := 0; start < len(messages); start += sqsMaxBatchSize {
+		batch := messages[start:minInt(start+sqsMaxBatchSize, len(messages))]
+
+		entries := make([]*sqs.ChangeMessageVisibilityBatchRequestEntry, 0, len(batch))
+		for i, message := range batch {
+			entries = append(entries, &sqs.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     message.ReceiptHandle,
+				VisibilityTimeout: aws.Int64(0),
+			})
+		}
+
+		_, err := svc.ChangeMessageVisibilityBatchWithContext(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			log.Printf("unable to release messages on %s, they will reappear after the visibility timeout: %s", queueURL, err)
+		}
+	}
+}
+
+func containsMessage(messages []*sqs.Message, message *sqs.Message) bool {
+	for _, m := range messages {
+		if m == message {
+			return true
+		}
+	}
+	return false
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
 }
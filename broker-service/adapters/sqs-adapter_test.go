@@ -0,0 +1,508 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/google/uuid"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/configuration"
+)
+
+// These tests run against an ElasticMQ (or any other SQS compatible) endpoint, e.g.
+//
+//	docker run -p 9324:9324 softwaremill/elasticmq
+//	SQS_TEST_ENDPOINT=http://localhost:9324 go test ./adapters -run SQS
+func newTestSQSAdapter(t *testing.T) *SQSAdapter {
+	endpoint := os.Getenv("SQS_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("SQS_TEST_ENDPOINT not set")
+	}
+
+	s := NewSQSAdapter(configuration.BrokerConfiguration{
+		Name: "sqs-test",
+		Type: "sqs",
+		All: map[string]string{
+			"REGION":     "elasticmq",
+			"ENDPOINT":   endpoint,
+			"ACCESS_KEY": "x",
+			"SECRET_KEY": "x",
+		},
+	})
+	if s == nil {
+		t.Fatal("No adapter.")
+	}
+
+	return s
+}
+
+func createTestSQSQueue(t *testing.T, s *SQSAdapter, name string) string {
+	svc := sqs.New(s.awsSession)
+	input := &sqs.CreateQueueInput{QueueName: aws.String(name)}
+	if isFIFOQueue(name) {
+		input.Attributes = map[string]*string{sqs.QueueAttributeNameFifoQueue: aws.String("true")}
+	}
+	output, err := svc.CreateQueue(input)
+	if err != nil {
+		t.Fatalf("Unable to create queue %s. %s", name, err)
+	}
+	t.Cleanup(func() {
+		_, _ = svc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: output.QueueUrl})
+	})
+	return *output.QueueUrl
+}
+
+func sendTestSQSMessages(t *testing.T, s *SQSAdapter, queueURL string, count int) []string {
+	svc := sqs.New(s.awsSession)
+	messageIDs := make([]string, 0)
+	for i := 0; i < count; i++ {
+		output, err := svc.SendMessage(&sqs.SendMessageInput{
+			QueueUrl:    aws.String(queueURL),
+			MessageBody: aws.String(fmt.Sprintf("string %d", i)),
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{
+				"test": {DataType: aws.String("String"), StringValue: aws.String("value")},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Unable to send message. %s", err)
+		}
+		messageIDs = append(messageIDs, *output.MessageId)
+	}
+	return messageIDs
+}
+
+func countTestSQSMessages(t *testing.T, s *SQSAdapter, queueURL string) int {
+	svc := sqs.New(s.awsSession)
+	output, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl: aws.String(queueURL),
+		AttributeNames: []*string{
+			aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages),
+			aws.String(sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unable to get queue attributes. %s", err)
+	}
+	var visible, notVisible int
+	fmt.Sscan(aws.StringValue(output.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]), &visible)
+	fmt.Sscan(aws.StringValue(output.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible]), &notVisible)
+	return visible + notVisible
+}
+
+func TestSQSAdapter_GetAllMessages(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	queueURL := createTestSQSQueue(t, s, "tobrowse-"+uuid.New().String()[:8])
+
+	messageIDs := sendTestSQSMessages(t, s, queueURL, 35)
+
+	messages, err := s.GetAllMessages(context.Background(), queueURL)
+	if err != nil {
+		t.Fatalf("GetAllMessages returned error: %s", err)
+	}
+
+	messageMap := make(map[string]bool)
+	for _, message := range messages {
+		if time.Since(message.Timestamp) > time.Minute || time.Until(message.Timestamp) > time.Minute {
+			t.Errorf("Message %s has timestamp %s", message.MessageID, message.Timestamp)
+		}
+		if messageMap[message.MessageID] {
+			t.Errorf("Message %s returned more than once", message.MessageID)
+		}
+		messageMap[message.MessageID] = true
+	}
+	for _, messageID := range messageIDs {
+		if !messageMap[messageID] {
+			t.Errorf("Message %s not found", messageID)
+		}
+	}
+
+	// a browse must leave the messages available for a move or delete straight afterwards
+	if err := resultError(s.DeleteMany(context.Background(), queueURL, messageIDs)); err != nil {
+		t.Errorf("DeleteMany after browse returned error: %v", err)
+	}
+
+	t.Run("stops at the cap", func(t *testing.T) {
+		sendTestSQSMessages(t, s, queueURL, 25)
+		s.maxBrowseMessages = 20
+		defer func() { s.maxBrowseMessages = sqsDefaultMaxBrowseMessages }()
+
+		messages, err := s.GetAllMessages(context.Background(), queueURL)
+		if err != nil {
+			t.Fatalf("GetAllMessages returned error: %s", err)
+		}
+		if len(messages) != 20 {
+			t.Errorf("expected 20 messages, got %d", len(messages))
+		}
+
+		// receives still in flight at the cap leave nothing hidden
+		svc := sqs.New(s.awsSession)
+		output, err := svc.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+			QueueUrl:       aws.String(queueURL),
+			AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible)},
+		})
+		if err != nil {
+			t.Fatalf("Unable to get queue attributes. %s", err)
+		}
+		if notVisible := aws.StringValue(output.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessagesNotVisible]); notVisible != "0" {
+			t.Errorf("expected no hidden messages after browsing, got %s", notVisible)
+		}
+	})
+}
+
+func TestSQSAdapter_GetAllQueues(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	suffix := uuid.New().String()[:8]
+	deadLetterQueue := createTestSQSQueue(t, s, "deadletter-"+suffix)
+
+	info, err := s.getQueueInfo(context.Background(), sqs.New(s.awsSession), deadLetterQueue)
+	if err != nil {
+		t.Fatalf("Unable to get queue info. %s", err)
+	}
+	redrivePolicy := fmt.Sprintf(`{"deadLetterTargetArn":"%s","maxReceiveCount":"3"}`, info["Arn"])
+
+	svc := sqs.New(s.awsSession)
+	output, err := svc.CreateQueue(&sqs.CreateQueueInput{
+		QueueName:  aws.String("source-" + suffix),
+		Attributes: map[string]*string{sqs.QueueAttributeNameRedrivePolicy: aws.String(redrivePolicy)},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create queue. %s", err)
+	}
+	sourceQueue := *output.QueueUrl
+	t.Cleanup(func() {
+		_, _ = svc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: output.QueueUrl})
+	})
+
+	sendTestSQSMessages(t, s, sourceQueue, 3)
+
+	queues, err := s.GetAllQueues(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllQueues returned error: %s", err)
+	}
+
+	var found bool
+	for _, queue := range queues {
+		if queue.Info["URL"] != sourceQueue {
+			continue
+		}
+		found = true
+		if queue.Name != "source-"+suffix {
+			t.Errorf("expected name source-%s, got %q", suffix, queue.Name)
+		}
+		if queue.Info["Size"] != "3" {
+			t.Errorf("expected Size 3, got %q", queue.Info["Size"])
+		}
+		if queue.Info["DeadLetterTargetArn"] != info["Arn"] {
+			t.Errorf("expected DeadLetterTargetArn %q, got %q", info["Arn"], queue.Info["DeadLetterTargetArn"])
+		}
+		if queue.Info["MaxReceiveCount"] != "3" {
+			t.Errorf("expected MaxReceiveCount 3, got %q", queue.Info["MaxReceiveCount"])
+		}
+	}
+	for _, queue := range queues {
+		if queue.Info["URL"] == deadLetterQueue && queue.Info["SourceQueues"] != "source-"+suffix {
+			t.Errorf("expected SourceQueues source-%s, got %q", suffix, queue.Info["SourceQueues"])
+		}
+	}
+	if !found {
+		t.Errorf("Queue %s not found", sourceQueue)
+	}
+}
+
+func TestSQSAdapter_GetAllQueuesPaged(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	suffix := uuid.New().String()[:8]
+	s.queuePrefix = "paged-" + suffix
+
+	for i := 0; i < 1005; i++ {
+		createTestSQSQueue(t, s, fmt.Sprintf("paged-%s-%04d", suffix, i))
+	}
+	createTestSQSQueue(t, s, "unpaged-"+suffix)
+
+	queues, err := s.GetAllQueues(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllQueues returned error: %s", err)
+	}
+	if len(queues) != 1005 {
+		t.Errorf("expected 1005 queues, got %d", len(queues))
+	}
+
+	// queues can be addressed by their short name
+	messageIDs := sendTestSQSMessages(t, s, queues[0].Info["URL"], 1)
+	messages, err := s.GetAllMessages(context.Background(), queues[0].Name)
+	if err != nil {
+		t.Fatalf("GetAllMessages returned error: %s", err)
+	}
+	if len(messages) != 1 || messages[0].MessageID != messageIDs[0] {
+		t.Errorf("expected message %s, got %+v", messageIDs[0], messages)
+	}
+}
+
+func TestSQSAdapter_Redrive(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	suffix := uuid.New().String()[:8]
+	deadLetterQueue := createTestSQSQueue(t, s, "redrivefrom-"+suffix)
+
+	info, err := s.getQueueInfo(context.Background(), sqs.New(s.awsSession), deadLetterQueue)
+	if err != nil {
+		t.Fatalf("Unable to get queue info. %s", err)
+	}
+
+	svc := sqs.New(s.awsSession)
+	output, err := svc.CreateQueue(&sqs.CreateQueueInput{
+		QueueName: aws.String("redriveto-" + suffix),
+		Attributes: map[string]*string{sqs.QueueAttributeNameRedrivePolicy: aws.String(
+			fmt.Sprintf(`{"deadLetterTargetArn":"%s","maxReceiveCount":3}`, info["Arn"]))},
+	})
+	if err != nil {
+		t.Fatalf("Unable to create queue. %s", err)
+	}
+	sourceQueue := *output.QueueUrl
+	t.Cleanup(func() {
+		_, _ = svc.DeleteQueue(&sqs.DeleteQueueInput{QueueUrl: output.QueueUrl})
+	})
+
+	messageIDs := sendTestSQSMessages(t, s, deadLetterQueue, 12)
+
+	if err := resultError(s.Redrive(context.Background(), deadLetterQueue, messageIDs[:2])); err != nil {
+		t.Fatalf("Redrive returned error: %v", err)
+	}
+	if got := countTestSQSMessages(t, s, sourceQueue); got != 2 {
+		t.Errorf("expected 2 messages in source queue, got %d", got)
+	}
+
+	results := s.Redrive(context.Background(), deadLetterQueue, nil)
+	if err := resultError(results); err != nil || len(results) != 10 {
+		t.Fatalf("Redrive of the whole queue = %+v, want the other 10 messages redriven", results)
+	}
+	if got := countTestSQSMessages(t, s, sourceQueue); got != 12 {
+		t.Errorf("expected 12 messages in source queue, got %d", got)
+	}
+	if got := countTestSQSMessages(t, s, deadLetterQueue); got != 0 {
+		t.Errorf("expected an empty dead-letter queue, got %d", got)
+	}
+}
+
+func TestSQSAdapter_ReceiveAllMessagesDeadline(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	queueURL := createTestSQSQueue(t, s, "receivedeadline-"+uuid.New().String()[:8])
+	sendTestSQSMessages(t, s, queueURL, 3)
+	svc := sqs.New(s.awsSession)
+
+	received, drained, err := s.receiveAllMessages(context.Background(), svc, queueURL, 1, 0, time.Now().Add(-time.Second))
+	if err != nil || len(received) != 0 || drained {
+		t.Errorf("receiveAllMessages() past its deadline = %d messages, drained %v, %v, want none and not drained", len(received), drained, err)
+	}
+
+	received, drained, err = s.receiveAllMessages(context.Background(), svc, queueURL, 1, 0, time.Now().Add(time.Minute))
+	if err != nil || len(received) != 3 || !drained {
+		t.Errorf("receiveAllMessages() = %d messages, drained %v, %v, want 3 and drained", len(received), drained, err)
+	}
+}
+
+func TestNewSQSAdapter(t *testing.T) {
+	os.Setenv("AWS_ACCESS_KEY_ID", "from-environment")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	err := ioutil.WriteFile(credentialsFile,
+		[]byte("[other]\naws_access_key_id = from-profile\naws_secret_access_key = secret\n"), 0600)
+	if err != nil {
+		t.Fatalf("Unable to write credentials file. %s", err)
+	}
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	defer os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	tests := []struct {
+		name          string
+		config        map[string]string
+		wantAccessKey string
+	}{
+		{"static keys", map[string]string{"ACCESS_KEY": "static", "SECRET_KEY": "secret"}, "static"},
+		{"profile", map[string]string{"PROFILE": "other"}, "from-profile"},
+		{"default chain", map[string]string{}, "from-environment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config["REGION"] = "us-east-1"
+			tt.config["ENDPOINT"] = "http://localhost:9324"
+			s := NewSQSAdapter(configuration.BrokerConfiguration{All: tt.config})
+			if s == nil {
+				t.Fatal("No adapter.")
+			}
+
+			if got := aws.StringValue(s.awsSession.Config.Endpoint); got != "http://localhost:9324" {
+				t.Errorf("expected endpoint http://localhost:9324, got %s", got)
+			}
+
+			value, err := s.awsSession.Config.Credentials.Get()
+			if err != nil {
+				t.Fatalf("Unable to get credentials. %s", err)
+			}
+			if value.AccessKeyID != tt.wantAccessKey {
+				t.Errorf("expected access key %s, got %s", tt.wantAccessKey, value.AccessKeyID)
+			}
+		})
+	}
+}
+
+func TestParseEpochMillis(t *testing.T) {
+	got, ok := parseEpochMillis("1588000000123")
+	if !ok {
+		t.Fatal("expected the timestamp to parse")
+	}
+	want := time.Date(2020, time.April, 27, 15, 6, 40, 123000000, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	if _, ok := parseEpochMillis(""); ok {
+		t.Error("expected an empty timestamp not to parse")
+	}
+}
+
+func TestSQSAdapter_Move(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	suffix := uuid.New().String()[:8]
+	fromQueue := createTestSQSQueue(t, s, "fromhere-"+suffix)
+	toQueue := createTestSQSQueue(t, s, "movetohere-"+suffix)
+
+	messageIDs := sendTestSQSMessages(t, s, fromQueue, 15)
+
+	if err := resultError(s.Move(context.Background(), fromQueue, toQueue, messageIDs[:12])); err != nil {
+		t.Fatalf("Move returned error: %v", err)
+	}
+
+	if got := countTestSQSMessages(t, s, toQueue); got != 12 {
+		t.Errorf("expected 12 messages in destination, got %d", got)
+	}
+	if got := countTestSQSMessages(t, s, fromQueue); got != 3 {
+		t.Errorf("expected 3 messages left in source, got %d", got)
+	}
+
+	err := s.MoveOne(context.Background(), fromQueue, toQueue, "does-not-exist")
+	if err == nil {
+		t.Error("expected an error moving a message that does not exist")
+	}
+}
+
+func TestSQSAdapter_MoveFIFO(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	suffix := uuid.New().String()[:8]
+	fromQueue := createTestSQSQueue(t, s, "fromhere-"+suffix+".fifo")
+	toQueue := createTestSQSQueue(t, s, "movetohere-"+suffix+".fifo")
+	standardQueue := createTestSQSQueue(t, s, "standard-"+suffix)
+
+	svc := sqs.New(s.awsSession)
+	messageIDs := make([]string, 0)
+	for i := 0; i < 3; i++ {
+		output, err := svc.SendMessage(&sqs.SendMessageInput{
+			QueueUrl:               aws.String(fromQueue),
+			MessageBody:            aws.String(fmt.Sprintf("string %d", i)),
+			MessageGroupId:         aws.String("group-a"),
+			MessageDeduplicationId: aws.String(fmt.Sprintf("dedup-%d", i)),
+		})
+		if err != nil {
+			t.Fatalf("Unable to send message. %s", err)
+		}
+		messageIDs = append(messageIDs, *output.MessageId)
+	}
+	standardIDs := sendTestSQSMessages(t, s, standardQueue, 1)
+
+	// ask for them out of order, they should still arrive in sequence
+	if err := resultError(s.Move(context.Background(), fromQueue, toQueue, []string{messageIDs[2], messageIDs[0], messageIDs[1]})); err != nil {
+		t.Fatalf("Move returned error: %v", err)
+	}
+	if err := resultError(s.Move(context.Background(), standardQueue, toQueue, standardIDs)); err != nil {
+		t.Fatalf("Move returned error: %v", err)
+	}
+
+	messages, err := s.GetAllMessages(context.Background(), toQueue)
+	if err != nil {
+		t.Fatalf("GetAllMessages returned error: %s", err)
+	}
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(messages))
+	}
+	for i, message := range messages[:3] {
+		if message.Body != fmt.Sprintf("string %d", i) {
+			t.Errorf("expected message %d to be %q, got %q", i, fmt.Sprintf("string %d", i), message.Body)
+		}
+		if message.Headers["MessageGroupId"] != "group-a" {
+			t.Errorf("expected group ID group-a, got %q", message.Headers["MessageGroupId"])
+		}
+		if message.Headers["MessageDeduplicationId"] != fmt.Sprintf("dedup-%d", i) {
+			t.Errorf("expected deduplication ID dedup-%d, got %q", i, message.Headers["MessageDeduplicationId"])
+		}
+	}
+	if messages[3].Headers["MessageGroupId"] != sqsDefaultMessageGroupID {
+		t.Errorf("expected group ID %s, got %q", sqsDefaultMessageGroupID, messages[3].Headers["MessageGroupId"])
+	}
+	if messages[3].Headers["MessageDeduplicationId"] != standardIDs[0] {
+		t.Errorf("expected deduplication ID %s, got %q", standardIDs[0], messages[3].Headers["MessageDeduplicationId"])
+	}
+
+	t.Run("regenerates deduplication IDs", func(t *testing.T) {
+		s.regenerateDeduplicationIDs = true
+		defer func() { s.regenerateDeduplicationIDs = false }()
+
+		if err := resultError(s.Move(context.Background(), toQueue, fromQueue, []string{messages[0].MessageID})); err != nil {
+			t.Fatalf("Move returned error: %v", err)
+		}
+		moved, err := s.GetAllMessages(context.Background(), fromQueue)
+		if err != nil {
+			t.Fatalf("GetAllMessages returned error: %s", err)
+		}
+		if len(moved) != 1 {
+			t.Fatalf("expected 1 message, got %d", len(moved))
+		}
+		if moved[0].Headers["MessageGroupId"] != "group-a" {
+			t.Errorf("expected group ID group-a, got %q", moved[0].Headers["MessageGroupId"])
+		}
+		if moved[0].Headers["MessageDeduplicationId"] == "dedup-0" {
+			t.Error("expected a new deduplication ID")
+		}
+	})
+}
+
+func TestSQSAdapter_DeleteMany(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	queueURL := createTestSQSQueue(t, s, "todeletefrom-"+uuid.New().String()[:8])
+
+	messageIDs := sendTestSQSMessages(t, s, queueURL, 5)
+
+	if err := resultError(s.DeleteMany(context.Background(), queueURL, messageIDs[:3])); err != nil {
+		t.Fatalf("DeleteMany returned error: %v", err)
+	}
+	if err := s.DeleteOne(context.Background(), queueURL, messageIDs[3]); err != nil {
+		t.Fatalf("DeleteOne returned error: %s", err)
+	}
+
+	if got := countTestSQSMessages(t, s, queueURL); got != 1 {
+		t.Errorf("expected 1 message left, got %d", got)
+	}
+}
+
+func TestSQSAdapter_Purge(t *testing.T) {
+	s := newTestSQSAdapter(t)
+	queueURL := createTestSQSQueue(t, s, "topurgefrom-"+uuid.New().String()[:8])
+
+	sendTestSQSMessages(t, s, queueURL, 5)
+
+	if err := s.Purge(context.Background(), queueURL); err != nil {
+		t.Fatalf("Purge returned error: %s", err)
+	}
+
+	if got := countTestSQSMessages(t, s, queueURL); got != 0 {
+		t.Errorf("expected an empty queue, got %d", got)
+	}
+}
@@ -4,11 +4,19 @@ go 1.14
 
 require (
 	github.com/Azure/go-amqp v0.12.7
-	github.com/aws/aws-sdk-go v1.30.2
+	github.com/alicebob/miniredis/v2 v2.30.0
+	github.com/apache/pulsar-client-go v0.10.0
+	github.com/aws/aws-sdk-go v1.35.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible // indirect
-	github.com/google/uuid v1.1.1
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/google/uuid v1.1.2
 	github.com/labstack/echo v3.3.10+incompatible
 	github.com/labstack/gommon v0.3.0 // indirect
+	github.com/nats-io/nats-server/v2 v2.9.25
+	github.com/nats-io/nats.go v1.28.0
 	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
-	golang.org/x/crypto v0.0.0-20200323165209-0ec3e9974c59 // indirect
+	github.com/twmb/franz-go v1.15.4
+	github.com/twmb/franz-go/pkg/kadm v1.11.0
+	github.com/twmb/franz-go/pkg/kfake v0.0.0-20240412162337-6a58760afaa7
+	github.com/twmb/franz-go/pkg/kmsg v1.7.0
 )
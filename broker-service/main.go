@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/middleware"
 
@@ -15,6 +17,9 @@ import (
 	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/service"
 )
 
+// journalReplayRetryDelay is how long to wait before replaying a journal again when its broker can't be reached
+const journalReplayRetryDelay = 30 * time.Second
+
 func main() {
 
 	var configMgr configuration.ConfigurationManager
@@ -22,7 +27,6 @@ func main() {
 	configs := configMgr.GetAdapterConfigurations(context.Background())
 
 	mapBrokerNameToAdapter := buildAdapters(configs)
-	mapBrokerNameToAdapter["test"] = &adapters.MockAdapter{}
 
 	brokerAdapterManager := service.BrokerAdapterManager{
 		MapBrokerNameToAdapter: mapBrokerNameToAdapter,
@@ -48,10 +52,22 @@ func buildAdapters(configs []configuration.BrokerConfiguration) map[string]adapt
 	for _, config := range configs {
 		switch config.Type {
 		case "amq":
+			if config.All["MANAGEMENT"] == "jolokia" {
+				amq := getActiveMQJolokiaAdapter(config)
+				if amq != nil {
+					newAdapters[config.Name] = amq
+				}
+				continue
+			}
 			amq := getActiveMQAdapter(config)
 			if amq != nil {
 				newAdapters[config.Name] = amq
 			}
+		case "amqp":
+			amqpAdapter := getAMQPAdapter(config)
+			if amqpAdapter != nil {
+				newAdapters[config.Name] = amqpAdapter
+			}
 		case "rabbitmq":
 			rabbit := getRabbitMQAdapter(config)
 			if rabbit != nil {
@@ -62,6 +78,31 @@ func buildAdapters(configs []configuration.BrokerConfiguration) map[string]adapt
 			if sqsAdapter != nil {
 				newAdapters[config.Name] = sqsAdapter
 			}
+		case "pulsar":
+			pulsarAdapter := getPulsarAdapter(config)
+			if pulsarAdapter != nil {
+				newAdapters[config.Name] = pulsarAdapter
+			}
+		case "kafka":
+			kafkaAdapter := getKafkaAdapter(config)
+			if kafkaAdapter != nil {
+				newAdapters[config.Name] = kafkaAdapter
+			}
+		case "jetstream":
+			jetStreamAdapter := getJetStreamAdapter(config)
+			if jetStreamAdapter != nil {
+				newAdapters[config.Name] = jetStreamAdapter
+			}
+		case "memory":
+			memoryAdapter := getMemoryAdapter(config)
+			if memoryAdapter != nil {
+				newAdapters[config.Name] = memoryAdapter
+			}
+		case "redis":
+			redisAdapter := getRedisAdapter(config)
+			if redisAdapter != nil {
+				newAdapters[config.Name] = redisAdapter
+			}
 		default:
 			fmt.Printf("Broker type not supported: %s", config.Type)
 			continue
@@ -90,6 +131,60 @@ func getActiveMQAdapter(config configuration.BrokerConfiguration) *adapters.Acti
 	return adapter
 }
 
+func getActiveMQJolokiaAdapter(config configuration.BrokerConfiguration) *adapters.ActiveMQJolokiaAdapter {
+
+	consoleUrl := config.All["CONSOLE_URL"]
+	consolePass := config.All["CONSOLE_PASS"]
+	consoleUser := config.All["CONSOLE_USER"]
+
+	adapter, err := adapters.NewActiveMQJolokiaAdapter(context.Background(), consoleUrl, consoleUser, consolePass)
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+
+	return adapter
+}
+
+func getAMQPAdapter(config configuration.BrokerConfiguration) *adapters.AMQPAdapter {
+
+	useTls := strings.Contains(config.URL, "amqps:")
+
+	consoleUrl := config.All["CONSOLE_URL"]
+	consolePass := config.All["CONSOLE_PASS"]
+	consoleUser := config.All["CONSOLE_USER"]
+
+	var discoverer adapters.QueueDiscoverer
+	switch config.All["QUEUE_DISCOVERY"] {
+	case "activemq":
+		discoverer = adapters.NewActiveMQConsoleDiscoverer(consoleUrl, consoleUser, consolePass)
+	case "activemq-jolokia":
+		jolokiaAdapter, err := adapters.NewActiveMQJolokiaAdapter(context.Background(), consoleUrl, consoleUser, consolePass)
+		if err != nil {
+			log.Printf("!!Adapter Error!! - %s", err)
+			return nil
+		}
+		discoverer = jolokiaAdapter
+	case "artemis":
+		discoverer = adapters.NewArtemisQueueDiscoverer(consoleUrl, consoleUser, consolePass)
+	case "static", "":
+		discoverer = adapters.NewStaticQueueDiscoverer(config.All["QUEUES"])
+	default:
+		log.Printf("!!Adapter Error!! - queue discovery not supported: %s", config.All["QUEUE_DISCOVERY"])
+		return nil
+	}
+
+	adapter, err := adapters.NewAMQPAdapter(context.Background(), config.URL, config.User, config.Pass, useTls, discoverer)
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+	// brokers without JMS selectors, such as the Qpid Dispatch router, need them turned off
+	adapter.UseSelectors(config.All["SELECTORS"] != "false")
+
+	return adapter
+}
+
 func getRabbitMQAdapter(config configuration.BrokerConfiguration) *adapters.RabbitMQAdapter {
 
 	adapter, err := adapters.NewRabbitMQAdapter(context.Background(), config.URL, config.All["CONSOLE_URL"], config.User, config.Pass, config.All["HOST"])
@@ -100,9 +195,35 @@ func getRabbitMQAdapter(config configuration.BrokerConfiguration) *adapters.Rabb
 
 	log.Println("connected to Rabbit Adapter")
 
+	if journalFile := config.All["JOURNAL_FILE"]; journalFile != "" {
+		journal, err := adapters.OpenMoveJournal(journalFile)
+		if err != nil {
+			log.Printf("!!Adapter Error!! - %s", err)
+			return nil
+		}
+		adapter.UseJournal(journal)
+		go replayJournal(config.Name, adapter)
+	}
+
 	return adapter
 }
 
+// replayJournal finishes the moves a crash interrupted, trying again while the broker can't be reached
+func replayJournal(name string, journaler adapters.Journaler) {
+	for {
+		errs := journaler.ReplayJournal(context.Background())
+		unavailable := false
+		for _, err := range errs {
+			log.Printf("!!Journal Error!! - %s: %s", name, err)
+			unavailable = unavailable || errors.Is(err, adapters.ErrBrokerUnavailable)
+		}
+		if !unavailable {
+			return
+		}
+		time.Sleep(journalReplayRetryDelay)
+	}
+}
+
 func getSQSAdapter(config configuration.BrokerConfiguration) *adapters.SQSAdapter {
 	adapter := adapters.NewSQSAdapter(config)
 	if adapter == nil {
@@ -111,11 +232,73 @@ func getSQSAdapter(config configuration.BrokerConfiguration) *adapters.SQSAdapte
 	return adapter
 }
 
+func getPulsarAdapter(config configuration.BrokerConfiguration) *adapters.PulsarAdapter {
+
+	adapter, err := adapters.NewPulsarAdapter(context.Background(), config.URL, config.All["CONSOLE_URL"], config.All["TOKEN"], config.All["NAMESPACES"], config.All["SUBSCRIPTION"])
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+
+	return adapter
+}
+
+func getKafkaAdapter(config configuration.BrokerConfiguration) *adapters.KafkaAdapter {
+
+	useTls := strings.EqualFold(config.All["TLS"], "true")
+
+	adapter, err := adapters.NewKafkaAdapter(context.Background(), config.URL, config.User, config.Pass, config.All["SASL_MECHANISM"], useTls,
+		config.All["GROUP_ID"], config.All["BROWSE_FROM"], config.All["BROWSE_TO"])
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+
+	return adapter
+}
+
+func getJetStreamAdapter(config configuration.BrokerConfiguration) *adapters.JetStreamAdapter {
+
+	adapter, err := adapters.NewJetStreamAdapter(context.Background(), config.URL, config.User, config.Pass, config.All["TOKEN"], config.All["CREDENTIALS_FILE"])
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+
+	return adapter
+}
+
+func getRedisAdapter(config configuration.BrokerConfiguration) *adapters.RedisAdapter {
+
+	adapter, err := adapters.NewRedisAdapter(context.Background(), config.URL, config.User, config.Pass, config.All["KEY_PATTERN"], config.All["BODY_FIELD"])
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+
+	return adapter
+}
+
+func getMemoryAdapter(config configuration.BrokerConfiguration) *adapters.MemoryAdapter {
+
+	adapter, err := adapters.NewMemoryAdapter(context.Background(), config.All["FIXTURE_FILE"])
+	if err != nil {
+		log.Printf("!!Adapter Error!! - %s", err)
+		return nil
+	}
+
+	return adapter
+}
+
 func setupRestEndpoints(e *echo.Echo, brokerAdapterManager service.BrokerAdapterManager) {
 	// Get all brokers
 	e.GET("brokers", brokerAdapterManager.GetAllBrokers)
+	// Get a broker, with what it supports
+	e.GET(fmt.Sprintf("%s/:%s", "brokers", "brokerID"), brokerAdapterManager.GetBroker)
 	// Get all service for a particular queue associated with a broker
 	e.GET(fmt.Sprintf("%s/:%s/%s/:%s/%s", "brokers", "brokerID", "queues", "queueName", "messages"), brokerAdapterManager.GetAllMessages)
+	// Get a single message from a queue, for brokers that can look messages up by ID
+	e.GET(fmt.Sprintf("%s/:%s/%s/:%s/%s/:%s", "brokers", "brokerID", "queues", "queueName", "messages", "messageID"), brokerAdapterManager.GetMessage)
 	// Get all queues from a particular broker
 	e.GET(fmt.Sprintf("%s/:%s/%s", "brokers", "brokerID", "queues"), brokerAdapterManager.GetAllQueues)
 	// Remove all items from a queue from a particular broker
@@ -128,4 +311,10 @@ func setupRestEndpoints(e *echo.Echo, brokerAdapterManager service.BrokerAdapter
 	e.POST(fmt.Sprintf("%s/:%s/%s/:%s/%s/:%s/%s/:%s", "brokers", "brokerID", "queues", "queueName", "toqueue", "toQueueName", "messages", "messageID"), brokerAdapterManager.MoveMessage)
 	//Move a list messages from a queue to another queue
 	e.POST(fmt.Sprintf("%s/:%s/%s/:%s/%s/:%s/%s", "brokers", "brokerID", "queues", "queueName", "toqueue", "toQueueName", "messages"), brokerAdapterManager.MoveMessages)
+	//Copy a list of messages from a queue to another queue, leaving the originals
+	e.POST(fmt.Sprintf("%s/:%s/%s/:%s/%s/:%s/%s", "brokers", "brokerID", "queues", "queueName", "copytoqueue", "toQueueName", "messages"), brokerAdapterManager.CopyMessages)
+	//Move messages from a dead-letter queue back to the queue they came from
+	e.POST(fmt.Sprintf("%s/:%s/%s/:%s/%s", "brokers", "brokerID", "queues", "queueName", "redrive"), brokerAdapterManager.RedriveMessages)
+	// Get the moves a broker's journal holds that aren't finished
+	e.GET(fmt.Sprintf("%s/:%s/%s", "brokers", "brokerID", "journal"), brokerAdapterManager.GetJournal)
 }
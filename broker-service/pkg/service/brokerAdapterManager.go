@@ -3,9 +3,14 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
 
@@ -22,83 +27,297 @@ func (b *BrokerAdapterManager) GetAllMessages(echoContext echo.Context) error {
 	brokerID := echoContext.Param("brokerID")
 
 	if queueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, "no queue name given", "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
 	}
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, "no broker name given", "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, fmt.Sprintf("No connection found for %s", brokerID), "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
 	}
 
-	messages, err := brokerAdapter.GetAllMessages(context.Background(), queueName)
+	var messages []structs.StandardMessage
+	var err error
+
+	// the UI's filter criteria, applied by the broker where it can
+	filter, err := getMessageFilter(echoContext)
+	if err != nil {
+		return respondError(echoContext, http.StatusBadRequest, err.Error())
+	}
+
+	// a limit or cursor asks for a page of the queue, with the cursor of the next page
+	limit := echoContext.QueryParam("limit")
+	cursor := echoContext.QueryParam("cursor")
+	if limit != "" || cursor != "" {
+		if !filter.IsEmpty() {
+			return respondError(echoContext, http.StatusBadRequest, "filtered messages can't be paged")
+		}
+		if !supportsPaging(adapters.GetCapabilities(brokerAdapter)) {
+			return notSupported(echoContext, "Paging", brokerID)
+		}
+		page := adapters.PageRequest{Cursor: cursor}
+		if limit != "" {
+			page.Limit, err = strconv.Atoi(limit)
+			if err != nil || page.Limit <= 0 {
+				return respondError(echoContext, http.StatusBadRequest, fmt.Sprintf("limit %s is not a positive number", limit))
+			}
+		}
+		messagePage, err := brokerAdapter.GetMessages(context.Background(), queueName, page)
+		if err != nil {
+			return respondAdapterError(echoContext, err)
+		}
+		return echoContext.JSONPretty(http.StatusOK, messagePage, "   ")
+	}
+
+	// a from or to position browses a range of the queue, for brokers that keep messages after they are read
+	from := echoContext.QueryParam("from")
+	to := echoContext.QueryParam("to")
+	// each selector=name=value has the broker pick out the messages with that header value
+	selectors := echoContext.QueryParams()["selector"]
+	if len(selectors) > 0 {
+		selectorBrowser, ok := brokerAdapter.(adapters.SelectorBrowser)
+		if !ok || !adapters.GetCapabilities(brokerAdapter).Selectors {
+			return notSupported(echoContext, "Browsing by selector", brokerID)
+		}
+		headers := make(map[string]string)
+		for _, selector := range selectors {
+			equals := strings.Index(selector, "=")
+			if equals <= 0 {
+				return respondError(echoContext, http.StatusBadRequest, fmt.Sprintf("selector %q is not name=value", selector))
+			}
+			headers[selector[:equals]] = selector[equals+1:]
+		}
+		messages, err = selectorBrowser.GetMessagesBySelector(context.Background(), queueName, headers)
+	} else if from != "" || to != "" {
+		rangeBrowser, ok := brokerAdapter.(adapters.RangeBrowser)
+		if !ok {
+			return notSupported(echoContext, "Browsing a range", brokerID)
+		}
+		messages, err = rangeBrowser.GetMessagesInRange(context.Background(), queueName, from, to)
+	} else if filterer, ok := brokerAdapter.(adapters.Filterer); ok && !filter.IsEmpty() {
+		messages, err = filterer.GetFilteredMessages(context.Background(), queueName, filter)
+	} else {
+		messages, err = brokerAdapter.GetAllMessages(context.Background(), queueName)
+	}
+	if err != nil {
+		return respondAdapterError(echoContext, err)
+	}
+
+	err = echoContext.JSONPretty(http.StatusOK, filter.Apply(messages), "   ")
+	return err
+}
+
+// getMessageFilter reads the UI's filter criteria from the query: messageId, correlationId, messageBody, fromDate,
+// toDate and a header=name=value for each header.  Dates are RFC 3339 times or whole days, e.g. 2020-04-01.
+func getMessageFilter(echoContext echo.Context) (adapters.MessageFilter, error) {
+	filter := adapters.MessageFilter{
+		MessageID:     echoContext.QueryParam("messageId"),
+		CorrelationID: echoContext.QueryParam("correlationId"),
+		Body:          echoContext.QueryParam("messageBody"),
+	}
+
+	var err error
+	if fromDate := echoContext.QueryParam("fromDate"); fromDate != "" {
+		if filter.FromDate, err = parseFilterDate(fromDate, false); err != nil {
+			return filter, err
+		}
+	}
+	if toDate := echoContext.QueryParam("toDate"); toDate != "" {
+		if filter.ToDate, err = parseFilterDate(toDate, true); err != nil {
+			return filter, err
+		}
+	}
+
+	for _, header := range echoContext.QueryParams()["header"] {
+		equals := strings.Index(header, "=")
+		if equals <= 0 {
+			return filter, fmt.Errorf("header filter %q is not name=value", header)
+		}
+		if filter.Headers == nil {
+			filter.Headers = make(map[string]string)
+		}
+		filter.Headers[header[:equals]] = header[equals+1:]
+	}
+	return filter, nil
+}
+
+// parseFilterDate reads an RFC 3339 time, or a day in UTC, which as the end of a range includes the whole day
+func parseFilterDate(date string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, date); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %s is not an RFC 3339 time or a day like 2006-01-02", date)
+	}
+	if endOfDay {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+func (b *BrokerAdapterManager) GetMessage(echoContext echo.Context) error {
+	queueName := echoContext.Param("queueName")
+	brokerID := echoContext.Param("brokerID")
+	messageID := echoContext.Param("messageID")
+
+	if queueName == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
+	}
+
+	if brokerID == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
+	}
+
+	if messageID == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no message ID given")
+	}
+
+	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
+	if !ok {
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	messageGetter, ok := brokerAdapter.(adapters.MessageGetter)
+	if !ok || !adapters.GetCapabilities(brokerAdapter).GetMessage {
+		return notSupported(echoContext, "Getting a single message", brokerID)
+	}
+
+	message, err := messageGetter.GetMessage(context.Background(), queueName, messageID)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondAdapterError(echoContext, err)
 	}
 
-	err = echoContext.JSONPretty(http.StatusOK, messages, "   ")
+	err = echoContext.JSONPretty(http.StatusOK, message, "   ")
 	return err
 }
 
 func (b *BrokerAdapterManager) GetAllBrokers(echoContext echo.Context) error {
 	var brokerAdapters []adapters.Broker
 
-	for k, _ := range b.MapBrokerNameToAdapter {
-		brokerAdapters = append(brokerAdapters, adapters.Broker{Name: k})
+	for k, brokerAdapter := range b.MapBrokerNameToAdapter {
+		brokerAdapters = append(brokerAdapters, getBroker(k, brokerAdapter))
 	}
+	sort.Slice(brokerAdapters, func(i, j int) bool { return brokerAdapters[i].Name < brokerAdapters[j].Name })
 
 	if len(brokerAdapters) == 0 {
-		return echoContext.JSONPretty(http.StatusInternalServerError, nil, "  ")
+		return respondError(echoContext, http.StatusServiceUnavailable, "no brokers are connected")
 	}
 
 	err := echoContext.JSONPretty(http.StatusOK, brokerAdapters, "   ")
 	return err
 }
 
+func (b *BrokerAdapterManager) GetBroker(echoContext echo.Context) error {
+	brokerID := echoContext.Param("brokerID")
+
+	if brokerID == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
+	}
+
+	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
+	if !ok {
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	err := echoContext.JSONPretty(http.StatusOK, getBroker(brokerID, brokerAdapter), "   ")
+	return err
+}
+
+// getBroker describes a broker: what it supports and, for brokers with a connection of their own, whether it is up
+func getBroker(name string, brokerAdapter adapters.Adapter) adapters.Broker {
+	broker := adapters.Broker{Name: name, Capabilities: adapters.GetCapabilities(brokerAdapter)}
+	if connectionReporter, ok := brokerAdapter.(adapters.ConnectionReporter); ok {
+		broker.Info = getConnectionInfo(connectionReporter.ConnectionStatus())
+	}
+	return broker
+}
+
+// supportsPaging tells whether the broker's queues can be paged with a limit and cursor
+func supportsPaging(capabilities adapters.Capabilities) bool {
+	for _, paging := range capabilities.Paging {
+		if paging == adapters.PagingOffset || paging == adapters.PagingPosition {
+			return true
+		}
+	}
+	return false
+}
+
+// notSupported answers 501 Not Implemented for an operation the broker doesn't support
+func notSupported(echoContext echo.Context, operation string, brokerID string) error {
+	return respondError(echoContext, http.StatusNotImplemented, fmt.Sprintf("%s is not supported by %s", operation, brokerID))
+}
+
 func (b *BrokerAdapterManager) GetAllQueues(echoContext echo.Context) error {
 	brokerID := echoContext.Param("brokerID")
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
 	}
 
 	queues, err := brokerAdapter.GetAllQueues(context.Background())
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondAdapterError(echoContext, err)
 	}
 
 	err = echoContext.JSONPretty(http.StatusOK, queues, "   ")
 	return err
 }
 
+// GetJournal lists the broker's moves that aren't finished, for brokers whose moves are journaled
+func (b *BrokerAdapterManager) GetJournal(echoContext echo.Context) error {
+	brokerID := echoContext.Param("brokerID")
+
+	if brokerID == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
+	}
+
+	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
+	if !ok {
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	journaler, ok := brokerAdapter.(adapters.Journaler)
+	if !ok || !adapters.GetCapabilities(brokerAdapter).Journal {
+		return notSupported(echoContext, "Journaling moves", brokerID)
+	}
+
+	err := echoContext.JSONPretty(http.StatusOK, journaler.JournalEntries(), "   ")
+	return err
+}
+
 func (b *BrokerAdapterManager) PurgeFromQueue(echoContext echo.Context) error {
 	queueName := echoContext.Param("queueName")
 	brokerID := echoContext.Param("brokerID")
 
 	if queueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
 	}
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, fmt.Sprintf("No connection found for %s", brokerID), "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	if !adapters.GetCapabilities(brokerAdapter).Purge {
+		return notSupported(echoContext, "Purging a queue", brokerID)
 	}
 
 	err := brokerAdapter.Purge(context.Background(), queueName)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondAdapterError(echoContext, err)
 	}
 
 	err = echoContext.JSONPretty(http.StatusOK, nil, "   ")
@@ -112,25 +331,29 @@ func (b *BrokerAdapterManager) DeleteMessageFromQueue(echoContext echo.Context)
 	messageID := echoContext.Param("messageID")
 
 	if queueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
 	}
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	if messageID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no message ID given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, fmt.Sprintf("No connection found for %s", brokerID), "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	if !adapters.GetCapabilities(brokerAdapter).Delete {
+		return notSupported(echoContext, "Deleting messages", brokerID)
 	}
 
 	err := brokerAdapter.DeleteOne(context.Background(), queueName, messageID)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondAdapterError(echoContext, err)
 	}
 
 	err = echoContext.JSONPretty(http.StatusOK, nil, "   ")
@@ -143,35 +366,34 @@ func (b *BrokerAdapterManager) DeleteMessagesFromQueue(echoContext echo.Context)
 	brokerID := echoContext.Param("brokerID")
 	body, err := getBody(echoContext)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondError(echoContext, http.StatusInternalServerError, err.Error())
 	}
 
 	var req structs.RequestMessageIDs
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondError(echoContext, http.StatusBadRequest, err.Error())
 	}
 
 	if queueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
 	}
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, fmt.Sprintf("No connection found for %s", brokerID), "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
 	}
 
-	errs := brokerAdapter.DeleteMany(context.Background(), queueName, req.MessageIDs)
-	if errs != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, createErrorStrings(errs), "   ")
+	if !adapters.GetCapabilities(brokerAdapter).Delete {
+		return notSupported(echoContext, "Deleting messages", brokerID)
 	}
 
-	err = echoContext.JSONPretty(http.StatusOK, nil, "   ")
-	return err
+	results := brokerAdapter.DeleteMany(context.Background(), queueName, req.MessageIDs)
+	return respondResults(echoContext, "Deleted", results)
 }
 
 func (b *BrokerAdapterManager) MoveMessage(echoContext echo.Context) error {
@@ -182,29 +404,33 @@ func (b *BrokerAdapterManager) MoveMessage(echoContext echo.Context) error {
 	messageID := echoContext.Param("messageID")
 
 	if queueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
 	}
 
 	if toQueueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no destination queue name given")
 	}
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	if messageID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no message ID given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, fmt.Sprintf("No connection found for %s", brokerID), "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	if !adapters.GetCapabilities(brokerAdapter).Move {
+		return notSupported(echoContext, "Moving messages", brokerID)
 	}
 
 	err := brokerAdapter.MoveOne(context.Background(), queueName, toQueueName, messageID)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondAdapterError(echoContext, err)
 	}
 
 	err = echoContext.JSONPretty(http.StatusOK, nil, "   ")
@@ -218,40 +444,204 @@ func (b *BrokerAdapterManager) MoveMessages(echoContext echo.Context) error {
 	brokerID := echoContext.Param("brokerID")
 	body, err := getBody(echoContext)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondError(echoContext, http.StatusInternalServerError, err.Error())
 	}
 
 	var req structs.RequestMessageIDs
 	err = json.Unmarshal(body, &req)
 	if err != nil {
-		return echoContext.JSONPretty(http.StatusInternalServerError, err.Error(), "   ")
+		return respondError(echoContext, http.StatusBadRequest, err.Error())
 	}
 
 	if queueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
 	}
 
 	if toQueueName == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no destination queue name given")
 	}
 
 	if brokerID == "" {
-		return echoContext.JSONPretty(http.StatusBadRequest, nil, "   ")
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
 	}
 
 	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
 	if !ok {
-		return echoContext.JSONPretty(http.StatusBadRequest, fmt.Sprintf("No connection found for %s", brokerID), "   ")
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
 	}
 
-	errs := brokerAdapter.Move(context.Background(), queueName, toQueueName, req.MessageIDs)
-	if errs != nil {
-		stringErrs := createErrorStrings(errs)
-		return echoContext.JSONPretty(http.StatusInternalServerError, stringErrs, "   ")
+	if !adapters.GetCapabilities(brokerAdapter).Move {
+		return notSupported(echoContext, "Moving messages", brokerID)
 	}
 
-	err = echoContext.JSONPretty(http.StatusOK, nil, "   ")
-	return err
+	results := brokerAdapter.Move(context.Background(), queueName, toQueueName, req.MessageIDs)
+	return respondResults(echoContext, "Moved", results)
+}
+
+func (b *BrokerAdapterManager) CopyMessages(echoContext echo.Context) error {
+
+	queueName := echoContext.Param("queueName")
+	toQueueName := echoContext.Param("toQueueName")
+	brokerID := echoContext.Param("brokerID")
+	body, err := getBody(echoContext)
+	if err != nil {
+		return respondError(echoContext, http.StatusInternalServerError, err.Error())
+	}
+
+	var req structs.RequestMessageIDs
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return respondError(echoContext, http.StatusBadRequest, err.Error())
+	}
+
+	if queueName == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
+	}
+
+	if toQueueName == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no destination queue name given")
+	}
+
+	if brokerID == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
+	}
+
+	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
+	if !ok {
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	copier, ok := brokerAdapter.(adapters.Copier)
+	if !ok || !adapters.GetCapabilities(brokerAdapter).Copy {
+		return notSupported(echoContext, "Copy", brokerID)
+	}
+
+	results := copier.Copy(context.Background(), queueName, toQueueName, req.MessageIDs)
+	return respondResults(echoContext, "Copied", results)
+}
+
+func (b *BrokerAdapterManager) RedriveMessages(echoContext echo.Context) error {
+
+	queueName := echoContext.Param("queueName")
+	brokerID := echoContext.Param("brokerID")
+	body, err := getBody(echoContext)
+	if err != nil {
+		return respondError(echoContext, http.StatusInternalServerError, err.Error())
+	}
+
+	// no body (or no message IDs) redrives the whole queue
+	var req structs.RequestMessageIDs
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			return respondError(echoContext, http.StatusBadRequest, err.Error())
+		}
+	}
+
+	if queueName == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no queue name given")
+	}
+
+	if brokerID == "" {
+		return respondError(echoContext, http.StatusBadRequest, "no broker name given")
+	}
+
+	brokerAdapter, ok := b.MapBrokerNameToAdapter[brokerID]
+	if !ok {
+		return respondError(echoContext, http.StatusNotFound, fmt.Sprintf("No connection found for %s", brokerID))
+	}
+
+	redriver, ok := brokerAdapter.(adapters.Redriver)
+	if !ok || !adapters.GetCapabilities(brokerAdapter).Redrive {
+		return notSupported(echoContext, "Redrive", brokerID)
+	}
+
+	results := redriver.Redrive(context.Background(), queueName, req.MessageIDs)
+	return respondResults(echoContext, "Redrove", results)
+}
+
+func getConnectionInfo(status adapters.ConnectionStatus) map[string]string {
+	info := map[string]string{
+		"Connection": string(status.State),
+		"URL":        status.URL,
+		"Since":      status.Since.Format(time.RFC3339),
+	}
+	if status.LastError != "" {
+		info["Last Error"] = status.LastError
+	}
+	if status.Attempts > 0 {
+		info["Attempts"] = strconv.Itoa(status.Attempts)
+	}
+	return info
+}
+
+// respondError answers with the status and the error envelope every failed request gets
+func respondError(echoContext echo.Context, status int, message string, errs ...string) error {
+	return echoContext.JSONPretty(status, structs.ErrorResponse{
+		Status:  status,
+		Error:   http.StatusText(status),
+		Message: message,
+		Errors:  errs,
+	}, "   ")
+}
+
+// respondAdapterError answers with the status for the kind of failure an adapter's error is
+func respondAdapterError(echoContext echo.Context, err error) error {
+	return respondError(echoContext, errorStatus(err), err.Error())
+}
+
+// respondResults answers a bulk request with the result of each message.  When only some of them failed
+// the answer is 207 Multi-Status, and when all of them failed it is the status their errors share or else 500.
+func respondResults(echoContext echo.Context, done string, results []structs.MessageResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	if len(errs) == 0 {
+		return echoContext.JSONPretty(http.StatusOK, structs.BulkResponse{Results: results}, "   ")
+	}
+
+	status := http.StatusMultiStatus
+	if len(errs) == len(results) {
+		status = sharedErrorStatus(errs)
+	}
+	return echoContext.JSONPretty(status, structs.ErrorResponse{
+		Status:  status,
+		Error:   http.StatusText(status),
+		Message: fmt.Sprintf("%s %d of %d messages", done, len(results)-len(errs), len(results)),
+		Errors:  createErrorStrings(errs),
+		Results: results,
+	}, "   ")
+}
+
+// sharedErrorStatus is the status every one of the errors has, or else 500
+func sharedErrorStatus(errs []error) int {
+	status := errorStatus(errs[0])
+	for _, err := range errs[1:] {
+		if errorStatus(err) != status {
+			return http.StatusInternalServerError
+		}
+	}
+	return status
+}
+
+// errorStatus is the HTTP status for the kind of failure an adapter's error is, 500 when it isn't one
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, adapters.ErrQueueNotFound), errors.Is(err, adapters.ErrMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, adapters.ErrNotSupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, adapters.ErrBrokerUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, adapters.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, adapters.ErrBadRequest):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
 }
 
 func createErrorStrings(errs []error) []string {
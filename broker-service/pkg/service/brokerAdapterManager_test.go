@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/adapters"
+	"gitlab.com/ciorg/bridge/brokerUI/broker-service/pkg/structs"
+)
+
+// newTestServer routes requests the way main does to a manager holding a memory broker named memory
+func newTestServer(t *testing.T) *echo.Echo {
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	contents := `{"queues": [{"name": "orders.dlq", "messages": [{"id": "1", "body": "one"}, {"id": "2", "body": "two"}]}, {"name": "orders"}]}`
+	if err := ioutil.WriteFile(fixture, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	memory, err := adapters.NewMemoryAdapter(context.Background(), fixture)
+	if err != nil {
+		t.Fatalf("NewMemoryAdapter() error = %v", err)
+	}
+
+	manager := &BrokerAdapterManager{MapBrokerNameToAdapter: map[string]adapters.Adapter{"memory": memory}}
+	e := echo.New()
+	e.GET("brokers", manager.GetAllBrokers)
+	e.GET("brokers/:brokerID/queues/:queueName/messages", manager.GetAllMessages)
+	e.GET("brokers/:brokerID/queues", manager.GetAllQueues)
+	e.DELETE("brokers/:brokerID/queues/:queueName/messages", manager.DeleteMessagesFromQueue)
+	e.POST("brokers/:brokerID/queues/:queueName/toqueue/:toQueueName/messages", manager.MoveMessages)
+	e.POST("brokers/:brokerID/queues/:queueName/copytoqueue/:toQueueName/messages", manager.CopyMessages)
+	e.POST("brokers/:brokerID/queues/:queueName/redrive", manager.RedriveMessages)
+	return e
+}
+
+// serve answers the request and reads the error body, when there is one
+func serve(t *testing.T, e *echo.Echo, method string, target string, body string) (int, structs.ErrorResponse) {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, request)
+
+	var response structs.ErrorResponse
+	if recorder.Code >= http.StatusBadRequest {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s answered %s, not an error response: %v", method, target, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code, response
+}
+
+func TestGetAllMessages_BadCursor(t *testing.T) {
+	e := newTestServer(t)
+
+	status, response := serve(t, e, http.MethodGet, "/brokers/memory/queues/orders.dlq/messages?cursor=garbage", "")
+	if status != http.StatusBadRequest || response.Message != "invalid cursor garbage" {
+		t.Errorf("GET with a bad cursor = %d %+v, want 400", status, response)
+	}
+
+	if status, _ := serve(t, e, http.MethodGet, "/brokers/memory/queues/orders.dlq/messages?cursor=1", ""); status != http.StatusOK {
+		t.Errorf("GET with a cursor = %d, want 200", status)
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	e := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   int
+	}{
+		{"unknown broker", http.MethodGet, "/brokers/nowhere/queues", "", http.StatusNotFound},
+		{"unknown broker's messages", http.MethodGet, "/brokers/nowhere/queues/orders.dlq/messages", "", http.StatusNotFound},
+		{"unknown queue", http.MethodGet, "/brokers/memory/queues/nowhere/messages", "", http.StatusNotFound},
+		{"delete with bad JSON", http.MethodDelete, "/brokers/memory/queues/orders.dlq/messages", "{", http.StatusBadRequest},
+		{"move with bad JSON", http.MethodPost, "/brokers/memory/queues/orders.dlq/toqueue/orders/messages", "{", http.StatusBadRequest},
+		{"copy with bad JSON", http.MethodPost, "/brokers/memory/queues/orders.dlq/copytoqueue/orders/messages", "{", http.StatusBadRequest},
+		{"redrive with bad JSON", http.MethodPost, "/brokers/memory/queues/orders.dlq/redrive", "{", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, response := serve(t, e, tt.method, tt.target, tt.body); status != tt.want || response.Status != tt.want {
+				t.Errorf("%s %s = %d %+v, want %d", tt.method, tt.target, status, response, tt.want)
+			}
+		})
+	}
+
+	none := echo.New()
+	none.GET("brokers", (&BrokerAdapterManager{}).GetAllBrokers)
+	if status, _ := serve(t, none, http.MethodGet, "/brokers", ""); status != http.StatusServiceUnavailable {
+		t.Errorf("GET /brokers without brokers = %d, want 503", status)
+	}
+}
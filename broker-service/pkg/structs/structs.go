@@ -12,3 +12,41 @@ type StandardMessage struct {
 type RequestMessageIDs struct {
 	MessageIDs []string `json:"messageIDs"`
 }
+
+// MessageStatus is what became of one message of a request on many messages
+type MessageStatus string
+
+const (
+	MessageMoved    MessageStatus = "moved"
+	MessageDeleted  MessageStatus = "deleted"
+	MessageCopied   MessageStatus = "copied"
+	MessageRedriven MessageStatus = "redriven"
+	MessageNotFound MessageStatus = "notFound"
+	MessageFailed   MessageStatus = "failed"
+)
+
+// MessageResult is what became of one message and, when it didn't go through, why
+type MessageResult struct {
+	MessageID string
+	Status    MessageStatus
+	Error     string `json:",omitempty"`
+	// Err is the error itself, which the response's status is worked out from
+	Err error `json:"-"`
+}
+
+// BulkResponse is the body of a request on many messages that went through for all of them
+type BulkResponse struct {
+	Results []MessageResult
+}
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Status int
+	// Error is the status text, e.g. Not Found
+	Error   string
+	Message string
+	// Errors holds each error of an operation on many messages
+	Errors []string `json:",omitempty"`
+	// Results holds what became of each message of a bulk request that didn't go through for all of them
+	Results []MessageResult `json:",omitempty"`
+}